@@ -63,6 +63,11 @@ const router = createRouter({
       name: 'payment-success',
       component: () => import('@/views/PaymentSuccessView.vue')
     },
+    {
+      path: '/unsubscribe',
+      name: 'unsubscribe',
+      component: () => import('@/views/UnsubscribeView.vue')
+    },
     {
       path: '/admin',
       name: 'admin',
@@ -23,6 +23,10 @@ export const useAdminStore = defineStore('admin', () => {
   const users = ref([])
   const usersPagination = ref({ page: 1, page_size: 10, total: 0, total_pages: 0 })
 
+  // Roles
+  const roles = ref([])
+  const availablePermissions = ref([])
+
   // Orders
   const orders = ref([])
   const ordersPagination = ref({ page: 1, page_size: 10, total: 0, total_pages: 0 })
@@ -154,6 +158,28 @@ export const useAdminStore = defineStore('admin', () => {
     await api.delete(`/admin/users/${id}`)
   }
 
+  // ==================== Roles ====================
+  async function fetchRoles() {
+    const response = await api.get('/admin/roles')
+    roles.value = (response.data.roles || []).map(r => ({ ...r.role, user_count: r.user_count }))
+    availablePermissions.value = response.data.available_permissions || []
+    return roles.value
+  }
+
+  async function createRole(roleData) {
+    const response = await api.post('/admin/roles', roleData)
+    return response.data.role
+  }
+
+  async function updateRole(id, roleData) {
+    const response = await api.put(`/admin/roles/${id}`, roleData)
+    return response.data.role
+  }
+
+  async function deleteRole(id) {
+    await api.delete(`/admin/roles/${id}`)
+  }
+
   // ==================== Orders ====================
   async function fetchOrders(params = {}) {
     try {
@@ -285,6 +311,8 @@ export const useAdminStore = defineStore('admin', () => {
     pluginsPagination,
     users,
     usersPagination,
+    roles,
+    availablePermissions,
     orders,
     ordersPagination,
     licenses,
@@ -315,6 +343,12 @@ export const useAdminStore = defineStore('admin', () => {
     updateUser,
     deleteUser,
 
+    // Role Actions
+    fetchRoles,
+    createRole,
+    updateRole,
+    deleteRole,
+
     // Order Actions
     fetchOrders,
     getOrder,
@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import api from '@/utils/api'
+import api, { refreshAccessToken } from '@/utils/api'
 
 export const useAuthStore = defineStore('auth', () => {
   const user = ref(null)
@@ -20,13 +20,24 @@ export const useAuthStore = defineStore('auth', () => {
   }
 
   const isAuthenticated = computed(() => !!token.value && !!user.value)
-  const isAdmin = computed(() => user.value?.role === 'admin')
+  // Any admin permission opens the admin area; each section checks its own with can()
+  const isAdmin = computed(() => (user.value?.permissions?.length || 0) > 0)
 
-  async function login() {
+  function can(permission) {
+    return !!user.value?.permissions?.includes(permission)
+  }
+
+  // redirectTo is the path to return to after login; defaults to the current page
+  async function login(redirectTo, provider = 'github') {
     try {
       loading.value = true
       error.value = null
-      const response = await api.get('/auth/github')
+      if (typeof redirectTo !== 'string') {
+        redirectTo = window.location.pathname + window.location.search
+      }
+      const response = await api.get(`/auth/${provider}`, {
+        params: { redirect_to: redirectTo }
+      })
       window.location.href = response.data.auth_url
     } catch (err) {
       error.value = err.message
@@ -36,6 +47,21 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  // Emails a one-time login link
+  async function requestMagicLink(email, redirectTo) {
+    await api.post('/auth/email', {
+      email,
+      redirect_to: redirectTo || window.location.pathname + window.location.search,
+      language: localStorage.getItem('language') || 'en'
+    })
+  }
+
+  // Redeems a login link; the session arrives in the refresh cookie
+  async function verifyMagicLink(magicToken) {
+    const response = await api.post('/auth/email/verify', { token: magicToken })
+    return response.data.redirect_to
+  }
+
   async function handleCallback(code, state) {
     try {
       loading.value = true
@@ -64,8 +90,8 @@ export const useAuthStore = defineStore('auth', () => {
     try {
       loading.value = true
       const response = await api.get('/auth/me')
-      user.value = response.data.user
-      localStorage.setItem('user', JSON.stringify(response.data.user))
+      user.value = { ...response.data.user, permissions: response.data.permissions || [] }
+      localStorage.setItem('user', JSON.stringify(user.value))
     } catch (err) {
       // Token might be expired
       logout()
@@ -74,7 +100,16 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  // Exchange the refresh cookie set by the login callback for an access token
+  async function refresh() {
+    const newToken = await refreshAccessToken()
+    token.value = newToken
+    return newToken
+  }
+
   function logout() {
+    // End the server-side session; local state is cleared regardless of the outcome
+    api.post('/auth/logout', null, { _skipRefresh: true }).catch(() => {})
     user.value = null
     token.value = null
     localStorage.removeItem('token')
@@ -93,9 +128,13 @@ export const useAuthStore = defineStore('auth', () => {
     error,
     isAuthenticated,
     isAdmin,
+    can,
     login,
+    requestMagicLink,
+    verifyMagicLink,
     handleCallback,
     fetchUser,
+    refresh,
     logout,
     setToken
   }
@@ -3,11 +3,49 @@ import axios from 'axios'
 const api = axios.create({
   baseURL: import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080/api',
   timeout: 30000,
+  // Send the HttpOnly refresh cookie to /auth/refresh and /auth/logout
+  withCredentials: true,
   headers: {
     'Content-Type': 'application/json'
   }
 })
 
+// Exchange the refresh cookie for a new access token. Concurrent 401s share one refresh,
+// because the refresh token rotates on every use. A 409 means another tab rotated the
+// cookie first, so the refresh is retried once with the cookie it set.
+let refreshing = null
+export function refreshAccessToken() {
+  if (!refreshing) {
+    refreshing = api.post('/auth/refresh', null, { _skipRefresh: true })
+      .catch((error) => {
+        if (error.response?.status !== 409) {
+          throw error
+        }
+        return new Promise((resolve) => setTimeout(resolve, 500))
+          .then(() => api.post('/auth/refresh', null, { _skipRefresh: true }))
+      })
+      .then((response) => {
+        localStorage.setItem('token', response.data.token)
+        return response.data.token
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
+// Ask for a TOTP or recovery code and exchange it for an access token carrying the verification
+async function verifySecondFactor(message) {
+  const code = window.prompt(message)
+  if (!code) {
+    throw new Error('Two-factor verification cancelled')
+  }
+  const response = await api.post('/auth/2fa/verify', { code: code.trim() }, { _skipRefresh: true })
+  localStorage.setItem('token', response.data.token)
+  return response.data.token
+}
+
 // Request interceptor
 api.interceptors.request.use(
   (config) => {
@@ -27,14 +65,46 @@ api.interceptors.response.use(
   (response) => {
     return response
   },
-  (error) => {
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && original && !original._skipRefresh && !original._retried) {
+      // Access token expired - refresh once and replay the request
+      original._retried = true
+      try {
+        const token = await refreshAccessToken()
+        original.headers.Authorization = `Bearer ${token}`
+        return api(original)
+      } catch (refreshError) {
+        // Session has ended, fall through to the login redirect
+      }
+    }
+
+    // Logins of 2FA users and sensitive admin actions need a second-factor code
+    const mfaCode = error.response?.data?.code
+    if (error.response?.status === 403 && original && !original._mfaRetried &&
+        (mfaCode === 'mfa_required' || mfaCode === 'mfa_step_up_required')) {
+      original._mfaRetried = true
+      try {
+        const token = await verifySecondFactor(mfaCode === 'mfa_required'
+          ? 'Enter the code from your authenticator app (or a recovery code)'
+          : 'Confirm this action with the code from your authenticator app')
+        original.headers.Authorization = `Bearer ${token}`
+        return api(original)
+      } catch (verifyError) {
+        return Promise.reject(verifyError)
+      }
+    }
+
     if (error.response) {
       // Handle specific error codes
       switch (error.response.status) {
         case 401:
           // Unauthorized - redirect to login
           localStorage.removeItem('token')
-          window.location.href = '/'
+          localStorage.removeItem('user')
+          if (!original?._skipRefresh) {
+            window.location.href = '/'
+          }
           break
         case 403:
           // Forbidden
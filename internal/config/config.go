@@ -25,12 +25,35 @@ type Config struct {
 	GitHubClientSecret string
 	GitHubRedirectURL  string
 
+	// Verifies GitHub App webhooks (X-Hub-Signature-256); release events need it
+	GitHubWebhookSecret string
+
+	// Additional login providers (OpenID Connect)
+	GoogleClientID     string
+	GoogleClientSecret string
+	GitLabClientID     string
+	GitLabClientSecret string
+	GitLabURL          string
+
+	// Email magic-link login
+	MagicLinkTTLMinutes int
+
+	// OAuth login state
+	OAuthStateStore      string // postgres or redis
+	OAuthStateTTLMinutes int
+	RedisURL             string // redis://[user:password@]host:port[/db]
+
 	// GitHub Personal Access Token
 	GitHubAdminToken string // Personal Access Token for managing collaborators
 
 	// JWT
-	JWTSecret      string
-	JWTExpiryHours int
+	JWTSecret             string
+	AccessTokenTTLMinutes int // lifetime of the JWT sent as bearer token
+	RefreshTokenTTLDays   int // lifetime of a login session, renewed on every refresh
+
+	// Two-factor authentication
+	TOTPIssuer       string // account name shown in authenticator apps
+	MFAStepUpMinutes int    // how recent a 2FA verification must be for sensitive admin actions
 
 	// Payment Methods
 	PaymentStripeEnabled bool
@@ -63,21 +86,47 @@ type Config struct {
 	SMTPFrom     string
 	SMTPFromName string
 
+	// Email transport: smtp, http (Mailgun-style API) or mailbox (.eml files for development)
+	EmailTransport   string
+	EmailHTTPURL     string
+	EmailHTTPFormat  string // form (Mailgun) or json
+	EmailHTTPAPIKey  string
+	EmailHTTPAPIUser string // set for HTTP basic auth ("api" for Mailgun), otherwise a bearer token is sent
+	EmailMailboxDir  string
+
+	// Verifies bounce and complaint webhooks from the email provider (Mailgun webhook
+	// signing key, or a bearer token for the generic format)
+	EmailWebhookSecret string
+
 	// Cron
 	CronMaintenanceCheck string
 
+	// Background jobs
+	JobWorkers int
+
 	// Admin
 	AdminEmail    string
 	AdminGitHubID string
 
 	// Defaults
 	DefaultMaintenanceMonths int
+	DefaultGracePeriodDays   int
+
+	// Release downloads
+	DownloadURLTTLMinutes int
 }
 
 func Load() *Config {
-	jwtExpiryHours, _ := strconv.Atoi(getEnv("JWT_EXPIRY_HOURS", "720"))
+	accessTokenTTLMinutes, _ := strconv.Atoi(getEnv("ACCESS_TOKEN_TTL_MINUTES", "15"))
+	magicLinkTTLMinutes, _ := strconv.Atoi(getEnv("MAGIC_LINK_TTL_MINUTES", "15"))
+	oauthStateTTLMinutes, _ := strconv.Atoi(getEnv("OAUTH_STATE_TTL_MINUTES", "10"))
+	refreshTokenTTLDays, _ := strconv.Atoi(getEnv("REFRESH_TOKEN_TTL_DAYS", "30"))
+	mfaStepUpMinutes, _ := strconv.Atoi(getEnv("MFA_STEP_UP_MINUTES", "10"))
 	smtpPort, _ := strconv.Atoi(getEnv("SMTP_PORT", "587"))
 	defaultMaintenanceMonths, _ := strconv.Atoi(getEnv("DEFAULT_MAINTENANCE_MONTHS", "12"))
+	defaultGracePeriodDays, _ := strconv.Atoi(getEnv("DEFAULT_GRACE_PERIOD_DAYS", "14"))
+	downloadURLTTLMinutes, _ := strconv.Atoi(getEnv("DOWNLOAD_URL_TTL_MINUTES", "15"))
+	jobWorkers, _ := strconv.Atoi(getEnv("JOB_WORKERS", "4"))
 
 	return &Config{
 		AppEnv:      getEnv("APP_ENV", "development"),
@@ -92,14 +141,31 @@ func Load() *Config {
 		DBName:     getEnv("DB_NAME", "plugin_store"),
 		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
 
-		GitHubClientID:     getEnv("GITHUB_CLIENT_ID", ""),
-		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
-		GitHubRedirectURL:  getEnv("GITHUB_REDIRECT_URL", ""),
+		GitHubClientID:      getEnv("GITHUB_CLIENT_ID", ""),
+		GitHubClientSecret:  getEnv("GITHUB_CLIENT_SECRET", ""),
+		GitHubRedirectURL:   getEnv("GITHUB_REDIRECT_URL", ""),
+		GitHubWebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
+
+		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GitLabClientID:     getEnv("GITLAB_CLIENT_ID", ""),
+		GitLabClientSecret: getEnv("GITLAB_CLIENT_SECRET", ""),
+		GitLabURL:          getEnv("GITLAB_URL", "https://gitlab.com"),
+
+		MagicLinkTTLMinutes: magicLinkTTLMinutes,
+
+		OAuthStateStore:      getEnv("OAUTH_STATE_STORE", "postgres"),
+		OAuthStateTTLMinutes: oauthStateTTLMinutes,
+		RedisURL:             getEnv("REDIS_URL", "redis://localhost:6379/0"),
 
 		GitHubAdminToken: getEnv("GITHUB_ADMIN_TOKEN", ""),
 
-		JWTSecret:      getEnv("JWT_SECRET", ""),
-		JWTExpiryHours: jwtExpiryHours,
+		JWTSecret:             getEnv("JWT_SECRET", ""),
+		AccessTokenTTLMinutes: accessTokenTTLMinutes,
+		RefreshTokenTTLDays:   refreshTokenTTLDays,
+
+		TOTPIssuer:       getEnv("TOTP_ISSUER", "Git Store"),
+		MFAStepUpMinutes: mfaStepUpMinutes,
 
 		PaymentStripeEnabled: getEnv("PAYMENT_STRIPE_ENABLED", "true") == "true",
 		PaymentPayPalEnabled: getEnv("PAYMENT_PAYPAL_ENABLED", "false") == "true",
@@ -127,12 +193,26 @@ func Load() *Config {
 		SMTPFrom:     getEnv("SMTP_FROM", ""),
 		SMTPFromName: getEnv("SMTP_FROM_NAME", "Plugin Store"),
 
+		EmailTransport:   getEnv("EMAIL_TRANSPORT", "smtp"),
+		EmailHTTPURL:     getEnv("EMAIL_HTTP_URL", ""),
+		EmailHTTPFormat:  getEnv("EMAIL_HTTP_FORMAT", "form"),
+		EmailHTTPAPIKey:  getEnv("EMAIL_HTTP_API_KEY", ""),
+		EmailHTTPAPIUser: getEnv("EMAIL_HTTP_API_USER", ""),
+		EmailMailboxDir:  getEnv("EMAIL_MAILBOX_DIR", "./mailbox"),
+
+		EmailWebhookSecret: getEnv("EMAIL_WEBHOOK_SECRET", ""),
+
 		CronMaintenanceCheck: getEnv("CRON_MAINTENANCE_CHECK", "0 2 * * *"),
 
+		JobWorkers: jobWorkers,
+
 		AdminEmail:    getEnv("ADMIN_EMAIL", ""),
 		AdminGitHubID: getEnv("ADMIN_GITHUB_ID", ""),
 
 		DefaultMaintenanceMonths: defaultMaintenanceMonths,
+		DefaultGracePeriodDays:   defaultGracePeriodDays,
+
+		DownloadURLTTLMinutes: downloadURLTTLMinutes,
 	}
 }
 
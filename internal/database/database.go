@@ -61,6 +61,29 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.Statistic{},
 		&models.SystemSetting{},
 		&models.Page{},
+		&models.Job{},
+		&models.SchedulerRun{},
+		&models.PluginRelease{},
+		&models.PluginReleaseAsset{},
+		&models.ReleaseDownload{},
+		&models.AccessToken{},
+		&models.ReleaseManifest{},
+		&models.Session{},
+		&models.OAuthState{},
+		&models.UserIdentity{},
+		&models.LoginToken{},
+		&models.Role{},
+		&models.AuditLog{},
+		&models.UserTOTP{},
+		&models.RecoveryCode{},
+		&models.EmailTemplate{},
+		&models.NotificationPreference{},
+		&models.EmailSuppression{},
+		&models.EmailWebhookToken{},
+		&models.Notification{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.CheckoutRecovery{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate: %w", err)
@@ -78,6 +101,88 @@ func AutoMigrate(db *gorm.DB) error {
 		log.Printf("Warning: failed to make github_repo_name nullable: %v", err)
 	}
 
+	// Roles live in the roles table now, so the original user/admin check is gone
+	if err := db.Exec("ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check").Error; err != nil {
+		log.Printf("Warning: failed to drop users_role_check: %v", err)
+	}
+	// Email types are defined by the code that queues them
+	if err := db.Exec("ALTER TABLE email_notifications DROP CONSTRAINT IF EXISTS email_notifications_notification_type_check").Error; err != nil {
+		log.Printf("Warning: failed to drop email_notifications_notification_type_check: %v", err)
+	}
+	// Replaced by the partial unique index idx_jobs_active_unique_key
+	if err := db.Exec("DROP INDEX IF EXISTS idx_jobs_unique_key").Error; err != nil {
+		log.Printf("Warning: failed to drop idx_jobs_unique_key: %v", err)
+	}
+	if err := createAuditLogTrigger(db); err != nil {
+		return fmt.Errorf("failed to make audit_logs append-only: %w", err)
+	}
+	if err := seedRoles(db); err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
+	if err := seedSettings(db); err != nil {
+		return fmt.Errorf("failed to seed settings: %w", err)
+	}
+
 	log.Println("Database migration completed")
 	return nil
 }
+
+// createAuditLogTrigger rejects every update and delete of audit log entries
+func createAuditLogTrigger(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs").Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE TRIGGER audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`).Error
+	})
+}
+
+// seedRoles creates the built-in roles if they are missing
+func seedRoles(db *gorm.DB) error {
+	roles := []models.Role{
+		{Name: models.RoleAdmin, Description: "Full access", Permissions: models.PermissionAll, IsSystem: true},
+		{Name: models.RoleUser, Description: "Customer without admin access", IsSystem: true},
+		{Name: "support", Description: "View orders and users, manage licenses", Permissions: "orders:read licenses:read licenses:write users:read"},
+	}
+	for i := range roles {
+		if err := db.Where("name = ?", roles[i].Name).FirstOrCreate(&roles[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedSettings creates settings the admin UI edits but no code path creates on demand.
+// Existing values are kept.
+func seedSettings(db *gorm.DB) error {
+	settings := []models.SystemSetting{
+		{Key: "chatops_slack_webhook_url", Value: "", Description: "Slack incoming webhook URL for chat-ops messages, empty to turn off"},
+		{Key: "chatops_slack_events", Value: "*", Description: "Comma-separated events posted to Slack: order.paid, refund.created, github_grant.failed, scheduler.failed or *"},
+		{Key: "chatops_discord_webhook_url", Value: "", Description: "Discord webhook URL for chat-ops messages, empty to turn off"},
+		{Key: "chatops_discord_events", Value: "*", Description: "Comma-separated events posted to Discord: order.paid, refund.created, github_grant.failed, scheduler.failed or *"},
+		{Key: "chatops_telegram_webhook_url", Value: "", Description: "Telegram Bot API sendMessage URL (https://api.telegram.org/bot<token>/sendMessage), empty to turn off"},
+		{Key: "chatops_telegram_chat_id", Value: "", Description: "Telegram chat ID chat-ops messages are sent to"},
+		{Key: "chatops_telegram_events", Value: "*", Description: "Comma-separated events posted to Telegram: order.paid, refund.created, github_grant.failed, scheduler.failed or *"},
+		{Key: "checkout_recovery_delay_hours", Value: "24", Description: "Hours an order stays unpaid before its buyer is reminded"},
+		{Key: "checkout_recovery_max_age_days", Value: "7", Description: "Unpaid orders older than this many days get no reminder"},
+		{Key: "checkout_recovery_discount_percent", Value: "0", Description: "Discount offered in checkout reminders in percent, 0 for none"},
+		{Key: "checkout_recovery_discount_hours", Value: "48", Description: "Hours the checkout reminder discount can be used"},
+		{Key: "admin_digest_recipients", Value: "", Description: "Comma-separated addresses the admin digest is sent to, empty for ADMIN_EMAIL"},
+		{Key: "admin_digest_periods", Value: "daily,weekly", Description: "Comma-separated admin digests to send: daily, weekly, empty for none"},
+	}
+	for i := range settings {
+		if err := db.Where("key = ?", settings[i].Key).FirstOrCreate(&settings[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
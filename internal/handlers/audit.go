@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// maxAuditExportRows caps a single export; narrow the filters to export more
+const maxAuditExportRows = 50000
+
+// recordAudit appends an audit log entry for an admin action. Pass the transaction the
+// change was made in, so the entry is only kept if the change is. before or after is nil
+// for creations and deletions.
+func recordAudit(tx *gorm.DB, c *gin.Context, action, entityType, entityID string, before, after interface{}) error {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    services.AuditDiff(before, after),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		actorID := userID.(uuid.UUID)
+		entry.ActorID = &actorID
+	}
+	entry.ActorEmail = c.GetString("user_email")
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+
+	// Tells the audit middleware this request needs no generic entry
+	c.Set("audit_recorded", true)
+	return nil
+}
+
+// logAudit records an audit entry for changes made outside a transaction
+func logAudit(db *gorm.DB, c *gin.Context, action, entityType, entityID string, before, after interface{}) {
+	if err := recordAudit(db, c, action, entityType, entityID, before, after); err != nil {
+		log.Printf("[Audit] %s %s/%s: %v", action, entityType, entityID, err)
+	}
+}
+
+// AuditHandler exposes the admin audit log
+type AuditHandler struct {
+	db *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+// filteredQuery applies the actor_id, actor, action, entity_type, entity_id, from and to filters
+func (h *AuditHandler) filteredQuery(c *gin.Context) (*gorm.DB, error) {
+	query := h.db.Model(&models.AuditLog{})
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor_email ILIKE ?", "%"+actor+"%")
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	for param, op := range map[string]string{"from": ">=", "to": "<"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseAuditTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: use RFC 3339 or YYYY-MM-DD", param)
+		}
+		query = query.Where("created_at "+op+" ?", t)
+	}
+	return query, nil
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// ListAuditLogs lists audit entries, newest first
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query, err := h.filteredQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.AuditLog
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"audit_logs": entries,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// ExportAuditLogs downloads the filtered entries as CSV (default) or JSON lines (format=jsonl)
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	query, err := h.filteredQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("created_at ASC").Limit(maxAuditExportRows).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	filename := "audit-log-" + time.Now().Format("20060102-150405")
+
+	if c.Query("format") == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.jsonl"`, filename))
+		encoder := json.NewEncoder(c.Writer)
+		for i := range entries {
+			if err := encoder.Encode(&entries[i]); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"created_at", "actor_id", "actor_email", "action", "entity_type", "entity_id", "changes", "ip_address", "user_agent"})
+	for _, entry := range entries {
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = entry.ActorID.String()
+		}
+		w.Write([]string{
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			actorID,
+			entry.ActorEmail,
+			entry.Action,
+			entry.EntityType,
+			entry.EntityID,
+			entry.Changes,
+			entry.IPAddress,
+			entry.UserAgent,
+		})
+	}
+	w.Flush()
+}
@@ -4,168 +4,448 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/models"
 	"github.com/nodeloc/git-store/internal/services"
-	"github.com/nodeloc/git-store/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refreshCookieName holds the refresh token; it is only sent to the auth endpoints
+const (
+	refreshCookieName = "refresh_token"
+	refreshCookiePath = "/api/auth"
+
+	// magicLinksPerHour limits login emails per address
+	magicLinksPerHour = 5
 )
 
 type AuthHandler struct {
-	db             *gorm.DB
-	config         *config.Config
-	githubOAuthSvc *services.GitHubOAuthService
-	stateStore     map[string]bool // In production, use Redis
+	db         *gorm.DB
+	config     *config.Config
+	providers  map[string]services.OAuthProvider
+	emailSvc   *services.EmailService
+	sessionSvc *services.SessionService
+	stateStore services.OAuthStateStore
 }
 
-func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, stateStore services.OAuthStateStore) *AuthHandler {
 	return &AuthHandler{
-		db:             db,
-		config:         cfg,
-		githubOAuthSvc: services.NewGitHubOAuthService(cfg),
-		stateStore:     make(map[string]bool),
+		db:         db,
+		config:     cfg,
+		providers:  services.NewOAuthProviders(cfg),
+		emailSvc:   services.NewEmailService(cfg, db),
+		sessionSvc: services.NewSessionService(db, cfg),
+		stateStore: stateStore,
 	}
 }
 
-func (h *AuthHandler) GitHubLogin(c *gin.Context) {
-	// Generate state token
-	state := generateStateToken()
-	h.stateStore[state] = true
+// ListProviders lists the enabled login methods
+func (h *AuthHandler) ListProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{
+		"providers":  names,
+		"magic_link": services.EmailTransportConfigured(h.config),
+	})
+}
 
-	// Get GitHub OAuth URL
-	authURL := h.githubOAuthSvc.GetAuthURL(state)
+// OAuthLogin starts a login with a provider. redirect_to is the frontend path to return to afterwards.
+func (h *AuthHandler) OAuthLogin(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	ttl := time.Duration(h.config.OAuthStateTTLMinutes) * time.Minute
+	state, err := services.NewOAuthState(safeRedirectPath(c.Query("redirect_to")), ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	state.Provider = provider.Name()
+
+	authURL := provider.AuthURL(state.State, state.CodeVerifier)
+	if authURL == "" {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login provider is unavailable"})
+		return
+	}
+
+	if err := h.stateStore.Save(c.Request.Context(), state); err != nil {
+		log.Printf("[OAuth] Failed to save login state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"auth_url": authURL,
-		"state":    state,
+		"state":    state.State,
 	})
 }
 
-func (h *AuthHandler) GitHubCallback(c *gin.Context) {
-	code := c.Query("code")
-	state := c.Query("state")
+// OAuthCallback finishes a provider login, signing in, linking or creating the user by email
+func (h *AuthHandler) OAuthCallback(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
 
 	// Verify state
-	if !h.stateStore[state] {
+	loginState, err := h.stateStore.Consume(c.Request.Context(), c.Query("state"))
+	if err != nil || loginState.Provider != provider.Name() {
+		if err != nil && !errors.Is(err, services.ErrOAuthStateNotFound) {
+			log.Printf("[OAuth] Failed to load login state: %v", err)
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state token"})
 		return
 	}
-	delete(h.stateStore, state)
-
-	ctx := context.Background()
 
-	// Exchange code for token
-	token, err := h.githubOAuthSvc.ExchangeCode(ctx, code)
+	identity, err := provider.Identify(context.Background(), c.Query("code"), loginState.CodeVerifier)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange code"})
+		log.Printf("[OAuth] %s login failed: %v", provider.Name(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
 		return
 	}
 
-	// Get GitHub user info
-	githubUser, err := h.githubOAuthSvc.GetUserInfo(ctx, token.AccessToken)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+	var user *models.User
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = h.findOrCreateUser(tx, identity); err != nil {
+			return err
+		}
+		return h.linkIdentity(tx, user, identity)
+	})
+	if errors.Is(err, services.ErrNoVerifiedEmail) {
+		c.Redirect(http.StatusFound, h.frontendCallbackURL("error", "no_verified_email"))
 		return
 	}
-
-	// Get user email
-	emails, err := h.githubOAuthSvc.GetUserEmails(ctx, token.AccessToken)
-	if err != nil || len(emails) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user email"})
+	if err != nil {
+		log.Printf("[OAuth] Failed to sign in %s user %s: %v", provider.Name(), identity.Subject, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
 		return
 	}
 
-	var primaryEmail string
-	for _, email := range emails {
-		if email.GetPrimary() {
-			primaryEmail = email.GetEmail()
-			break
+	h.completeLogin(c, user, loginState.RedirectTo)
+}
+
+// findOrCreateUser resolves the user of a provider identity: an account linked before,
+// else the user owning one of its verified emails, else a new user
+func (h *AuthHandler) findOrCreateUser(tx *gorm.DB, identity *services.OAuthIdentity) (*models.User, error) {
+	var user models.User
+
+	var linkedUserID uuid.UUID
+	if identity.Provider == "github" {
+		var account models.GitHubAccount
+		if tx.Where("git_hub_user_id = ?", identity.Subject).First(&account).Error == nil {
+			linkedUserID = account.UserID
+		}
+	} else {
+		var linked models.UserIdentity
+		if tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&linked).Error == nil {
+			linkedUserID = linked.UserID
 		}
 	}
-	if primaryEmail == "" {
-		primaryEmail = emails[0].GetEmail()
+	if linkedUserID != uuid.Nil {
+		if err := tx.First(&user, "id = ?", linkedUserID).Error; err != nil {
+			return nil, err
+		}
+		return &user, h.promoteAdmin(tx, &user, identity)
 	}
 
-	// Find or create user
-	var user models.User
-	err = h.db.Where("email = ?", primaryEmail).First(&user).Error
-	if err == gorm.ErrRecordNotFound {
-		// Create new user
-		user = models.User{
-			Email:     primaryEmail,
-			Name:      githubUser.GetName(),
-			AvatarURL: githubUser.GetAvatarURL(),
-			Role:      "user",
-		}
-
-		// Check if this is the admin user
-		if primaryEmail == h.config.AdminEmail ||
-			(h.config.AdminGitHubID != "" && h.config.AdminGitHubID == fmt.Sprintf("%d", githubUser.GetID())) {
-			user.Role = "admin"
-		}
+	if len(identity.Emails) == 0 {
+		return nil, services.ErrNoVerifiedEmail
+	}
 
-		if err := h.db.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-			return
+	// Link to an existing account through its email address, preferring the primary one
+	for _, email := range identity.Emails {
+		if err := tx.Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err == nil {
+			return &user, h.promoteAdmin(tx, &user, identity)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
 		}
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
-	} else {
-		// User exists, check and update admin role if needed
-		shouldBeAdmin := primaryEmail == h.config.AdminEmail ||
-			(h.config.AdminGitHubID != "" && h.config.AdminGitHubID == fmt.Sprintf("%d", githubUser.GetID()))
+	}
 
-		if shouldBeAdmin && user.Role != "admin" {
-			user.Role = "admin"
-			h.db.Save(&user)
-		} else if !shouldBeAdmin && user.Role == "admin" {
-			// Optional: remove admin role if no longer in config
-			// user.Role = "user"
-			// h.db.Save(&user)
+	user = models.User{
+		Email:     identity.Emails[0],
+		Name:      identity.Name,
+		AvatarURL: identity.AvatarURL,
+		Role:      "user",
+	}
+	if h.isAdminIdentity(&user, identity) {
+		user.Role = "admin"
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// isAdminIdentity reports whether the configured admin email or GitHub ID matches
+func (h *AuthHandler) isAdminIdentity(user *models.User, identity *services.OAuthIdentity) bool {
+	if h.config.AdminEmail != "" && strings.EqualFold(user.Email, h.config.AdminEmail) {
+		return true
+	}
+	return identity != nil && identity.Provider == "github" &&
+		h.config.AdminGitHubID != "" && h.config.AdminGitHubID == identity.Subject
+}
+
+func (h *AuthHandler) promoteAdmin(tx *gorm.DB, user *models.User, identity *services.OAuthIdentity) error {
+	if user.Role != "admin" && h.isAdminIdentity(user, identity) {
+		user.Role = "admin"
+		return tx.Model(user).Update("role", "admin").Error
+	}
+	return nil
+}
+
+// linkIdentity records the provider account on the user. GitHub accounts also carry
+// repository access, so licenses bought before linking are handed to them.
+func (h *AuthHandler) linkIdentity(tx *gorm.DB, user *models.User, identity *services.OAuthIdentity) error {
+	if identity.Provider != "github" {
+		var linked models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&linked).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			linked = models.UserIdentity{UserID: user.ID, Provider: identity.Provider, Subject: identity.Subject}
+		} else if err != nil {
+			return err
+		}
+		if len(identity.Emails) > 0 {
+			linked.Email = identity.Emails[0]
 		}
+		linked.Login = identity.Login
+		return tx.Save(&linked).Error
+	}
+
+	githubID, err := strconv.ParseInt(identity.Subject, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid GitHub user ID %q", identity.Subject)
 	}
 
-	// Create or update GitHub account
 	var githubAccount models.GitHubAccount
-	err = h.db.Where("git_hub_user_id = ?", githubUser.GetID()).First(&githubAccount).Error
-	if err == gorm.ErrRecordNotFound {
-		githubID := githubUser.GetID()
+	err = tx.Where("git_hub_user_id = ?", githubID).First(&githubAccount).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		githubAccount = models.GitHubAccount{
 			UserID:       user.ID,
 			GitHubUserID: &githubID,
 			AccountType:  "user",
-			Login:        githubUser.GetLogin(),
-			AccessToken:  token.AccessToken,
+			Login:        identity.Login,
+			AccessToken:  identity.AccessToken,
 		}
-		if err := h.db.Create(&githubAccount).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create GitHub account"})
-			return
+		if err := tx.Create(&githubAccount).Error; err != nil {
+			return fmt.Errorf("failed to create GitHub account: %w", err)
 		}
+	} else if err != nil {
+		return err
 	} else {
 		// Update access token
-		githubAccount.AccessToken = token.AccessToken
-		h.db.Save(&githubAccount)
+		githubAccount.AccessToken = identity.AccessToken
+		githubAccount.Login = identity.Login
+		if err := tx.Save(&githubAccount).Error; err != nil {
+			return err
+		}
+	}
+
+	_, err = services.AttachGitHubAccount(tx, githubAccount.UserID, githubAccount.ID)
+	return err
+}
+
+// completeLogin starts a session and sends the browser back to the frontend, which
+// exchanges the refresh cookie for an access token
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, redirectTo string) {
+	if !user.IsActive {
+		c.Redirect(http.StatusFound, h.frontendCallbackURL("error", "account_disabled"))
+		return
 	}
 
-	// Generate JWT token
-	jwtToken, err := utils.GenerateJWT(user.ID, user.Email, user.Role, h.config)
+	_, refreshToken, err := h.sessionSvc.Create(user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	setRefreshCookie(c, h.config, refreshToken, h.sessionSvc.RefreshTTL())
+
+	if redirectTo != "" {
+		c.Redirect(http.StatusFound, h.frontendCallbackURL("redirect_to", redirectTo))
 		return
 	}
+	c.Redirect(http.StatusFound, h.frontendCallbackURL("", ""))
+}
 
-	// Redirect to frontend with token
+func (h *AuthHandler) frontendCallbackURL(key, value string) string {
 	frontendURL := h.config.FrontendURL
 	if frontendURL == "" {
 		frontendURL = "http://localhost:3001"
 	}
-	c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/callback?token=%s", frontendURL, jwtToken))
+	callbackURL := fmt.Sprintf("%s/auth/callback", frontendURL)
+	if key != "" {
+		callbackURL += "?" + key + "=" + url.QueryEscape(value)
+	}
+	return callbackURL
+}
+
+// RequestMagicLink emails a single-use login link. Unknown addresses get an account on first login.
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req struct {
+		Email      string `json:"email" binding:"required,email"`
+		RedirectTo string `json:"redirect_to"`
+		Language   string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var recent int64
+	h.db.Model(&models.LoginToken{}).
+		Where("email = ? AND created_at > ?", email, time.Now().Add(-time.Hour)).
+		Count(&recent)
+	if recent >= magicLinksPerHour {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login emails, please try again later"})
+		return
+	}
+
+	raw, err := generateMagicLinkToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login link"})
+		return
+	}
+	ttl := h.config.MagicLinkTTLMinutes
+	token := models.LoginToken{
+		Email:      email,
+		TokenHash:  models.HashToken(raw),
+		RedirectTo: safeRedirectPath(req.RedirectTo),
+		IPAddress:  c.ClientIP(),
+		ExpiresAt:  time.Now().Add(time.Duration(ttl) * time.Minute),
+	}
+	if err := h.db.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login link"})
+		return
+	}
+
+	// The frontend posts the token back, so link scanners that open emails cannot use it up
+	loginURL := h.frontendCallbackURL("magic_token", raw)
+
+	// Existing users get the email in their language, new ones in the storefront's
+	language := req.Language
+	var existing models.User
+	if err := h.db.Select("language").Where("email = ?", email).First(&existing).Error; err == nil && existing.Language != "" {
+		language = existing.Language
+	}
+	if err := h.emailSvc.SendMagicLinkEmail(email, language, loginURL, ttl); err != nil {
+		if errors.Is(err, services.ErrEmailSuppressed) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "This address cannot receive email, please contact support"})
+			return
+		}
+		log.Printf("[Auth] Failed to send login link to %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Check your inbox for a login link"})
+}
+
+// VerifyMagicLink redeems a login link, starting a session in the refresh cookie
+func (h *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tokens []models.LoginToken
+	result := h.db.Model(&tokens).Clauses(clause.Returning{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", models.HashToken(req.Token), time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil || len(tokens) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This login link is invalid or has expired"})
+		return
+	}
+	token := tokens[0]
+
+	var user models.User
+	err := h.db.Where("LOWER(email) = ?", token.Email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = models.User{Email: token.Email, Name: strings.Split(token.Email, "@")[0], Role: "user"}
+		if h.isAdminIdentity(&user, nil) {
+			user.Role = "admin"
+		}
+		err = h.db.Create(&user).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been disabled"})
+		return
+	}
+
+	_, refreshToken, err := h.sessionSvc.Create(&user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	setRefreshCookie(c, h.config, refreshToken, h.sessionSvc.RefreshTTL())
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": token.RedirectTo})
+}
+
+// Refresh rotates the refresh cookie and returns a new access token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	raw, err := c.Cookie(refreshCookieName)
+	if err != nil || raw == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not logged in"})
+		return
+	}
+
+	session, next, err := h.sessionSvc.Refresh(raw, c.Request.UserAgent(), c.ClientIP())
+	switch {
+	case errors.Is(err, services.ErrInvalidRefreshToken):
+		clearRefreshCookie(c, h.config)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+		return
+	case errors.Is(err, services.ErrRefreshConflict):
+		// Another tab refreshed at the same time and its response carries the new cookie
+		c.JSON(http.StatusConflict, gin.H{"error": "Session was refreshed by another request, please retry"})
+		return
+	case err != nil:
+		log.Printf("[Session] Failed to refresh session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	accessToken, err := h.sessionSvc.AccessToken(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	setRefreshCookie(c, h.config, next, h.sessionSvc.RefreshTTL())
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      accessToken,
+		"expires_in": h.config.AccessTokenTTLMinutes * 60,
+	})
 }
 
 func (h *AuthHandler) GetMe(c *gin.Context) {
@@ -189,19 +469,146 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		return
 	}
 
+	// Admin permissions of the user's role, so the frontend can show what they may use
+	permissions := []string{}
+	var role models.Role
+	if err := h.db.Where("name = ?", user.Role).First(&role).Error; err == nil {
+		permissions = role.PermissionList()
+	}
+
+	var totpEnabled int64
+	h.db.Model(&models.UserTOTP{}).Where("user_id = ? AND enabled_at IS NOT NULL", user.ID).Count(&totpEnabled)
+
 	c.JSON(http.StatusOK, gin.H{
-		"user": user,
+		"user":        user,
+		"permissions": permissions,
+		"mfa_enabled": totpEnabled > 0,
+		"mfa_pending": c.GetBool("mfa_pending"),
 	})
 }
 
+// UpdateProfile changes the user's display name and email language
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	var req struct {
+		Name     *string `json:"name"`
+		Language *string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be 1-100 characters"})
+			return
+		}
+		updates["name"] = name
+	}
+	if req.Language != nil {
+		if !models.IsSupportedLanguage(*req.Language) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported language"})
+			return
+		}
+		updates["language"] = *req.Language
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.MustGet("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// Logout ends the session of the refresh cookie, which also invalidates its access tokens
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// In a stateless JWT system, logout is handled client-side
-	// The client should delete the JWT token
+	if raw, err := c.Cookie(refreshCookieName); err == nil && raw != "" {
+		if err := h.sessionSvc.RevokeByRefreshToken(raw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+			return
+		}
+	}
+	clearRefreshCookie(c, h.config)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logged out successfully",
 	})
 }
 
+// ListSessions lists the current user's active sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	currentID, _ := c.Get("session_id")
+
+	sessions, err := h.sessionSvc.ListActive(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"created_at":   session.CreatedAt,
+			"current":      session.ID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeSession logs out one of the current user's devices
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	found, err := h.sessionSvc.Revoke(userID.(uuid.UUID), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions logs out every device except the current one
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	currentID, _ := c.Get("session_id")
+
+	revoked, err := h.sessionSvc.RevokeAll(userID.(uuid.UUID), currentID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked successfully", "revoked": revoked})
+}
+
 func (h *AuthHandler) GetGitHubAccounts(c *gin.Context) {
 	userIDValue, exists := c.Get("user_id")
 	if !exists {
@@ -226,8 +633,33 @@ func (h *AuthHandler) GetGitHubAccounts(c *gin.Context) {
 	})
 }
 
-func generateStateToken() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return hex.EncodeToString(b)
+func setRefreshCookie(c *gin.Context, cfg *config.Config, value string, ttl time.Duration) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(refreshCookieName, value, int(ttl.Seconds()), refreshCookiePath, "", strings.HasPrefix(cfg.AppURL, "https://"), true)
+}
+
+func clearRefreshCookie(c *gin.Context, cfg *config.Config) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(refreshCookieName, "", -1, refreshCookiePath, "", strings.HasPrefix(cfg.AppURL, "https://"), true)
+}
+
+// generateMagicLinkToken returns a random token for a login link; only its hash is stored
+func generateMagicLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// safeRedirectPath only lets through paths on the frontend itself, so logins cannot be used as open redirects
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return ""
+	}
+	parsed, err := url.Parse(path)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" {
+		return ""
+	}
+	return path
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// ChatOpsHandler shows the chat-ops channels configured in system settings and tests them
+type ChatOpsHandler struct {
+	db         *gorm.DB
+	chatOpsSvc *services.ChatOpsService
+}
+
+func NewChatOpsHandler(db *gorm.DB, cfg *config.Config) *ChatOpsHandler {
+	return &ChatOpsHandler{
+		db:         db,
+		chatOpsSvc: services.NewChatOpsService(db, cfg),
+	}
+}
+
+func (h *ChatOpsHandler) ListChannels(c *gin.Context) {
+	channels, err := services.ChatOpsChannelConfigs(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat-ops channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channels":         channels,
+		"available_events": services.ChatOpsEvents,
+	})
+}
+
+// TestChannel posts a test message to a channel right away and reports the result
+func (h *ChatOpsHandler) TestChannel(c *gin.Context) {
+	channels, err := services.ChatOpsChannelConfigs(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat-ops channels"})
+		return
+	}
+
+	for i := range channels {
+		if channels[i].Channel != c.Param("channel") {
+			continue
+		}
+		if !channels[i].Configured {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Channel is not configured"})
+			return
+		}
+
+		msg := services.ChatOpsMessage{
+			Event: "test",
+			Title: "Chat-ops test message",
+			Text:  "This channel receives: " + strings.Join(channels[i].Events, ", "),
+			Path:  "/admin",
+		}
+		if err := h.chatOpsSvc.Send(c.Request.Context(), &channels[i], &msg); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Test message sent"})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Unknown channel"})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// CheckoutRecoveryHandler shows the abandoned checkout reminders and how many were recovered
+type CheckoutRecoveryHandler struct {
+	db *gorm.DB
+}
+
+func NewCheckoutRecoveryHandler(db *gorm.DB) *CheckoutRecoveryHandler {
+	return &CheckoutRecoveryHandler{db: db}
+}
+
+// ListRecoveries lists sent reminders, newest first. Filter with status=recovered or status=open.
+func (h *CheckoutRecoveryHandler) ListRecoveries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.CheckoutRecovery{})
+	switch c.Query("status") {
+	case "recovered":
+		query = query.Where("recovered_at IS NOT NULL")
+	case "open":
+		query = query.Where("recovered_at IS NULL")
+	}
+	if pluginID := c.Query("plugin_id"); pluginID != "" {
+		query = query.Where("plugin_id = ?", pluginID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var recoveries []models.CheckoutRecovery
+	if err := query.Preload("Order").Preload("User").Preload("Plugin").
+		Offset((page - 1) * pageSize).Limit(pageSize).Order("sent_at DESC").Find(&recoveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch checkout recoveries"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"recoveries": recoveries,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetStats reports the conversion of reminders sent in the last days (default 30)
+func (h *CheckoutRecoveryHandler) GetStats(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	stats, err := services.GetCheckoutRecoveryStats(h.db, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch checkout recovery stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"days": days, "stats": stats})
+}
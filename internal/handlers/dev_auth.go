@@ -6,19 +6,21 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/models"
-	"github.com/nodeloc/git-store/internal/utils"
+	"github.com/nodeloc/git-store/internal/services"
 	"gorm.io/gorm"
 )
 
 type DevAuthHandler struct {
-	db     *gorm.DB
-	config *config.Config
+	db         *gorm.DB
+	config     *config.Config
+	sessionSvc *services.SessionService
 }
 
 func NewDevAuthHandler(db *gorm.DB, cfg *config.Config) *DevAuthHandler {
 	return &DevAuthHandler{
-		db:     db,
-		config: cfg,
+		db:         db,
+		config:     cfg,
+		sessionSvc: services.NewSessionService(db, cfg),
 	}
 }
 
@@ -38,8 +40,15 @@ func (h *DevAuthHandler) DevLogin(c *gin.Context) {
 		return
 	}
 
-	// 生成 JWT token
-	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role, h.config)
+	// 创建会话并生成 JWT token
+	session, refreshToken, err := h.sessionSvc.Create(&user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	setRefreshCookie(c, h.config, refreshToken, h.sessionSvc.RefreshTTL())
+
+	token, err := h.sessionSvc.AccessToken(session)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// EmailNotificationHandler exposes the email outbox to admins
+type EmailNotificationHandler struct {
+	db       *gorm.DB
+	emailSvc *services.EmailService
+}
+
+func NewEmailNotificationHandler(db *gorm.DB, cfg *config.Config) *EmailNotificationHandler {
+	return &EmailNotificationHandler{
+		db:       db,
+		emailSvc: services.NewEmailService(cfg, db),
+	}
+}
+
+// ListEmailNotifications lists outbox entries, newest first, without their bodies
+func (h *EmailNotificationHandler) ListEmailNotifications(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.EmailNotification{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("notification_type = ?", notificationType)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if email := c.Query("email"); email != "" {
+		query = query.Where("to_email ILIKE ?", "%"+email+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var notifications []models.EmailNotification
+	if err := query.Omit("body").Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch email notifications"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetEmailNotification returns one outbox entry including its rendered body
+func (h *EmailNotificationHandler) GetEmailNotification(c *gin.Context) {
+	var notification models.EmailNotification
+	if err := h.db.First(&notification, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notification": notification})
+}
+
+// ResendEmailNotification queues an email for delivery again, e.g. after fixing SMTP
+// settings or when a customer lost it
+func (h *EmailNotificationHandler) ResendEmailNotification(c *gin.Context) {
+	var notification models.EmailNotification
+	if err := h.db.First(&notification, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email notification not found"})
+		return
+	}
+
+	if notification.Status == models.EmailStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already waiting to be sent"})
+		return
+	}
+
+	before := notification
+	if err := h.emailSvc.Resend(&notification); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend email"})
+		return
+	}
+	logAudit(h.db, c, "email.resend", "email_notification", notification.ID.String(), before, notification)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email queued for delivery", "notification": notification})
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailSuppressionHandler manages the addresses no email is sent to
+type EmailSuppressionHandler struct {
+	db       *gorm.DB
+	config   *config.Config
+	emailSvc *services.EmailService
+}
+
+func NewEmailSuppressionHandler(db *gorm.DB, cfg *config.Config) *EmailSuppressionHandler {
+	return &EmailSuppressionHandler{
+		db:       db,
+		config:   cfg,
+		emailSvc: services.NewEmailService(cfg, db),
+	}
+}
+
+// ListSuppressions lists suppressed addresses, newest first
+func (h *EmailSuppressionHandler) ListSuppressions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.EmailSuppression{})
+	if email := c.Query("email"); email != "" {
+		query = query.Where("email ILIKE ?", "%"+email+"%")
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var suppressions []models.EmailSuppression
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&suppressions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch suppressions"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"suppressions": suppressions,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// CreateSuppression stops all email to an address
+func (h *EmailSuppressionHandler) CreateSuppression(c *gin.Context) {
+	var req struct {
+		Email  string `json:"email" binding:"required,email"`
+		Detail string `json:"detail"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suppression, err := h.emailSvc.Suppress(req.Email, models.SuppressionManual, req.Detail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suppress address"})
+		return
+	}
+	logAudit(h.db, c, "email_suppression.create", "email_suppression", suppression.Email, nil, suppression)
+
+	c.JSON(http.StatusCreated, gin.H{"suppression": suppression})
+}
+
+// DeleteSuppression lets email reach an address again, e.g. after a customer fixed their mailbox
+func (h *EmailSuppressionHandler) DeleteSuppression(c *gin.Context) {
+	var suppression models.EmailSuppression
+	if err := h.db.First(&suppression, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suppression not found"})
+		return
+	}
+
+	if err := h.db.Delete(&suppression).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete suppression"})
+		return
+	}
+	logAudit(h.db, c, "email_suppression.delete", "email_suppression", suppression.Email, suppression, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Suppression removed"})
+}
+
+// bounceWebhookRequest accepts Mailgun's webhook payload as well as a generic
+// {"email", "type", "detail"} body
+type bounceWebhookRequest struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData struct {
+		Event          string `json:"event"`    // failed, complained
+		Severity       string `json:"severity"` // permanent, temporary
+		Recipient      string `json:"recipient"`
+		DeliveryStatus struct {
+			Description string `json:"description"`
+			Message     string `json:"message"`
+		} `json:"delivery-status"`
+	} `json:"event-data"`
+
+	Email  string `json:"email"`
+	Type   string `json:"type"` // hard_bounce, soft_bounce, complaint
+	Detail string `json:"detail"`
+}
+
+// BounceWebhook receives bounces and complaints from the email provider. Hard bounces
+// and complaints suppress the address.
+func (h *EmailSuppressionHandler) BounceWebhook(c *gin.Context) {
+	if h.config.EmailWebhookSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email webhooks are not configured"})
+		return
+	}
+
+	var req bounceWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	var email, detail, mailgunToken string
+	var permanent, complaint bool
+	if req.EventData.Event != "" {
+		if !h.validMailgunSignature(req.Signature.Timestamp, req.Signature.Token, req.Signature.Signature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+		first, err := h.claimMailgunToken(req.Signature.Token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record webhook"})
+			return
+		}
+		if !first {
+			// Already processed; a replayed or retried delivery changes nothing
+			c.JSON(http.StatusOK, gin.H{"received": true})
+			return
+		}
+		mailgunToken = req.Signature.Token
+		email = req.EventData.Recipient
+		permanent = req.EventData.Event == "failed" && req.EventData.Severity == "permanent"
+		complaint = req.EventData.Event == "complained"
+		detail = req.EventData.DeliveryStatus.Description
+		if detail == "" {
+			detail = req.EventData.DeliveryStatus.Message
+		}
+	} else {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !hmac.Equal([]byte(token), []byte(h.config.EmailWebhookSecret)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		email = req.Email
+		permanent = req.Type == "hard_bounce"
+		complaint = req.Type == "complaint"
+		detail = req.Detail
+	}
+
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Recipient is required"})
+		return
+	}
+	if err := h.emailSvc.RecordBounce(email, permanent, complaint, detail); err != nil {
+		if mailgunToken != "" {
+			// Let the provider's retry through
+			h.db.Delete(&models.EmailWebhookToken{}, "token = ?", mailgunToken)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record bounce"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// mailgunSignatureMaxAge is how far a Mailgun webhook's timestamp may be from now
+const mailgunSignatureMaxAge = 5 * time.Minute
+
+// validMailgunSignature checks the HMAC Mailgun signs webhooks with and that the
+// signature is recent
+func (h *EmailSuppressionHandler) validMailgunSignature(timestamp, token, signature string) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || token == "" {
+		return false
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > mailgunSignatureMaxAge || age < -mailgunSignatureMaxAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.config.EmailWebhookSecret))
+	mac.Write([]byte(timestamp + token))
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
+
+// claimMailgunToken remembers a webhook's token and reports whether it was new. Tokens
+// are forgotten once their timestamp could no longer pass validMailgunSignature.
+func (h *EmailSuppressionHandler) claimMailgunToken(token string) (bool, error) {
+	h.db.Where("created_at < ?", time.Now().Add(-2*mailgunSignatureMaxAge)).Delete(&models.EmailWebhookToken{})
+
+	result := h.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.EmailWebhookToken{Token: token})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
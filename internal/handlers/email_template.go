@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// EmailTemplateHandler lets admins edit, preview and test the emails the store sends
+type EmailTemplateHandler struct {
+	db       *gorm.DB
+	emailSvc *services.EmailService
+}
+
+func NewEmailTemplateHandler(db *gorm.DB, cfg *config.Config) *EmailTemplateHandler {
+	return &EmailTemplateHandler{
+		db:       db,
+		emailSvc: services.NewEmailService(cfg, db),
+	}
+}
+
+// emailTemplateRequest holds template changes. Preview and test-send apply them
+// without saving, so admins can try edits first.
+type emailTemplateRequest struct {
+	Subject  *string `json:"subject"`
+	HTMLBody *string `json:"html_body"`
+	TextBody *string `json:"text_body"`
+}
+
+func (r *emailTemplateRequest) apply(tpl *models.EmailTemplate) {
+	if r.Subject != nil {
+		tpl.Subject = *r.Subject
+	}
+	if r.HTMLBody != nil {
+		tpl.HTMLBody = *r.HTMLBody
+	}
+	if r.TextBody != nil {
+		tpl.TextBody = *r.TextBody
+	}
+}
+
+// ListEmailTemplates lists all templates with the variables they can use
+func (h *EmailTemplateHandler) ListEmailTemplates(c *gin.Context) {
+	var templates []models.EmailTemplate
+	if err := h.db.Order("name ASC, language ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch email templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"languages": models.SupportedLanguages,
+		"variables": services.EmailTemplateVariables(),
+	})
+}
+
+func (h *EmailTemplateHandler) GetEmailTemplate(c *gin.Context) {
+	var tpl models.EmailTemplate
+	if err := h.db.First(&tpl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"template": tpl})
+}
+
+// UpdateEmailTemplate saves a template after checking it parses
+func (h *EmailTemplateHandler) UpdateEmailTemplate(c *gin.Context) {
+	var tpl models.EmailTemplate
+	if err := h.db.First(&tpl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return
+	}
+
+	var req emailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := tpl
+	req.apply(&tpl)
+	if err := services.ValidateEmailTemplate(&tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.emailSvc.RenderTemplate(&tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template does not render: " + err.Error()})
+		return
+	}
+
+	if err := h.db.Save(&tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email template"})
+		return
+	}
+	logAudit(h.db, c, "email_template.update", "email_template", tpl.Name+"/"+tpl.Language, before, tpl)
+
+	c.JSON(http.StatusOK, gin.H{"template": tpl})
+}
+
+// ResetEmailTemplate restores the built-in content of a template
+func (h *EmailTemplateHandler) ResetEmailTemplate(c *gin.Context) {
+	var tpl models.EmailTemplate
+	if err := h.db.First(&tpl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return
+	}
+
+	def, ok := services.DefaultEmailTemplate(tpl.Name, tpl.Language)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This template has no built-in version"})
+		return
+	}
+
+	before := tpl
+	tpl.Subject = def.Subject
+	tpl.HTMLBody = def.HTMLBody
+	tpl.TextBody = ""
+	if err := h.db.Save(&tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset email template"})
+		return
+	}
+	logAudit(h.db, c, "email_template.reset", "email_template", tpl.Name+"/"+tpl.Language, before, tpl)
+
+	c.JSON(http.StatusOK, gin.H{"template": tpl})
+}
+
+// PreviewEmailTemplate renders a template, with any unsaved changes, using sample data
+func (h *EmailTemplateHandler) PreviewEmailTemplate(c *gin.Context) {
+	tpl, ok := h.templateWithChanges(c)
+	if !ok {
+		return
+	}
+
+	rendered, err := h.emailSvc.RenderTemplate(tpl)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preview": rendered})
+}
+
+// TestEmailTemplate sends a template, with any unsaved changes, to the admin or
+// the given address
+func (h *EmailTemplateHandler) TestEmailTemplate(c *gin.Context) {
+	var req struct {
+		emailTemplateRequest
+		To string `json:"to"`
+	}
+	var tpl models.EmailTemplate
+	if err := h.db.First(&tpl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.apply(&tpl)
+
+	to := strings.TrimSpace(req.To)
+	if to == "" {
+		to = c.GetString("user_email")
+	}
+	if to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Recipient is required"})
+		return
+	}
+
+	if err := h.emailSvc.SendTestEmail(to, &tpl); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send test email: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test email sent to " + to})
+}
+
+// templateWithChanges loads a template and applies the changes in the request body, if any
+func (h *EmailTemplateHandler) templateWithChanges(c *gin.Context) (*models.EmailTemplate, bool) {
+	var tpl models.EmailTemplate
+	if err := h.db.First(&tpl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return nil, false
+	}
+
+	var req emailTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	req.apply(&tpl)
+	return &tpl, true
+}
@@ -1,25 +1,34 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v57/github"
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
 	"gorm.io/gorm"
 )
 
 type GitHubWebhookHandler struct {
-	db     *gorm.DB
-	config *config.Config
+	db         *gorm.DB
+	config     *config.Config
+	releaseSvc *services.ReleaseService
 }
 
-func NewGitHubWebhookHandler(db *gorm.DB, cfg *config.Config) *GitHubWebhookHandler {
+func NewGitHubWebhookHandler(db *gorm.DB, cfg *config.Config, releaseSvc *services.ReleaseService) *GitHubWebhookHandler {
 	return &GitHubWebhookHandler{
-		db:     db,
-		config: cfg,
+		db:         db,
+		config:     cfg,
+		releaseSvc: releaseSvc,
 	}
 }
 
@@ -48,17 +57,105 @@ func (h *GitHubWebhookHandler) HandleGitHubAppWebhook(c *gin.Context) {
 
 	log.Printf("[GitHub Webhook] Received event: %s", eventType)
 
+	if !h.verifySignature(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
 	switch eventType {
 	case "installation":
 		h.handleInstallationEvent(c)
 	case "installation_repositories":
 		h.handleInstallationRepositoriesEvent(c)
+	case "release":
+		h.handleReleaseEvent(c)
 	default:
 		log.Printf("[GitHub Webhook] Unhandled event type: %s", eventType)
 		c.JSON(http.StatusOK, gin.H{"message": "Event received"})
 	}
 }
 
+// verifySignature checks the X-Hub-Signature-256 header when GITHUB_WEBHOOK_SECRET is
+// set. The body is put back for the event handlers.
+func (h *GitHubWebhookHandler) verifySignature(c *gin.Context) bool {
+	if h.config.GitHubWebhookSecret == "" {
+		return true
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, []byte(h.config.GitHubWebhookSecret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(c.GetHeader("X-Hub-Signature-256")))
+}
+
+// handleReleaseEvent records a published release of a plugin repository, updates
+// Plugin.Version and queues the announcement emails to the plugin's licensees
+func (h *GitHubWebhookHandler) handleReleaseEvent(c *gin.Context) {
+	// Without a verified signature anyone could make the store email every licensee
+	if h.config.GitHubWebhookSecret == "" {
+		log.Printf("[GitHub Webhook] Ignoring release event: GITHUB_WEBHOOK_SECRET is not set")
+		c.JSON(http.StatusOK, gin.H{"message": "Release events require a webhook secret"})
+		return
+	}
+
+	var event github.ReleaseEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		log.Printf("[GitHub Webhook] Failed to parse release event: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	action := event.GetAction()
+	if action != "published" && action != "released" && action != "edited" {
+		c.JSON(http.StatusOK, gin.H{"message": "Release event ignored"})
+		return
+	}
+	r := event.GetRelease()
+	if r == nil || r.GetDraft() || r.GetTagName() == "" {
+		c.JSON(http.StatusOK, gin.H{"message": "Release event ignored"})
+		return
+	}
+
+	// Match by repository ID, or by name for plugins without a stored ID
+	var plugin models.Plugin
+	query := h.db.Where("LOWER(github_repo_name) = LOWER(?)", event.GetRepo().GetFullName())
+	if repoID := event.GetRepo().GetID(); repoID != 0 {
+		query = query.Or("github_repo_id = ?", repoID)
+	}
+	if err := query.First(&plugin).Error; err != nil {
+		log.Printf("[GitHub Webhook] No plugin for repository %s", event.GetRepo().GetFullName())
+		c.JSON(http.StatusOK, gin.H{"message": "Repository is not a plugin"})
+		return
+	}
+
+	release, err := h.releaseSvc.SaveRelease(&plugin, r)
+	if err != nil {
+		log.Printf("[GitHub Webhook] Failed to save release %s of %s: %v", r.GetTagName(), plugin.Slug, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save release"})
+		return
+	}
+
+	// Edits only update the stored notes; prereleases are not announced until released
+	if action == "edited" || release.Prerelease || release.AnnouncedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Release recorded", "version": plugin.Version})
+		return
+	}
+	if err := services.EnqueueReleaseAnnouncement(h.db, release.ID); err != nil {
+		log.Printf("[GitHub Webhook] Failed to queue announcement of %s %s: %v", plugin.Slug, release.TagName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue release announcement"})
+		return
+	}
+
+	log.Printf("[GitHub Webhook] Release %s of %s recorded, announcement queued", release.TagName, plugin.Slug)
+	c.JSON(http.StatusOK, gin.H{"message": "Release recorded", "version": plugin.Version})
+}
+
 func (h *GitHubWebhookHandler) handleInstallationEvent(c *gin.Context) {
 	var event GitHubInstallationEvent
 	if err := c.ShouldBindJSON(&event); err != nil {
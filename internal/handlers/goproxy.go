@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// GoProxyHandler serves licensed plugin repositories over the GOPROXY protocol
+type GoProxyHandler struct {
+	db          *gorm.DB
+	releaseSvc  *services.ReleaseService
+	downloadSvc *services.DownloadService
+}
+
+func NewGoProxyHandler(db *gorm.DB, cfg *config.Config, releaseSvc *services.ReleaseService) *GoProxyHandler {
+	return &GoProxyHandler{
+		db:          db,
+		releaseSvc:  releaseSvc,
+		downloadSvc: services.NewDownloadService(db, cfg),
+	}
+}
+
+type goModuleInfo struct {
+	Version string    `json:"Version"`
+	Time    time.Time `json:"Time"`
+}
+
+// Serve handles /<module>/@v/list, /<module>/@v/<version>.info|.mod|.zip and /<module>/@latest
+func (h *GoProxyHandler) Serve(c *gin.Context) {
+	requestPath := strings.TrimPrefix(c.Param("path"), "/")
+
+	var escapedModule, file string
+	if i := strings.Index(requestPath, "/@v/"); i > 0 {
+		escapedModule, file = requestPath[:i], requestPath[i+len("/@v/"):]
+	} else if strings.HasSuffix(requestPath, "/@latest") {
+		escapedModule, file = strings.TrimSuffix(requestPath, "/@latest"), "@latest"
+	} else {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+
+	modulePath, err := services.UnescapeModulePath(escapedModule)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plugin, major, ok := h.findPlugin(modulePath)
+	if !ok {
+		c.String(http.StatusNotFound, "unknown module %s", modulePath)
+		return
+	}
+
+	license, ok := findPackageLicense(h.db, c, plugin)
+	if !ok {
+		c.String(http.StatusForbidden, "no license for %s", modulePath)
+		return
+	}
+
+	versions, err := h.coveredVersions(plugin, license, major)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to list versions")
+		return
+	}
+
+	switch {
+	case file == "list":
+		var b strings.Builder
+		for _, release := range versions {
+			b.WriteString(release.TagName + "\n")
+		}
+		c.String(http.StatusOK, b.String())
+
+	case file == "@latest":
+		latest := latestVersion(versions)
+		if latest == nil {
+			c.String(http.StatusNotFound, "no versions available")
+			return
+		}
+		c.JSON(http.StatusOK, goModuleInfo{Version: latest.TagName, Time: latest.PublishedAt})
+
+	default:
+		ext := ""
+		for _, candidate := range []string{".info", ".mod", ".zip"} {
+			if strings.HasSuffix(file, candidate) {
+				ext = candidate
+			}
+		}
+		if ext == "" {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+
+		version, err := services.UnescapeModulePath(strings.TrimSuffix(file, ext))
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var release *models.PluginRelease
+		for i := range versions {
+			if versions[i].TagName == version {
+				release = &versions[i]
+				break
+			}
+		}
+		if release == nil {
+			c.String(http.StatusNotFound, "version %s is not available to your license", version)
+			return
+		}
+
+		switch ext {
+		case ".info":
+			c.JSON(http.StatusOK, goModuleInfo{Version: release.TagName, Time: release.PublishedAt})
+		case ".mod":
+			h.serveMod(c, plugin, release, modulePath)
+		case ".zip":
+			h.serveZip(c, plugin, license, release, modulePath)
+		}
+	}
+}
+
+// findPlugin resolves a module path, with or without a /vN suffix, to a plugin
+func (h *GoProxyHandler) findPlugin(modulePath string) (*models.Plugin, int, bool) {
+	var plugins []models.Plugin
+	if err := h.db.Where("github_repo_name <> ''").Find(&plugins).Error; err != nil {
+		return nil, 0, false
+	}
+
+	base, major := services.SplitModuleMajor(modulePath)
+	for i := range plugins {
+		path := services.GoModulePath(&plugins[i])
+		if strings.EqualFold(path, modulePath) {
+			_, pathMajor := services.SplitModuleMajor(path)
+			return &plugins[i], pathMajor, true
+		}
+		if major > 1 && strings.EqualFold(path, base) {
+			return &plugins[i], major, true
+		}
+	}
+	return nil, 0, false
+}
+
+// coveredVersions lists the semver releases of the major version that the license covers
+func (h *GoProxyHandler) coveredVersions(plugin *models.Plugin, license *models.License, major int) ([]models.PluginRelease, error) {
+	var releases []models.PluginRelease
+	if err := h.db.Where("plugin_id = ?", plugin.ID).Order("published_at DESC").Find(&releases).Error; err != nil {
+		return nil, err
+	}
+
+	versions := make([]models.PluginRelease, 0, len(releases))
+	for _, release := range releases {
+		if services.GoModuleVersion(release.TagName, major) && license.CoversRelease(&release) {
+			versions = append(versions, release)
+		}
+	}
+	return versions, nil
+}
+
+// latestVersion picks the newest stable version, falling back to the newest pre-release
+func latestVersion(versions []models.PluginRelease) *models.PluginRelease {
+	for i := range versions {
+		if !versions[i].Prerelease && !strings.Contains(versions[i].TagName, "-") {
+			return &versions[i]
+		}
+	}
+	if len(versions) > 0 {
+		return &versions[0]
+	}
+	return nil
+}
+
+func (h *GoProxyHandler) serveMod(c *gin.Context, plugin *models.Plugin, release *models.PluginRelease, modulePath string) {
+	content, err := h.releaseSvc.GetFile(c.Request.Context(), plugin, release, "go.mod")
+	if errors.Is(err, services.ErrFileNotFound) {
+		// Modules without a go.mod are served with a synthesized one, like the public proxy does
+		c.String(http.StatusOK, "module %s\n", modulePath)
+		return
+	}
+	if err != nil {
+		log.Printf("[GoProxy] Failed to fetch go.mod of %s@%s: %v", modulePath, release.TagName, err)
+		c.String(http.StatusBadGateway, "failed to fetch go.mod")
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", content)
+}
+
+func (h *GoProxyHandler) serveZip(c *gin.Context, plugin *models.Plugin, license *models.License, release *models.PluginRelease, modulePath string) {
+	body, _, err := h.releaseSvc.OpenArchive(c.Request.Context(), plugin, release, "zip")
+	if err != nil {
+		log.Printf("[GoProxy] Failed to fetch %s@%s: %v", modulePath, release.TagName, err)
+		c.String(http.StatusBadGateway, "failed to fetch module source")
+		return
+	}
+	defer body.Close()
+
+	// zip needs random access, so spool the GitHub archive to disk first
+	tmp, err := os.CreateTemp("", "goproxy-*.zip")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to prepare module zip")
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		log.Printf("[GoProxy] Failed to download %s@%s: %v", modulePath, release.TagName, err)
+		c.String(http.StatusBadGateway, "failed to fetch module source")
+		return
+	}
+
+	download := &models.ReleaseDownload{
+		LicenseID: license.ID,
+		ReleaseID: release.ID,
+		UserID:    license.UserID,
+		Format:    "gomod",
+		Via:       "goproxy",
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err := h.downloadSvc.Record(download, plugin.ID); err != nil {
+		log.Printf("[GoProxy] Failed to record download of %s@%s: %v", modulePath, release.TagName, err)
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, release.TagName))
+	c.Status(http.StatusOK)
+	if err := services.WriteModuleZip(c.Writer, tmp, modulePath, release.TagName); err != nil {
+		log.Printf("[GoProxy] Failed to write module zip for %s@%s: %v", modulePath, release.TagName, err)
+	}
+}
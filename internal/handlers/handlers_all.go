@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,7 +8,6 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +15,6 @@ import (
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/models"
 	"github.com/nodeloc/git-store/internal/services"
-	"github.com/nodeloc/git-store/internal/utils"
 	"github.com/stripe/stripe-go/v76"
 	"gorm.io/gorm"
 )
@@ -176,6 +173,9 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
+	if err := services.ExpireCheckoutDiscount(h.db, &order); err != nil {
+		log.Printf("Failed to check checkout discount of order %s: %v", order.ID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"order": order})
 }
@@ -194,10 +194,11 @@ func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 
 // PaymentHandler handles payment-related requests
 type PaymentHandler struct {
-	db            *gorm.DB
-	config        *config.Config
-	alipayService *services.AlipayService
-	stripeService *services.StripeService
+	db                 *gorm.DB
+	config             *config.Config
+	alipayService      *services.AlipayService
+	stripeService      *services.StripeService
+	fulfillmentService *services.FulfillmentService
 }
 
 func NewPaymentHandler(db *gorm.DB, cfg *config.Config) *PaymentHandler {
@@ -211,10 +212,11 @@ func NewPaymentHandler(db *gorm.DB, cfg *config.Config) *PaymentHandler {
 	stripeService := services.NewStripeService(cfg)
 
 	return &PaymentHandler{
-		db:            db,
-		config:        cfg,
-		alipayService: alipayService,
-		stripeService: stripeService,
+		db:                 db,
+		config:             cfg,
+		alipayService:      alipayService,
+		stripeService:      stripeService,
+		fulfillmentService: services.NewFulfillmentService(db, cfg),
 	}
 }
 
@@ -242,6 +244,13 @@ func (h *PaymentHandler) CreateStripePaymentIntent(c *gin.Context) {
 		return
 	}
 
+	// A reminder discount that ran out no longer applies
+	if err := services.ExpireCheckoutDiscount(h.db, &order); err != nil {
+		log.Printf("Failed to check checkout discount of order %s: %v", order.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order"})
+		return
+	}
+
 	// Check if Stripe service is available
 	if h.stripeService == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -330,6 +339,13 @@ func (h *PaymentHandler) CreateAlipayPayment(c *gin.Context) {
 		return
 	}
 
+	// A reminder discount that ran out no longer applies
+	if err := services.ExpireCheckoutDiscount(h.db, &order); err != nil {
+		log.Printf("Failed to check checkout discount of order %s: %v", order.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order"})
+		return
+	}
+
 	// 检查易支付服务是否可用
 	if h.alipayService == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
@@ -482,67 +498,23 @@ func (h *PaymentHandler) StripeWebhook(c *gin.Context) {
 			return
 		}
 
-		// Update order status
+		// Mark the order paid and create the license in one transaction
+		now := time.Now()
 		order.PaymentStatus = "paid"
 		order.PaymentMethod = "stripe"
 		order.PaymentTransactionID = paymentIntent.ID
-		if err := h.db.Save(&order).Error; err != nil {
-			log.Printf("Failed to update order: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
-			return
-		}
-
-		// Get user's first GitHub account
-		var githubAccount models.GitHubAccount
-		if err := h.db.Where("user_id = ?", order.UserID).First(&githubAccount).Error; err != nil {
-			log.Printf("Failed to find GitHub account for user: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "GitHub account not found"})
-			return
-		}
-
-		// Calculate maintenance expiry
-		maintenanceMonths := order.Plugin.DefaultMaintenanceMonths
-		if maintenanceMonths == 0 {
-			maintenanceMonths = 12
-		}
-		maintenanceUntil := time.Now().AddDate(0, maintenanceMonths, 0)
-
-		// Create or update license (use FirstOrCreate to handle duplicates)
-		license := models.License{
-			UserID:   order.UserID,
-			PluginID: order.PluginID,
-		}
-
-		// Try to find existing license first
-		var existingLicense models.License
-		err = h.db.Where("user_id = ? AND plugin_id = ?", order.UserID, order.PluginID).First(&existingLicense).Error
-		if err == nil {
-			// License exists, update it
-			existingLicense.OrderID = order.ID
-			existingLicense.GitHubAccountID = githubAccount.ID
-			existingLicense.LicenseType = "permanent"
-			existingLicense.Status = "active"
-			existingLicense.MaintenanceUntil = maintenanceUntil
-			if err := h.db.Save(&existingLicense).Error; err != nil {
-				log.Printf("Failed to update license: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update license"})
-				return
+		order.PaidAt = &now
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&order).Error; err != nil {
+				return err
 			}
-			log.Printf("Successfully updated existing license: %s", existingLicense.ID)
-		} else {
-			// License doesn't exist, create new one
-			license.OrderID = order.ID
-			license.GitHubAccountID = githubAccount.ID
-			license.LicenseType = "permanent"
-			license.Status = "active"
-			license.MaintenanceUntil = maintenanceUntil
-
-			if err := h.db.Create(&license).Error; err != nil {
-				log.Printf("Failed to create license: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create license"})
-				return
-			}
-			log.Printf("Successfully created new license: %s", license.ID)
+			_, err := h.fulfillmentService.FulfillOrder(tx, &order)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to fulfill order: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fulfill order"})
+			return
 		}
 
 		log.Printf("Successfully processed Stripe payment for order: %s", orderIDStr)
@@ -653,67 +625,26 @@ func (h *PaymentHandler) AlipayNotify(c *gin.Context) {
 			return
 		}
 
-		// 更新订单状态
+		// 更新订单状态并创建许可证（同一事务）
+		now := time.Now()
 		order.PaymentStatus = "paid"
 		order.PaymentMethod = "alipay"
-		if err := h.db.Save(&order).Error; err != nil {
-			log.Printf("❌ Failed to update order: %v", err)
-			c.String(http.StatusInternalServerError, "fail")
-			return
-		}
-
-		log.Printf("✅ Order status updated to paid: %s", outTradeNo)
-
-		// 获取用户的第一个GitHub账户
-		var githubAccount models.GitHubAccount
-		if err := h.db.Where("user_id = ?", order.UserID).First(&githubAccount).Error; err != nil {
-			log.Printf("❌ Failed to find GitHub account for user: %v", err)
+		order.PaymentTransactionID = tradeNo
+		order.PaidAt = &now
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&order).Error; err != nil {
+				return err
+			}
+			_, err := h.fulfillmentService.FulfillOrder(tx, &order)
+			return err
+		})
+		if err != nil {
+			log.Printf("❌ Failed to fulfill order %s: %v", outTradeNo, err)
 			c.String(http.StatusInternalServerError, "fail")
 			return
 		}
 
-		// 计算维护到期时间（默认12个月）
-		maintenanceMonths := order.Plugin.DefaultMaintenanceMonths
-		if maintenanceMonths == 0 {
-			maintenanceMonths = 12
-		}
-		maintenanceUntil := utils.CalculateMaintenanceUntil(maintenanceMonths)
-
-		// Create or update license (handle duplicates)
-		var existingLicense models.License
-		err = h.db.Where("user_id = ? AND plugin_id = ?", order.UserID, order.PluginID).First(&existingLicense).Error
-		if err == nil {
-			// License exists, update it
-			existingLicense.OrderID = order.ID
-			existingLicense.GitHubAccountID = githubAccount.ID
-			existingLicense.LicenseType = "permanent"
-			existingLicense.Status = "active"
-			existingLicense.MaintenanceUntil = maintenanceUntil
-			if err := h.db.Save(&existingLicense).Error; err != nil {
-				log.Printf("❌ Failed to update license: %v", err)
-				c.String(http.StatusInternalServerError, "fail")
-				return
-			}
-			log.Printf("✅ License updated successfully for order: %s", outTradeNo)
-		} else {
-			// License doesn't exist, create new one
-			license := models.License{
-				UserID:           order.UserID,
-				PluginID:         order.PluginID,
-				OrderID:          order.ID,
-				GitHubAccountID:  githubAccount.ID,
-				LicenseType:      "permanent",
-				MaintenanceUntil: maintenanceUntil,
-				Status:           "active",
-			}
-
-			if err := h.db.Create(&license).Error; err != nil {
-				log.Printf("❌ Failed to create license: %v", err)
-				c.String(http.StatusInternalServerError, "fail")
-				return
-			}
-			log.Printf("🎉 License created successfully for order: %s", outTradeNo)
-		}
+		log.Printf("🎉 License fulfilled for order: %s", outTradeNo)
 		log.Printf("✅ Payment successful - Order: %s, TradeNo: %s", outTradeNo, tradeNo)
 		c.String(http.StatusOK, "success")
 		return
@@ -798,8 +729,8 @@ func (h *LicenseHandler) VerifyLicense(c *gin.Context) {
 		return
 	}
 
-	// Check if license is active
-	if license.Status != "active" {
+	// Check if license is active (a license in its grace period keeps full access)
+	if license.Status != "active" && license.Status != "grace" {
 		c.JSON(http.StatusOK, gin.H{
 			"valid":   false,
 			"status":  license.Status,
@@ -833,6 +764,8 @@ func (h *LicenseHandler) VerifyLicense(c *gin.Context) {
 		"github_username":    license.GitHubAccount.Login,
 		"maintenance_until":  license.MaintenanceUntil,
 		"maintenance_active": !maintenanceExpired,
+		"in_grace_period":    license.Status == "grace",
+		"grace_until":        license.GraceEndsAt(),
 		"created_at":         license.CreatedAt,
 	})
 }
@@ -877,16 +810,22 @@ func (h *TutorialHandler) GetTutorial(c *gin.Context) {
 
 // AdminHandler handles admin-related requests
 type AdminHandler struct {
-	db        *gorm.DB
-	config    *config.Config
-	githubSvc *services.GitHubService
+	db                 *gorm.DB
+	config             *config.Config
+	githubSvc          *services.GitHubService
+	fulfillmentService *services.FulfillmentService
+	sessionSvc         *services.SessionService
+	emailSvc           *services.EmailService
 }
 
 func NewAdminHandler(db *gorm.DB, cfg *config.Config, githubSvc *services.GitHubService) *AdminHandler {
 	return &AdminHandler{
-		db:        db,
-		config:    cfg,
-		githubSvc: githubSvc,
+		db:                 db,
+		config:             cfg,
+		githubSvc:          githubSvc,
+		fulfillmentService: services.NewFulfillmentService(db, cfg),
+		sessionSvc:         services.NewSessionService(db, cfg),
+		emailSvc:           services.NewEmailService(cfg, db),
 	}
 }
 
@@ -949,6 +888,11 @@ func (h *AdminHandler) CreatePlugin(c *gin.Context) {
 		Price                    float64  `json:"price"`
 		Currency                 string   `json:"currency"`
 		DefaultMaintenanceMonths int      `json:"default_maintenance_months"`
+		GracePeriodDays          *int     `json:"grace_period_days"`
+		CollaboratorPermission   string   `json:"collaborator_permission"`
+		GoModulePath             string   `json:"go_module_path"`
+		NpmPackage               string   `json:"npm_package"`
+		ComposerPackage          string   `json:"composer_package"`
 		Status                   string   `json:"status"`
 		Category                 string   `json:"category"`
 		Tags                     []string `json:"tags"`
@@ -962,6 +906,10 @@ func (h *AdminHandler) CreatePlugin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateAccessPolicy(req.GracePeriodDays, req.CollaboratorPermission); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Set default values for required GitHub fields if not provided
 	if req.GitHubRepoID == 0 {
@@ -985,6 +933,11 @@ func (h *AdminHandler) CreatePlugin(c *gin.Context) {
 		Price:                    req.Price,
 		Currency:                 req.Currency,
 		DefaultMaintenanceMonths: req.DefaultMaintenanceMonths,
+		GracePeriodDays:          h.config.DefaultGracePeriodDays,
+		CollaboratorPermission:   req.CollaboratorPermission,
+		GoModulePath:             req.GoModulePath,
+		NpmPackage:               req.NpmPackage,
+		ComposerPackage:          req.ComposerPackage,
 		Status:                   req.Status,
 		Category:                 req.Category,
 		Tags:                     req.Tags,
@@ -1003,8 +956,20 @@ func (h *AdminHandler) CreatePlugin(c *gin.Context) {
 	if plugin.DefaultMaintenanceMonths == 0 {
 		plugin.DefaultMaintenanceMonths = h.config.DefaultMaintenanceMonths
 	}
+	if req.GracePeriodDays != nil {
+		plugin.GracePeriodDays = *req.GracePeriodDays
+	}
+	if plugin.CollaboratorPermission == "" {
+		plugin.CollaboratorPermission = "pull"
+	}
 
-	if err := h.db.Create(&plugin).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&plugin).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, "plugin.create", "plugin", plugin.ID.String(), nil, plugin)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create plugin"})
 		return
 	}
@@ -1012,6 +977,18 @@ func (h *AdminHandler) CreatePlugin(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"plugin": plugin})
 }
 
+// validateAccessPolicy checks the repository access settings of a plugin request
+func validateAccessPolicy(gracePeriodDays *int, permission string) error {
+	if gracePeriodDays != nil && *gracePeriodDays < 0 {
+		return errors.New("grace_period_days must not be negative")
+	}
+	switch permission {
+	case "", "pull", "triage", "push", "maintain", "admin":
+		return nil
+	}
+	return fmt.Errorf("invalid collaborator_permission: %s", permission)
+}
+
 func (h *AdminHandler) GetPluginByID(c *gin.Context) {
 	id := c.Param("id")
 
@@ -1048,6 +1025,11 @@ func (h *AdminHandler) UpdatePlugin(c *gin.Context) {
 		Price                    float64  `json:"price"`
 		Currency                 string   `json:"currency"`
 		DefaultMaintenanceMonths int      `json:"default_maintenance_months"`
+		GracePeriodDays          *int     `json:"grace_period_days"`
+		CollaboratorPermission   string   `json:"collaborator_permission"`
+		GoModulePath             string   `json:"go_module_path"`
+		NpmPackage               string   `json:"npm_package"`
+		ComposerPackage          string   `json:"composer_package"`
 		Status                   string   `json:"status"`
 		Category                 string   `json:"category"`
 		Tags                     []string `json:"tags"`
@@ -1061,6 +1043,10 @@ func (h *AdminHandler) UpdatePlugin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateAccessPolicy(req.GracePeriodDays, req.CollaboratorPermission); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	updates := map[string]interface{}{
 		"name":                       req.Name,
@@ -1080,14 +1066,31 @@ func (h *AdminHandler) UpdatePlugin(c *gin.Context) {
 		"demo_url":                   req.DemoURL,
 		"documentation_url":          req.DocumentationURL,
 		"version":                    req.Version,
+		"go_module_path":             req.GoModulePath,
+		"npm_package":                req.NpmPackage,
+		"composer_package":           req.ComposerPackage,
+	}
+	if req.GracePeriodDays != nil {
+		updates["grace_period_days"] = *req.GracePeriodDays
+	}
+	if req.CollaboratorPermission != "" {
+		updates["collaborator_permission"] = req.CollaboratorPermission
 	}
 
-	if err := h.db.Model(&plugin).Updates(updates).Error; err != nil {
+	before := plugin
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&plugin).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&plugin, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, "plugin.update", "plugin", plugin.ID.String(), before, plugin)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plugin"})
 		return
 	}
-
-	h.db.First(&plugin, "id = ?", id)
 	c.JSON(http.StatusOK, gin.H{"plugin": plugin})
 }
 
@@ -1107,7 +1110,13 @@ func (h *AdminHandler) DeletePlugin(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&plugin).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&plugin).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, "plugin.delete", "plugin", plugin.ID.String(), plugin, nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete plugin"})
 		return
 	}
@@ -1325,102 +1334,39 @@ func (h *AdminHandler) UpdateOrderPaymentStatus(c *gin.Context) {
 		updates["refunded_at"] = &now
 	}
 
-	if err := h.db.Model(&order).Updates(updates).Error; err != nil {
-		log.Printf("Failed to update order payment status: %v", err)
+	before := order
+	var license *models.License
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&order).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, "order.update_status", "order", order.ID.String(), before, order); err != nil {
+			return err
+		}
+
+		// If status is paid, create license if it doesn't exist
+		if req.PaymentStatus != "paid" {
+			return nil
+		}
+		var existingLicense models.License
+		err := tx.Where("order_id = ?", order.ID).First(&existingLicense).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		license, err = h.fulfillmentService.FulfillOrder(tx, &order)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to update order payment status for order %s: %v", order.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order payment status"})
 		return
 	}
 
-	log.Printf("[License Debug] After update - req.PaymentStatus=%s, order.ID=%s", req.PaymentStatus, order.ID)
-
-	// If status is paid, create license if it doesn't exist
-	if req.PaymentStatus == "paid" {
-		log.Printf("[License Debug] Payment status is paid, checking for existing license")
-		// Check if license already exists
-		var existingLicense models.License
-		err := h.db.Where("order_id = ?", order.ID).First(&existingLicense).Error
-		log.Printf("[License Debug] License query result - err: %v", err)
-		if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-			// License doesn't exist, create one
-
-			// Get user's GitHub account (required)
-			var githubAccount models.GitHubAccount
-			if err := h.db.Where("user_id = ?", order.UserID).First(&githubAccount).Error; err != nil {
-				log.Printf("Cannot create license: user %s has no GitHub account: %v", order.UserID, err)
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error":           "Cannot create license: user must login with GitHub first to link their account",
-					"order_updated":   true,
-					"license_created": false,
-				})
-				return
-			}
-
-			// Load plugin to get maintenance months
-			var plugin models.Plugin
-			if err := h.db.Where("id = ?", order.PluginID).First(&plugin).Error; err != nil {
-				log.Printf("Failed to find plugin %s: %v", order.PluginID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Order updated but failed to find plugin"})
-				return
-			}
-
-			// Calculate maintenance expiry
-			maintenanceMonths := plugin.DefaultMaintenanceMonths
-			if maintenanceMonths == 0 {
-				maintenanceMonths = 12
-			}
-			maintenanceUntil := time.Now().AddDate(0, maintenanceMonths, 0)
-
-			// Create license
-			license := models.License{
-				OrderID:          order.ID,
-				UserID:           order.UserID,
-				PluginID:         order.PluginID,
-				GitHubAccountID:  githubAccount.ID,
-				LicenseType:      "permanent",
-				Status:           "active",
-				MaintenanceUntil: maintenanceUntil,
-			}
-
-			if err := h.db.Create(&license).Error; err != nil {
-				log.Printf("Failed to create license for order %s: %v", order.ID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Order updated but failed to create license"})
-				return
-			}
-
-			log.Printf("Successfully created license for order %s", order.ID)
-
-			// Grant repository access via collaborator invitation
-			if h.githubSvc != nil && plugin.GitHubRepoName != "" && githubAccount.Login != "" {
-				ctx := context.Background()
-
-				// Split repo name into owner/repo
-				repoParts := strings.Split(plugin.GitHubRepoName, "/")
-				if len(repoParts) != 2 {
-					log.Printf("[Repository Access] Invalid repo name format: %s", plugin.GitHubRepoName)
-				} else {
-					owner, repo := repoParts[0], repoParts[1]
-
-					log.Printf("[Repository Access] Inviting %s as collaborator to %s with pull permission",
-						githubAccount.Login, plugin.GitHubRepoName)
-
-					err := h.githubSvc.AddRepositoryCollaborator(ctx, owner, repo, githubAccount.Login, "pull")
-					if err != nil {
-						log.Printf("[Repository Access] Warning: Failed to add collaborator: %v", err)
-					} else {
-						log.Printf("[Repository Access] Successfully invited %s as collaborator to %s",
-							githubAccount.Login, plugin.GitHubRepoName)
-
-						// Log the action
-						history := models.LicenseHistory{
-							LicenseID:  license.ID,
-							Action:     "collaborator_invited",
-							OccurredAt: time.Now(),
-						}
-						h.db.Create(&history)
-					}
-				}
-			}
-		}
+	if license != nil {
+		log.Printf("Successfully created license %s for order %s", license.ID, order.ID)
 	}
 
 	// Reload order with associations
@@ -1446,21 +1392,67 @@ func (h *AdminHandler) RefundOrder(c *gin.Context) {
 		return
 	}
 
+	before := order
 	now := time.Now()
 	order.PaymentStatus = "refunded"
 	order.RefundedAt = &now
 
-	if err := h.db.Save(&order).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, "order.refund", "order", order.ID.String(), before, order); err != nil {
+			return err
+		}
+
+		var user models.User
+		var plugin models.Plugin
+		if err := tx.First(&user, "id = ?", order.UserID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&plugin, "id = ?", order.PluginID).Error; err != nil {
+			return err
+		}
+		if err := services.EmitWebhookEvent(tx, models.WebhookEventRefundCreated, services.OrderWebhookData(&order, &user, &plugin)); err != nil {
+			return err
+		}
+		if err := services.NotifyChatOps(tx, services.ChatOpsOrderMessage(services.ChatOpsRefundCreated, &order, &user, &plugin)); err != nil {
+			return err
+		}
+
+		var licenses []models.License
+		if err := tx.Where("order_id = ? AND status <> ?", order.ID, "revoked").Find(&licenses).Error; err != nil {
+			return err
+		}
+		for _, license := range licenses {
+			if err := tx.Model(&license).Updates(map[string]interface{}{
+				"status":         "revoked",
+				"revoked_reason": "Order refunded",
+				"revoked_at":     now,
+			}).Error; err != nil {
+				return err
+			}
+			if err := services.EnqueueAccessRevocation(tx, license.ID); err != nil {
+				return err
+			}
+			if err := services.EmitWebhookEvent(tx, models.WebhookEventLicenseRevoked, services.LicenseWebhookData(&license, &user, &plugin)); err != nil {
+				return err
+			}
+		}
+
+		if err := h.emailSvc.QueueOrderRefundedEmail(tx, &user, &plugin, &order); err != nil {
+			return err
+		}
+		return services.Notify(tx, &user, models.NotificationTypeOrderRefunded, "/orders", map[string]interface{}{
+			"order_number": order.OrderNumber,
+			"plugin":       plugin.Name,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
 		return
 	}
 
-	h.db.Model(&models.License{}).Where("order_id = ?", order.ID).Updates(map[string]interface{}{
-		"status":         "revoked",
-		"revoked_reason": "Order refunded",
-		"revoked_at":     now,
-	})
-
 	c.JSON(http.StatusOK, gin.H{"message": "Order refunded successfully", "order": order})
 }
 
@@ -1547,25 +1539,51 @@ func (h *AdminHandler) RevokeLicense(c *gin.Context) {
 		return
 	}
 
+	before := license
 	now := time.Now()
 	license.Status = "revoked"
 	license.RevokedReason = req.Reason
 	license.RevokedAt = &now
 
-	if err := h.db.Save(&license).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&license).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, "license.revoke", "license", license.ID.String(), before, license); err != nil {
+			return err
+		}
+
+		history := models.LicenseHistory{
+			LicenseID:   license.ID,
+			Action:      "revoked",
+			PerformedBy: &adminUserID,
+			Metadata:    fmt.Sprintf(`{"reason": "%s"}`, req.Reason),
+			OccurredAt:  now,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		var plugin models.Plugin
+		if err := tx.First(&user, "id = ?", license.UserID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&plugin, "id = ?", license.PluginID).Error; err != nil {
+			return err
+		}
+		if err := services.EmitWebhookEvent(tx, models.WebhookEventLicenseRevoked, services.LicenseWebhookData(&license, &user, &plugin)); err != nil {
+			return err
+		}
+
+		// Revocation is the only path that removes the collaborator from the repository
+		return services.EnqueueAccessRevocation(tx, license.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke license"})
 		return
 	}
 
-	history := models.LicenseHistory{
-		LicenseID:   license.ID,
-		Action:      "revoked",
-		PerformedBy: &adminUserID,
-		Metadata:    fmt.Sprintf(`{"reason": "%s"}`, req.Reason),
-		OccurredAt:  now,
-	}
-	h.db.Create(&history)
-
 	c.JSON(http.StatusOK, gin.H{"message": "License revoked successfully", "license": license})
 }
 
@@ -1589,30 +1607,48 @@ func (h *AdminHandler) ExtendLicense(c *gin.Context) {
 		return
 	}
 
+	before := license
 	baseDate := license.MaintenanceUntil
 	if time.Now().After(baseDate) {
 		baseDate = time.Now()
 	}
 	license.MaintenanceUntil = baseDate.AddDate(0, req.Months, 0)
 
-	if license.Status == "expired" {
+	// Expired licenses were downgraded to read-only and need their full permission back
+	restoreAccess := license.Status == "expired"
+	if license.Status == "expired" || license.Status == "grace" {
 		license.Status = "active"
 	}
 
-	if err := h.db.Save(&license).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&license).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, c, "license.extend", "license", license.ID.String(), before, license); err != nil {
+			return err
+		}
+
+		history := models.LicenseHistory{
+			LicenseID:   license.ID,
+			Action:      "renewed",
+			PerformedBy: &adminUserID,
+			Metadata:    fmt.Sprintf(`{"months": %d, "new_maintenance_until": "%s"}`, req.Months, license.MaintenanceUntil.Format("2006-01-02")),
+			OccurredAt:  time.Now(),
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+
+		if restoreAccess {
+			return services.EnqueueAccessGrant(tx, license.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend license"})
 		return
 	}
 
-	history := models.LicenseHistory{
-		LicenseID:   license.ID,
-		Action:      "renewed",
-		PerformedBy: &adminUserID,
-		Metadata:    fmt.Sprintf(`{"months": %d, "new_maintenance_until": "%s"}`, req.Months, license.MaintenanceUntil.Format("2006-01-02")),
-		OccurredAt:  time.Now(),
-	}
-	h.db.Create(&history)
-
 	c.JSON(http.StatusOK, gin.H{"message": "License extended successfully", "license": license})
 }
 
@@ -1796,7 +1832,7 @@ func (h *AdminHandler) UpdateSettings(c *gin.Context) {
 	var req struct {
 		Settings []struct {
 			Key   string `json:"key" binding:"required"`
-			Value string `json:"value" binding:"required"`
+			Value string `json:"value"` // may be empty, e.g. to turn off a chat-ops channel
 		} `json:"settings" binding:"required"`
 	}
 
@@ -1805,14 +1841,32 @@ func (h *AdminHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
-	for _, setting := range req.Settings {
-		if err := h.db.Model(&models.SystemSetting{}).Where("key = ?", setting.Key).Updates(map[string]interface{}{
-			"value":      setting.Value,
-			"updated_at": time.Now(),
-		}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update setting: " + setting.Key})
-			return
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for _, setting := range req.Settings {
+			var current models.SystemSetting
+			if err := tx.Where("key = ?", setting.Key).First(&current).Error; err != nil {
+				return fmt.Errorf("%s: %w", setting.Key, err)
+			}
+			if current.Value == setting.Value {
+				continue
+			}
+
+			if err := tx.Model(&current).Updates(map[string]interface{}{
+				"value":      setting.Value,
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+				return fmt.Errorf("%s: %w", setting.Key, err)
+			}
+			if err := recordAudit(tx, c, "setting.update", "setting", setting.Key,
+				gin.H{"value": current.Value}, gin.H{"value": setting.Value}); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update setting: " + err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Settings updated successfully"})
@@ -1911,26 +1965,72 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if !h.canManageUser(c, &user) {
+		return
+	}
+
 	updates := make(map[string]interface{})
 	if req.Name != "" {
 		updates["name"] = req.Name
 	}
-	if req.Role != "" {
+	if req.Role != "" && req.Role != user.Role {
+		userIDValue, _ := c.Get("user_id")
+		if user.ID == userIDValue.(uuid.UUID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change your own role"})
+			return
+		}
+
+		var role models.Role
+		if err := h.db.Where("name = ?", req.Role).First(&role).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + req.Role})
+			return
+		}
+		if !callerRole(c).Covers(&role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot assign a role with permissions you do not have"})
+			return
+		}
 		updates["role"] = req.Role
 	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
 
-	if err := h.db.Model(&user).Updates(updates).Error; err != nil {
+	before := user
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, "user.update", "user", user.ID.String(), before, user)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	// Deactivated users are logged out of every device
+	if req.IsActive != nil && !*req.IsActive {
+		if _, err := h.sessionSvc.RevokeAll(user.ID, uuid.Nil); err != nil {
+			log.Printf("[Admin] Failed to revoke sessions of user %s: %v", user.ID, err)
+		}
+	}
+
 	h.db.First(&user, "id = ?", id)
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
+// canManageUser refuses changes to users whose role grants permissions the caller lacks
+func (h *AdminHandler) canManageUser(c *gin.Context, user *models.User) bool {
+	var role models.Role
+	if err := h.db.Where("name = ?", user.Role).First(&role).Error; err != nil {
+		return true
+	}
+	if !callerRole(c).Covers(&role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage a user with more permissions than you"})
+		return false
+	}
+	return true
+}
+
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 	userIDValue, _ := c.Get("user_id")
@@ -1947,11 +2047,26 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Model(&user).Update("is_active", false).Error; err != nil {
+	if !h.canManageUser(c, &user) {
+		return
+	}
+
+	before := user
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, "user.delete", "user", user.ID.String(), before, user)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user"})
 		return
 	}
 
+	if _, err := h.sessionSvc.RevokeAll(user.ID, uuid.Nil); err != nil {
+		log.Printf("[Admin] Failed to revoke sessions of user %s: %v", user.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
 }
 
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/jobs"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+type JobHandler struct {
+	db *gorm.DB
+}
+
+func NewJobHandler(db *gorm.DB) *JobHandler {
+	return &JobHandler{db: db}
+}
+
+// ListJobs lists background jobs, filterable by status and type (e.g. ?status=dead)
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	status := c.Query("status")
+	jobType := c.Query("type")
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var jobList []models.Job
+	var total int64
+
+	query := h.db.Model(&models.Job{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	query.Count(&total)
+
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&jobList).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs": jobList,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetJobStats returns job counts grouped by type and status
+func (h *JobHandler) GetJobStats(c *gin.Context) {
+	type JobStat struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+
+	var results []JobStat
+	if err := h.db.Model(&models.Job{}).
+		Select("type, status, COUNT(*) as count").
+		Group("type, status").
+		Order("type ASC, status ASC").
+		Scan(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": results})
+}
+
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id := c.Param("id")
+
+	var job models.Job
+	if err := h.db.First(&job, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// RetryJob re-queues a dead or cancelled job
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	h.transition(c, jobs.Retry, "Job queued for retry")
+}
+
+// CancelJob stops a pending job from running
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	h.transition(c, jobs.Cancel, "Job cancelled")
+}
+
+func (h *JobHandler) transition(c *gin.Context, fn func(*gorm.DB, uuid.UUID) (*models.Job, error), message string) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := fn(h.db, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "job": job})
+}
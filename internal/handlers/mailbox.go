@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/services"
+)
+
+// MailboxHandler shows the emails written by the mailbox transport (EMAIL_TRANSPORT=mailbox)
+type MailboxHandler struct {
+	mailbox *services.Mailbox
+}
+
+func NewMailboxHandler(cfg *config.Config) *MailboxHandler {
+	h := &MailboxHandler{}
+	if cfg.EmailTransport == "mailbox" {
+		h.mailbox = services.NewMailbox(cfg.EmailMailboxDir)
+	}
+	return h
+}
+
+func (h *MailboxHandler) enabled(c *gin.Context) bool {
+	if h.mailbox == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The mailbox transport is not enabled"})
+		return false
+	}
+	return true
+}
+
+// ListMessages lists stored emails, newest first, optionally only those sent to "to"
+func (h *MailboxHandler) ListMessages(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	entries, err := h.mailbox.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read mailbox"})
+		return
+	}
+	if to := c.Query("to"); to != "" {
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if strings.Contains(strings.ToLower(entry.To), strings.ToLower(to)) {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": entries})
+}
+
+// GetMessage returns a stored email with its decoded parts, or the raw .eml with format=eml
+func (h *MailboxHandler) GetMessage(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	name := c.Param("name")
+
+	if c.Query("format") == "eml" {
+		data, err := h.mailbox.Raw(name)
+		if err != nil {
+			h.messageError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+		c.Data(http.StatusOK, "message/rfc822", data)
+		return
+	}
+
+	message, err := h.mailbox.Read(name)
+	if err != nil {
+		h.messageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func (h *MailboxHandler) DeleteMessage(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	if err := h.mailbox.Delete(c.Param("name")); err != nil {
+		h.messageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted"})
+}
+
+// ClearMessages empties the mailbox
+func (h *MailboxHandler) ClearMessages(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	if err := h.mailbox.Clear(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear mailbox"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Mailbox cleared"})
+}
+
+func (h *MailboxHandler) messageError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrMailboxMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read message"})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+const (
+	notificationPollInterval = 5 * time.Second
+	// Below the 60s proxy read timeout
+	notificationHeartbeatInterval = 25 * time.Second
+)
+
+// NotificationHandler serves the user's in-app notification center
+type NotificationHandler struct {
+	db *gorm.DB
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{db: db}
+}
+
+// ListNotifications lists the user's notifications, newest first
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var notifications []models.Notification
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	unread, err := services.UnreadNotificationCount(h.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  unread,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	unread, err := services.UnreadNotificationCount(h.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	var notification models.Notification
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := h.db.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+		notification.ReadAt = &now
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notification": notification})
+}
+
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	result := h.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read", "updated": result.RowsAffected})
+}
+
+// Stream pushes new notifications as server-sent events. Each notification is a
+// "notification" event whose id is the notification ID, so a client reconnecting with
+// Last-Event-ID receives what it missed; "unread_count" events follow every change.
+// The stream ends with the login session.
+func (h *NotificationHandler) Stream(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	sessionID := c.MustGet("session_id").(uuid.UUID)
+
+	since := time.Now()
+	if lastID, err := uuid.Parse(c.GetHeader("Last-Event-ID")); err == nil {
+		var last models.Notification
+		if err := h.db.Select("created_at").Where("id = ? AND user_id = ?", lastID, userID).First(&last).Error; err == nil {
+			since = last.CreatedAt
+		}
+	}
+
+	feed, err := services.NewNotificationFeed(h.db, userID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open notification stream"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	writeEvent := func(id, event string, data interface{}) error {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+	writeUnreadCount := func() error {
+		unread, err := services.UnreadNotificationCount(h.db, userID)
+		if err != nil {
+			return err
+		}
+		return writeEvent("", "unread_count", gin.H{"unread_count": unread})
+	}
+
+	// Tell the browser how long to wait before reconnecting
+	fmt.Fprintf(w, "retry: %d\n\n", notificationPollInterval.Milliseconds())
+	if err := writeUnreadCount(); err != nil {
+		return
+	}
+
+	poll := time.NewTicker(notificationPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(notificationHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		case <-poll.C:
+			var active int64
+			h.db.Model(&models.Session{}).
+				Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+				Count(&active)
+			if active == 0 {
+				writeEvent("", "session_ended", gin.H{"error": "Session has ended"})
+				return
+			}
+
+			notifications, err := feed.Poll()
+			if err != nil {
+				log.Printf("[Notifications] Failed to poll notifications of user %s: %v", userID, err)
+				continue
+			}
+			if len(notifications) == 0 {
+				continue
+			}
+			for _, notification := range notifications {
+				if err := writeEvent(notification.ID.String(), "notification", notification); err != nil {
+					return
+				}
+			}
+			if err := writeUnreadCount(); err != nil {
+				return
+			}
+		}
+	}
+}
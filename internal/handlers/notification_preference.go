@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// NotificationPreferenceHandler lets users choose which optional emails they receive
+type NotificationPreferenceHandler struct {
+	db       *gorm.DB
+	config   *config.Config
+	emailSvc *services.EmailService
+}
+
+func NewNotificationPreferenceHandler(db *gorm.DB, cfg *config.Config) *NotificationPreferenceHandler {
+	return &NotificationPreferenceHandler{
+		db:       db,
+		config:   cfg,
+		emailSvc: services.NewEmailService(cfg, db),
+	}
+}
+
+func (h *NotificationPreferenceHandler) GetPreferences(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	pref, err := h.emailSvc.NotificationPreferences(h.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": pref,
+		"categories":  models.NotificationCategories,
+	})
+}
+
+// UpdatePreferences changes the categories given in the request and keeps the others
+func (h *NotificationPreferenceHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req map[string]bool
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref, err := h.emailSvc.NotificationPreferences(h.db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+	for category, enabled := range req {
+		if !pref.Set(category, enabled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification category: " + category})
+			return
+		}
+	}
+
+	if err := h.emailSvc.SaveNotificationPreferences(h.db, pref); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": pref})
+}
+
+// Unsubscribe handles signed unsubscribe links: one-click POSTs from mail clients
+// (RFC 8058) and the storefront unsubscribe page. The token is read from the query
+// or a JSON body.
+func (h *NotificationPreferenceHandler) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var req struct {
+			Token string `json:"token"`
+		}
+		_ = c.ShouldBindJSON(&req)
+		token = req.Token
+	}
+
+	category, err := h.emailSvc.Unsubscribe(token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Unsubscribed",
+		"category": category,
+	})
+}
+
+// UnsubscribePage sends people who open the List-Unsubscribe URL in a browser to the
+// storefront page, so link scanners fetching it never unsubscribe anyone
+func (h *NotificationPreferenceHandler) UnsubscribePage(c *gin.Context) {
+	c.Redirect(http.StatusFound, h.emailSvc.UnsubscribeURLForToken(c.Query("token")))
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// RegistryHandler serves licensed plugins as read-only npm and Composer repositories
+type RegistryHandler struct {
+	db          *gorm.DB
+	config      *config.Config
+	releaseSvc  *services.ReleaseService
+	downloadSvc *services.DownloadService
+}
+
+func NewRegistryHandler(db *gorm.DB, cfg *config.Config, releaseSvc *services.ReleaseService) *RegistryHandler {
+	return &RegistryHandler{
+		db:          db,
+		config:      cfg,
+		releaseSvc:  releaseSvc,
+		downloadSvc: services.NewDownloadService(db, cfg),
+	}
+}
+
+// packageVersion is a release the caller's license covers, with its package version
+type packageVersion struct {
+	release *models.PluginRelease
+	version string
+}
+
+// findPackageLicense returns the caller's license for the plugin that covers the most releases
+func findPackageLicense(db *gorm.DB, c *gin.Context, plugin *models.Plugin) (*models.License, bool) {
+	userID, _ := c.Get("user_id")
+
+	var license models.License
+	err := db.Where("user_id = ? AND plugin_id = ? AND status <> ?", userID, plugin.ID, "revoked").
+		Order("maintenance_until DESC").First(&license).Error
+	if err != nil {
+		return nil, false
+	}
+	license.Plugin = *plugin
+	return &license, true
+}
+
+// packageVersions lists the releases with a semver tag that the license covers, newest first
+func (h *RegistryHandler) packageVersions(plugin *models.Plugin, license *models.License) ([]packageVersion, error) {
+	var releases []models.PluginRelease
+	if err := h.db.Where("plugin_id = ?", plugin.ID).Order("published_at DESC").Find(&releases).Error; err != nil {
+		return nil, err
+	}
+
+	versions := make([]packageVersion, 0, len(releases))
+	for i := range releases {
+		version, ok := services.PackageVersion(releases[i].TagName)
+		if !ok || !license.CoversRelease(&releases[i]) {
+			continue
+		}
+		versions = append(versions, packageVersion{release: &releases[i], version: version})
+	}
+	return versions, nil
+}
+
+// ==================== npm ====================
+
+// Npm serves packuments (/<package>) and tarballs (/<package>/-/<name>-<version>.tgz)
+func (h *RegistryHandler) Npm(c *gin.Context) {
+	requestPath := strings.TrimPrefix(c.Param("path"), "/")
+
+	name, tarball := requestPath, ""
+	if i := strings.Index(requestPath, "/-/"); i > 0 {
+		name, tarball = requestPath[:i], requestPath[i+len("/-/"):]
+	}
+
+	var plugin models.Plugin
+	if err := h.db.Where("npm_package = ?", name).First(&plugin).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	license, ok := findPackageLicense(h.db, c, &plugin)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have a license for this package"})
+		return
+	}
+
+	versions, err := h.packageVersions(&plugin, license)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list versions"})
+		return
+	}
+
+	if tarball != "" {
+		h.serveNpmTarball(c, &plugin, license, versions, tarball)
+		return
+	}
+
+	versionMetadata := map[string]interface{}{}
+	times := map[string]interface{}{}
+	latest := ""
+	for _, v := range versions {
+		manifest, _, err := h.releaseSvc.Manifest(c.Request.Context(), &plugin, v.release, "package.json")
+		if err != nil {
+			log.Printf("[Registry] Failed to fetch package.json of %s@%s: %v", plugin.NpmPackage, v.version, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch package metadata"})
+			return
+		}
+
+		versionMetadata[v.version] = services.NpmVersionMetadata(&plugin, v.release, v.version, manifest, h.npmTarballURL(&plugin, v.version))
+		times[v.version] = v.release.PublishedAt
+		if latest == "" && !v.release.Prerelease && !strings.Contains(v.version, "-") {
+			latest = v.version
+		}
+	}
+	if latest == "" && len(versions) > 0 {
+		latest = versions[0].version
+	}
+
+	distTags := map[string]string{}
+	if latest != "" {
+		distTags["latest"] = latest
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":        plugin.NpmPackage,
+		"description": plugin.Description,
+		"dist-tags":   distTags,
+		"versions":    versionMetadata,
+		"time":        times,
+	})
+}
+
+func (h *RegistryHandler) npmTarballURL(plugin *models.Plugin, version string) string {
+	base := plugin.NpmPackage
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		base = base[i+1:]
+	}
+	return fmt.Sprintf("%s/api/npm/%s/-/%s-%s.tgz", h.config.AppURL, plugin.NpmPackage, base, version)
+}
+
+func (h *RegistryHandler) serveNpmTarball(c *gin.Context, plugin *models.Plugin, license *models.License, versions []packageVersion, file string) {
+	base := plugin.NpmPackage
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		base = base[i+1:]
+	}
+	version := strings.TrimSuffix(strings.TrimPrefix(file, base+"-"), ".tgz")
+
+	for _, v := range versions {
+		if v.version == version {
+			// npm strips the top-level directory of tarballs, so GitHub's archive can be served as is
+			h.serveArchive(c, plugin, license, v.release, "tar", "npm", file)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Version not available to your license"})
+}
+
+// ==================== Composer ====================
+
+// ComposerPackages serves packages.json, listing the packages the caller holds a license for
+func (h *RegistryHandler) ComposerPackages(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var names []string
+	h.db.Model(&models.Plugin{}).
+		Joins("JOIN licenses ON licenses.plugin_id = plugins.id").
+		Where("licenses.user_id = ? AND licenses.status <> ? AND plugins.composer_package <> ''", userID, "revoked").
+		Distinct().
+		Pluck("plugins.composer_package", &names)
+
+	c.JSON(http.StatusOK, gin.H{
+		"packages":           []string{},
+		"metadata-url":       h.config.AppURL + "/api/composer/p2/%package%.json",
+		"available-packages": names,
+	})
+}
+
+// ComposerMetadata serves /p2/<vendor>/<name>.json
+func (h *RegistryHandler) ComposerMetadata(c *gin.Context) {
+	name := strings.TrimSuffix(strings.TrimPrefix(c.Param("path"), "/"), ".json")
+
+	// Development branches are not distributed
+	if strings.HasSuffix(name, "~dev") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	var plugin models.Plugin
+	if err := h.db.Where("composer_package = ?", name).First(&plugin).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	license, ok := findPackageLicense(h.db, c, &plugin)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have a license for this package"})
+		return
+	}
+
+	versions, err := h.packageVersions(&plugin, license)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list versions"})
+		return
+	}
+
+	packages := make([]map[string]interface{}, 0, len(versions))
+	for _, v := range versions {
+		manifest, _, err := h.releaseSvc.Manifest(c.Request.Context(), &plugin, v.release, "composer.json")
+		if err != nil {
+			log.Printf("[Registry] Failed to fetch composer.json of %s@%s: %v", plugin.ComposerPackage, v.version, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch package metadata"})
+			return
+		}
+
+		distURL := fmt.Sprintf("%s/api/composer/dist/%s/%s.zip", h.config.AppURL, plugin.ComposerPackage, v.version)
+		packages = append(packages, services.ComposerVersionMetadata(&plugin, v.release, v.version, manifest, distURL))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"packages": gin.H{plugin.ComposerPackage: packages},
+	})
+}
+
+// ComposerDist serves /dist/<vendor>/<name>/<version>.zip
+func (h *RegistryHandler) ComposerDist(c *gin.Context) {
+	requestPath := strings.TrimSuffix(strings.TrimPrefix(c.Param("path"), "/"), ".zip")
+	i := strings.LastIndex(requestPath, "/")
+	if i <= 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	name, version := requestPath[:i], requestPath[i+1:]
+
+	var plugin models.Plugin
+	if err := h.db.Where("composer_package = ?", name).First(&plugin).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	license, ok := findPackageLicense(h.db, c, &plugin)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have a license for this package"})
+		return
+	}
+
+	versions, err := h.packageVersions(&plugin, license)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list versions"})
+		return
+	}
+
+	for _, v := range versions {
+		if v.version == version {
+			filename := fmt.Sprintf("%s-%s.zip", strings.ReplaceAll(plugin.ComposerPackage, "/", "-"), version)
+			h.serveArchive(c, &plugin, license, v.release, "zip", "composer", filename)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Version not available to your license"})
+}
+
+// serveArchive records the download and streams the release source archive from GitHub
+func (h *RegistryHandler) serveArchive(c *gin.Context, plugin *models.Plugin, license *models.License, release *models.PluginRelease, format, via, filename string) {
+	body, size, err := h.releaseSvc.OpenArchive(c.Request.Context(), plugin, release, format)
+	if err != nil {
+		log.Printf("[Registry] Failed to fetch %s@%s: %v", plugin.Slug, release.TagName, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch package archive"})
+		return
+	}
+	defer body.Close()
+
+	download := &models.ReleaseDownload{
+		LicenseID: license.ID,
+		ReleaseID: release.ID,
+		UserID:    license.UserID,
+		Format:    format,
+		Via:       via,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if err := h.downloadSvc.Record(download, plugin.ID); err != nil {
+		log.Printf("[Registry] Failed to record download of %s@%s: %v", plugin.Slug, release.TagName, err)
+	}
+
+	c.DataFromReader(http.StatusOK, size, "application/octet-stream", body, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, filename),
+	})
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+type ReleaseHandler struct {
+	db          *gorm.DB
+	config      *config.Config
+	releaseSvc  *services.ReleaseService
+	downloadSvc *services.DownloadService
+}
+
+func NewReleaseHandler(db *gorm.DB, cfg *config.Config, releaseSvc *services.ReleaseService) *ReleaseHandler {
+	return &ReleaseHandler{
+		db:          db,
+		config:      cfg,
+		releaseSvc:  releaseSvc,
+		downloadSvc: services.NewDownloadService(db, cfg),
+	}
+}
+
+// ListPluginReleases lists the published releases of a plugin with their assets
+func (h *ReleaseHandler) ListPluginReleases(c *gin.Context) {
+	slug := c.Param("slug")
+
+	var plugin models.Plugin
+	if err := h.db.Where("slug = ? AND status = ?", slug, "published").First(&plugin).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plugin not found"})
+		return
+	}
+
+	var releases []models.PluginRelease
+	if err := h.db.Preload("Assets").Where("plugin_id = ?", plugin.ID).
+		Order("published_at DESC").Find(&releases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch releases"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"releases":       releases,
+		"latest_version": plugin.Version,
+	})
+}
+
+// DownloadPluginRelease streams a release archive (?format=zip|tar) or asset (?asset=name) for the caller's license
+func (h *ReleaseHandler) DownloadPluginRelease(c *gin.Context) {
+	license, release, ok := h.resolvePluginRelease(c)
+	if !ok {
+		return
+	}
+
+	asset, format, ok := h.resolveFile(c, release)
+	if !ok {
+		return
+	}
+
+	h.serve(c, license, release, asset, format, "session")
+}
+
+// CreateDownloadURL issues a short-lived signed URL for a release, e.g. for CI pipelines
+func (h *ReleaseHandler) CreateDownloadURL(c *gin.Context) {
+	license, release, ok := h.resolvePluginRelease(c)
+	if !ok {
+		return
+	}
+
+	asset, format, ok := h.resolveFile(c, release)
+	if !ok {
+		return
+	}
+
+	ttl := time.Duration(h.config.DownloadURLTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+
+	grant := services.DownloadGrant{
+		LicenseID: license.ID,
+		ReleaseID: release.ID,
+		Format:    format,
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if asset != nil {
+		grant.AssetID = &asset.ID
+		grant.Format = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        h.downloadSvc.SignedURL(grant),
+		"expires_at": grant.ExpiresAt,
+	})
+}
+
+// SignedDownload streams a release through a signed URL, without a session
+func (h *ReleaseHandler) SignedDownload(c *gin.Context) {
+	grant, err := h.downloadSvc.ParseSignedURL(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var license models.License
+	if err := h.db.Preload("Plugin").First(&license, "id = ?", grant.LicenseID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "License not found"})
+		return
+	}
+
+	var release models.PluginRelease
+	if err := h.db.Where("id = ? AND plugin_id = ?", grant.ReleaseID, license.PluginID).First(&release).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+		return
+	}
+
+	// The license may have been revoked since the URL was signed
+	if !license.CoversRelease(&release) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This license does not cover the release"})
+		return
+	}
+
+	var asset *models.PluginReleaseAsset
+	if grant.AssetID != nil {
+		asset = &models.PluginReleaseAsset{}
+		if err := h.db.Where("id = ? AND release_id = ?", *grant.AssetID, release.ID).First(asset).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+			return
+		}
+	}
+
+	h.serve(c, &license, &release, asset, grant.Format, "signed_url")
+}
+
+// ListLicenseReleases lists the plugin's releases and marks the ones the license can download
+func (h *ReleaseHandler) ListLicenseReleases(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	licenseID := c.Param("id")
+
+	var license models.License
+	if err := h.db.Where("id = ? AND user_id = ?", licenseID, userID).First(&license).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "License not found"})
+		return
+	}
+
+	var releases []models.PluginRelease
+	if err := h.db.Preload("Assets").Where("plugin_id = ?", license.PluginID).
+		Order("published_at DESC").Find(&releases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch releases"})
+		return
+	}
+
+	var counts []struct {
+		ReleaseID uuid.UUID
+		Count     int
+	}
+	h.db.Model(&models.ReleaseDownload{}).
+		Select("release_id, COUNT(*) AS count").
+		Where("license_id = ?", license.ID).
+		Group("release_id").
+		Scan(&counts)
+	downloads := make(map[uuid.UUID]int, len(counts))
+	for _, count := range counts {
+		downloads[count.ReleaseID] = count.Count
+	}
+
+	type releaseItem struct {
+		models.PluginRelease
+		Downloadable     bool `json:"downloadable"`
+		LicenseDownloads int  `json:"license_downloads"`
+	}
+	items := make([]releaseItem, 0, len(releases))
+	for _, release := range releases {
+		items = append(items, releaseItem{
+			PluginRelease:    release,
+			Downloadable:     license.CoversRelease(&release),
+			LicenseDownloads: downloads[release.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"releases":          items,
+		"maintenance_until": license.MaintenanceUntil,
+	})
+}
+
+// DownloadLicenseRelease streams the source archive of a release covered by the license (?format=zip|tar)
+func (h *ReleaseHandler) DownloadLicenseRelease(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	licenseID := c.Param("id")
+	tag := c.Param("tag")
+
+	var license models.License
+	if err := h.db.Preload("Plugin").Where("id = ? AND user_id = ?", licenseID, userID).First(&license).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "License not found"})
+		return
+	}
+
+	var release models.PluginRelease
+	if err := h.db.Where("plugin_id = ? AND tag_name = ?", license.PluginID, tag).First(&release).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+		return
+	}
+
+	if !license.CoversRelease(&release) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":             "This release was published after your maintenance period ended",
+			"maintenance_until": license.MaintenanceUntil,
+			"should_renew":      license.Status != "revoked",
+		})
+		return
+	}
+
+	asset, format, ok := h.resolveFile(c, &release)
+	if !ok {
+		return
+	}
+
+	h.serve(c, &license, &release, asset, format, "session")
+}
+
+// resolvePluginRelease finds the release named in the URL and the caller's license that covers it
+func (h *ReleaseHandler) resolvePluginRelease(c *gin.Context) (*models.License, *models.PluginRelease, bool) {
+	userID, _ := c.Get("user_id")
+	slug := c.Param("slug")
+	tag := c.Param("tag")
+
+	var plugin models.Plugin
+	if err := h.db.Where("slug = ?", slug).First(&plugin).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plugin not found"})
+		return nil, nil, false
+	}
+
+	var release models.PluginRelease
+	if err := h.db.Where("plugin_id = ? AND tag_name = ?", plugin.ID, tag).First(&release).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+		return nil, nil, false
+	}
+
+	var licenses []models.License
+	if err := h.db.Where("user_id = ? AND plugin_id = ? AND status <> ?", userID, plugin.ID, "revoked").
+		Order("maintenance_until DESC").Find(&licenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch licenses"})
+		return nil, nil, false
+	}
+	if len(licenses) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have a license for this plugin"})
+		return nil, nil, false
+	}
+
+	license := &licenses[0]
+	if !license.CoversRelease(&release) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":             "This release was published after your maintenance period ended",
+			"license_id":        license.ID,
+			"maintenance_until": license.MaintenanceUntil,
+			"should_renew":      true,
+		})
+		return nil, nil, false
+	}
+
+	license.Plugin = plugin
+	return license, &release, true
+}
+
+// resolveFile reads which file of a release is wanted: an asset by name or a source archive format
+func (h *ReleaseHandler) resolveFile(c *gin.Context, release *models.PluginRelease) (*models.PluginReleaseAsset, string, bool) {
+	if name := c.Query("asset"); name != "" {
+		var asset models.PluginReleaseAsset
+		if err := h.db.Where("release_id = ? AND name = ?", release.ID, name).First(&asset).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+			return nil, "", false
+		}
+		return &asset, "", true
+	}
+
+	format := c.DefaultQuery("format", "zip")
+	if format != "zip" && format != "tar" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be zip or tar"})
+		return nil, "", false
+	}
+	return nil, format, true
+}
+
+// serve records the download and streams the asset or archive from GitHub.
+// license.Plugin must be loaded.
+func (h *ReleaseHandler) serve(c *gin.Context, license *models.License, release *models.PluginRelease, asset *models.PluginReleaseAsset, format, via string) {
+	var (
+		body        io.ReadCloser
+		size        int64 = -1
+		contentType string
+		filename    string
+		err         error
+	)
+
+	if asset != nil {
+		body, err = h.releaseSvc.OpenAsset(c.Request.Context(), &license.Plugin, asset)
+		size, contentType, filename = asset.Size, asset.ContentType, asset.Name
+		format = "asset"
+	} else {
+		body, size, err = h.releaseSvc.OpenArchive(c.Request.Context(), &license.Plugin, release, format)
+		contentType, filename = "application/zip", fmt.Sprintf("%s-%s.zip", license.Plugin.Slug, release.TagName)
+		if format == "tar" {
+			contentType, filename = "application/gzip", fmt.Sprintf("%s-%s.tar.gz", license.Plugin.Slug, release.TagName)
+		}
+	}
+	if err != nil {
+		log.Printf("[Releases] Failed to open %s@%s: %v", license.Plugin.Slug, release.TagName, err)
+		if errors.Is(err, services.ErrGitHubNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Downloads are not available"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch release file"})
+		return
+	}
+	defer body.Close()
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	download := &models.ReleaseDownload{
+		LicenseID: license.ID,
+		ReleaseID: release.ID,
+		UserID:    license.UserID,
+		Format:    format,
+		Via:       via,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if asset != nil {
+		download.AssetID = &asset.ID
+	}
+	if err := h.downloadSvc.Record(download, license.PluginID); err != nil {
+		log.Printf("[Releases] Failed to record download of %s@%s: %v", license.Plugin.Slug, release.TagName, err)
+	}
+
+	c.DataFromReader(http.StatusOK, size, contentType, body, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, filename),
+	})
+}
+
+// ListAdminPluginReleases lists the synced releases of a plugin for the admin
+func (h *ReleaseHandler) ListAdminPluginReleases(c *gin.Context) {
+	id := c.Param("id")
+
+	var releases []models.PluginRelease
+	if err := h.db.Preload("Assets").Where("plugin_id = ?", id).Order("published_at DESC").Find(&releases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch releases"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"releases": releases})
+}
+
+// GetPluginDownloadStats returns download counts of a plugin per version and per license
+func (h *ReleaseHandler) GetPluginDownloadStats(c *gin.Context) {
+	id := c.Param("id")
+
+	var byVersion []struct {
+		TagName   string `json:"tag_name"`
+		Downloads int    `json:"downloads"`
+	}
+	if err := h.db.Table("release_downloads").
+		Select("plugin_releases.tag_name, COUNT(*) AS downloads").
+		Joins("JOIN plugin_releases ON plugin_releases.id = release_downloads.release_id").
+		Where("plugin_releases.plugin_id = ?", id).
+		Group("plugin_releases.tag_name").
+		Order("downloads DESC").
+		Scan(&byVersion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch download stats"})
+		return
+	}
+
+	var byLicense []struct {
+		LicenseID      uuid.UUID `json:"license_id"`
+		Email          string    `json:"email"`
+		Downloads      int       `json:"downloads"`
+		LastDownloadAt time.Time `json:"last_download_at"`
+	}
+	if err := h.db.Table("release_downloads").
+		Select("release_downloads.license_id, users.email, COUNT(*) AS downloads, MAX(release_downloads.created_at) AS last_download_at").
+		Joins("JOIN plugin_releases ON plugin_releases.id = release_downloads.release_id").
+		Joins("JOIN users ON users.id = release_downloads.user_id").
+		Where("plugin_releases.plugin_id = ?", id).
+		Group("release_downloads.license_id, users.email").
+		Order("downloads DESC").
+		Limit(100).
+		Scan(&byLicense).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch download stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"by_version": byVersion,
+		"by_license": byLicense,
+	})
+}
+
+// SyncPluginReleases imports a plugin's releases and tags from GitHub now
+func (h *ReleaseHandler) SyncPluginReleases(c *gin.Context) {
+	id := c.Param("id")
+
+	var plugin models.Plugin
+	if err := h.db.First(&plugin, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plugin not found"})
+		return
+	}
+	if plugin.GitHubRepoName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Plugin has no GitHub repository"})
+		return
+	}
+
+	created, err := h.releaseSvc.SyncPlugin(c.Request.Context(), &plugin)
+	if err != nil {
+		if errors.Is(err, services.ErrGitHubNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "GitHub service not configured"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync releases: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Synced %d new releases", created),
+		"created": created,
+		"version": plugin.Version,
+	})
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+// RoleHandler manages the roles that grant admin permissions
+type RoleHandler struct {
+	db *gorm.DB
+}
+
+func NewRoleHandler(db *gorm.DB) *RoleHandler {
+	return &RoleHandler{db: db}
+}
+
+// ListRoles lists roles with the number of users holding each
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.db.Order("is_system DESC, name ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	type roleCount struct {
+		Role  string
+		Count int64
+	}
+	var counts []roleCount
+	h.db.Model(&models.User{}).Select("role, COUNT(*) AS count").Group("role").Scan(&counts)
+	userCounts := map[string]int64{}
+	for _, rc := range counts {
+		userCounts[rc.Role] = rc.Count
+	}
+
+	result := make([]gin.H, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, gin.H{
+			"role":       role,
+			"user_count": userCounts[role.Name],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":                 result,
+		"available_permissions": models.AdminPermissions,
+	})
+}
+
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// parsePermissions validates requested permissions and checks the caller holds all of them
+func parsePermissions(c *gin.Context, requested []string) (string, bool) {
+	permissions := make([]string, 0, len(requested))
+	for _, permission := range requested {
+		if !models.IsAdminPermission(permission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + permission})
+			return "", false
+		}
+		if !containsString(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	role := models.Role{Permissions: strings.Join(permissions, " ")}
+	if !callerRole(c).Covers(&role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant permissions you do not have"})
+		return "", false
+	}
+	return role.Permissions, true
+}
+
+// callerRole returns the admin role of the current user, loaded by AdminMiddleware
+func callerRole(c *gin.Context) *models.Role {
+	if role, ok := c.Get("admin_role"); ok {
+		return role.(*models.Role)
+	}
+	return &models.Role{}
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role names are 2-20 lowercase letters, digits, '-' or '_'"})
+		return
+	}
+
+	permissions, ok := parsePermissions(c, req.Permissions)
+	if !ok {
+		return
+	}
+
+	var existing int64
+	h.db.Model(&models.Role{}).Where("name = ?", req.Name).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := h.db.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+	logAudit(h.db, c, "role.create", "role", role.Name, nil, role)
+
+	c.JSON(http.StatusCreated, gin.H{"role": role})
+}
+
+// UpdateRole changes a role's description and permissions. Names are fixed, since users reference them.
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var role models.Role
+	if err := h.db.First(&role, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.Name == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role always has every permission"})
+		return
+	}
+	if !callerRole(c).Covers(&role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot edit a role with permissions you do not have"})
+		return
+	}
+
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"description": req.Description}
+	if req.Permissions != nil {
+		permissions, ok := parsePermissions(c, req.Permissions)
+		if !ok {
+			return
+		}
+		updates["permissions"] = permissions
+	}
+
+	before := role
+	if err := h.db.Model(&role).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	logAudit(h.db, c, "role.update", "role", role.Name, before, role)
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// DeleteRole removes a custom role that no user holds
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := h.db.First(&role, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+
+	var holders int64
+	h.db.Model(&models.User{}).Where("role = ?", role.Name).Count(&holders)
+	if holders > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reassign the users holding this role first"})
+		return
+	}
+
+	if err := h.db.Delete(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	logAudit(h.db, c, "role.delete", "role", role.Name, role, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/scheduler"
+	"gorm.io/gorm"
+)
+
+type SchedulerHandler struct {
+	db        *gorm.DB
+	scheduler *scheduler.Scheduler
+}
+
+func NewSchedulerHandler(db *gorm.DB, s *scheduler.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{db: db, scheduler: s}
+}
+
+// ListSchedulerJobs lists scheduled jobs with their schedule, next run and last run
+func (h *SchedulerHandler) ListSchedulerJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": h.scheduler.Jobs()})
+}
+
+// UpdateSchedulerJob changes a job's cron schedule at runtime
+func (h *SchedulerHandler) UpdateSchedulerJob(c *gin.Context) {
+	var req struct {
+		Schedule string `json:"schedule" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.scheduler.UpdateSchedule(c.Param("name"), req.Schedule); err != nil {
+		if errors.Is(err, scheduler.ErrUnknownJob) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule updated successfully", "jobs": h.scheduler.Jobs()})
+}
+
+// TriggerSchedulerJob runs a job immediately
+func (h *SchedulerHandler) TriggerSchedulerJob(c *gin.Context) {
+	userIDValue, _ := c.Get("user_id")
+	adminUserID := userIDValue.(uuid.UUID)
+
+	run, err := h.scheduler.Trigger(c.Param("name"), &adminUserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownJob):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, scheduler.ErrJobRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": "Job is already running"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trigger job"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job triggered", "run": run})
+}
+
+// ListSchedulerRuns lists run history, filterable by job and status
+func (h *SchedulerHandler) ListSchedulerRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	jobName := c.Query("job")
+	status := c.Query("status")
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var runs []models.SchedulerRun
+	var total int64
+
+	query := h.db.Model(&models.SchedulerRun{})
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	query.Count(&total)
+
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("started_at DESC").Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduler runs"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs": runs,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// TwoFactorHandler handles TOTP enrollment and second-factor verification of sessions
+type TwoFactorHandler struct {
+	db           *gorm.DB
+	config       *config.Config
+	twoFactorSvc *services.TwoFactorService
+	sessionSvc   *services.SessionService
+}
+
+func NewTwoFactorHandler(db *gorm.DB, cfg *config.Config) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		db:           db,
+		config:       cfg,
+		twoFactorSvc: services.NewTwoFactorService(db, cfg),
+		sessionSvc:   services.NewSessionService(db, cfg),
+	}
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// currentSession loads the caller's session with its user
+func (h *TwoFactorHandler) currentSession(c *gin.Context) (*models.Session, bool) {
+	sessionID, _ := c.Get("session_id")
+	userID, _ := c.Get("user_id")
+
+	var session models.Session
+	if err := h.db.Preload("User").Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+		return nil, false
+	}
+	return &session, true
+}
+
+// respondWithToken returns an access token carrying the session's new second-factor state
+func (h *TwoFactorHandler) respondWithToken(c *gin.Context, session *models.Session, extra gin.H) {
+	token, err := h.sessionSvc.AccessToken(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	response := gin.H{"token": token}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetStatus reports whether 2FA is enabled and how many recovery codes are left
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	enabledAt, remaining := h.twoFactorSvc.Status(userID.(uuid.UUID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  enabledAt != nil,
+		"enabled_at":               enabledAt,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// Setup starts enrollment, returning the secret to add to an authenticator app
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	secret, otpauthURL, err := h.twoFactorSvc.BeginEnrollment(&user)
+	if errors.Is(err, services.ErrTwoFactorEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": otpauthURL,
+	})
+}
+
+// Enable confirms enrollment with a first code. The recovery codes are only returned here.
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactorSvc.ConfirmEnrollment(session.UserID, req.Code)
+	switch {
+	case errors.Is(err, services.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	case errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	// The code just entered also verifies the current session
+	if err := h.twoFactorSvc.MarkSessionVerified(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+		return
+	}
+
+	h.respondWithToken(c, session, gin.H{"recovery_codes": codes})
+}
+
+// Disable turns 2FA off. Admins cannot, since their role requires it.
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+	if session.User.Role == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins must keep two-factor authentication enabled"})
+		return
+	}
+
+	if err := h.twoFactorSvc.Verify(session.UserID, req.Code); err != nil {
+		h.respondVerifyError(c, err)
+		return
+	}
+	if err := h.twoFactorSvc.Disable(session.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	session.MFAVerifiedAt = nil
+	h.respondWithToken(c, session, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a current code
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.twoFactorSvc.Verify(userID.(uuid.UUID), req.Code); err != nil {
+		h.respondVerifyError(c, err)
+		return
+	}
+
+	codes, err := h.twoFactorSvc.RegenerateRecoveryCodes(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Verify completes the login of a session waiting for its second factor, or refreshes the
+// step-up verification of sensitive admin actions. It returns a new access token.
+func (h *TwoFactorHandler) Verify(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactorSvc.VerifySession(session, req.Code); err != nil {
+		h.respondVerifyError(c, err)
+		return
+	}
+
+	h.respondWithToken(c, session, nil)
+}
+
+func (h *TwoFactorHandler) respondVerifyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+	case errors.Is(err, services.ErrTooManyTwoFactorTries):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many invalid codes, please log in again"})
+	case errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"gorm.io/gorm"
+)
+
+// WebhookHandler manages outgoing webhook endpoints and their delivery log
+type WebhookHandler struct {
+	db *gorm.DB
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+type webhookEndpointRequest struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+// apply validates the request and copies the given fields onto the endpoint
+func (r *webhookEndpointRequest) apply(endpoint *models.WebhookEndpoint) error {
+	if r.URL != nil {
+		u, err := url.Parse(strings.TrimSpace(*r.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an absolute http or https URL")
+		}
+		endpoint.URL = u.String()
+	}
+	if r.Description != nil {
+		endpoint.Description = *r.Description
+	}
+	if r.Events != nil {
+		if len(r.Events) == 0 {
+			return errors.New("subscribe to at least one event")
+		}
+		for _, event := range r.Events {
+			if !models.IsWebhookEvent(event) {
+				return errors.New("unknown event: " + event)
+			}
+		}
+		endpoint.Events = strings.Join(r.Events, " ")
+	}
+	if r.IsActive != nil {
+		endpoint.IsActive = *r.IsActive
+	}
+	return nil
+}
+
+func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
+	var endpoints []models.WebhookEndpoint
+	if err := h.db.Order("created_at ASC").Find(&endpoints).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook endpoints"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoints":        endpoints,
+		"available_events": models.WebhookEvents,
+	})
+}
+
+func (h *WebhookHandler) GetEndpoint(c *gin.Context) {
+	var endpoint models.WebhookEndpoint
+	if err := h.db.First(&endpoint, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"endpoint": endpoint})
+}
+
+// CreateEndpoint adds an endpoint. The signing secret is only returned here and by RotateSecret.
+func (h *WebhookHandler) CreateEndpoint(c *gin.Context) {
+	var req webhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.URL == nil || req.Events == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url and events are required"})
+		return
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook endpoint"})
+		return
+	}
+	endpoint := models.WebhookEndpoint{
+		Secret:   secret,
+		IsActive: true,
+	}
+	if err := req.apply(&endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Create(&endpoint).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook endpoint"})
+		return
+	}
+	logAudit(h.db, c, "webhook.create", "webhook_endpoint", endpoint.ID.String(), nil, endpoint)
+
+	c.JSON(http.StatusCreated, gin.H{"endpoint": endpoint, "secret": endpoint.Secret})
+}
+
+func (h *WebhookHandler) UpdateEndpoint(c *gin.Context) {
+	var endpoint models.WebhookEndpoint
+	if err := h.db.First(&endpoint, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+
+	var req webhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := endpoint
+	if err := req.apply(&endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Save(&endpoint).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook endpoint"})
+		return
+	}
+	logAudit(h.db, c, "webhook.update", "webhook_endpoint", endpoint.ID.String(), before, endpoint)
+
+	c.JSON(http.StatusOK, gin.H{"endpoint": endpoint})
+}
+
+// DeleteEndpoint removes an endpoint together with its delivery log
+func (h *WebhookHandler) DeleteEndpoint(c *gin.Context) {
+	var endpoint models.WebhookEndpoint
+	if err := h.db.First(&endpoint, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&endpoint).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook endpoint"})
+		return
+	}
+	logAudit(h.db, c, "webhook.delete", "webhook_endpoint", endpoint.ID.String(), endpoint, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint deleted"})
+}
+
+// RotateSecret replaces the signing secret. Deliveries still queued are signed with the new one.
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	var endpoint models.WebhookEndpoint
+	if err := h.db.First(&endpoint, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+	endpoint.Secret = secret
+	if err := h.db.Model(&endpoint).Update("secret", endpoint.Secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+	logAudit(h.db, c, "webhook.rotate_secret", "webhook_endpoint", endpoint.ID.String(), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"endpoint": endpoint, "secret": endpoint.Secret})
+}
+
+// TestEndpoint queues a ping event to the endpoint
+func (h *WebhookHandler) TestEndpoint(c *gin.Context) {
+	var endpoint models.WebhookEndpoint
+	if err := h.db.First(&endpoint, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+
+	delivery, err := services.SendTestWebhook(h.db, &endpoint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue test webhook"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"delivery": delivery})
+}
+
+// ListDeliveries lists the delivery log, newest first, without payloads
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.WebhookDelivery{})
+	if endpointID := c.Query("endpoint_id"); endpointID != "" {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Omit("payload").Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook deliveries"})
+		return
+	}
+
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// GetDelivery returns one delivery including its payload and the endpoint's response
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	var delivery models.WebhookDelivery
+	if err := h.db.Preload("Endpoint").First(&delivery, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+// Redeliver sends a delivery again, e.g. after the receiving system was fixed
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := services.Redeliver(h.db, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue redelivery"})
+		return
+	}
+	logAudit(h.db, c, "webhook.redeliver", "webhook_delivery", id.String(), nil, delivery)
+
+	c.JSON(http.StatusAccepted, gin.H{"delivery": delivery})
+}
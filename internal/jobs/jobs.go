@@ -0,0 +1,225 @@
+// Package jobs implements a durable background job queue backed by the jobs table.
+//
+// Jobs are enqueued inside the caller's transaction, claimed by workers with
+// SELECT ... FOR UPDATE SKIP LOCKED so several replicas can share the queue,
+// retried with exponential backoff and dead-lettered once their attempts run out.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+	StatusCancelled = "cancelled"
+)
+
+// Handler executes a single job. Returning an error schedules a retry unless the
+// error is wrapped with Permanent or the job has no attempts left.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// RetryPolicy controls how often and how quickly a job type is retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used for job types registered without a policy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    time.Hour,
+}
+
+// Backoff returns the delay before the next attempt, doubling after every failure
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying; the job is dead-lettered immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Typed adapts a function taking a decoded payload into a Handler
+func Typed[T any](fn func(ctx context.Context, payload T) error) Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload T
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return Permanent(fmt.Errorf("invalid payload for %s: %w", job.Type, err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+	uniqueKey   string
+}
+
+// EnqueueOption customises a single enqueued job
+type EnqueueOption func(*enqueueOptions)
+
+// RunAt delays the first attempt until t
+func RunAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// Delay delays the first attempt by d
+func Delay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+// MaxAttempts overrides the handler's retry policy for this job
+func MaxAttempts(n int) EnqueueOption {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// activeUniqueKeyPredicate is the condition of the partial unique index on jobs.unique_key
+const activeUniqueKeyPredicate = "(status = 'pending' OR status = 'running')"
+
+// UniqueKey skips the enqueue when a pending or running job with the same key exists
+func UniqueKey(key string) EnqueueOption {
+	return func(o *enqueueOptions) { o.uniqueKey = key }
+}
+
+// Enqueue stores a new job. Pass the caller's transaction so the job is only
+// visible to workers once the surrounding work has been committed.
+func Enqueue(tx *gorm.DB, jobType string, payload interface{}, opts ...EnqueueOption) (*models.Job, error) {
+	options := enqueueOptions{runAt: time.Now()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", jobType, err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      StatusPending,
+		MaxAttempts: options.maxAttempts,
+		RunAt:       options.runAt,
+	}
+
+	if options.uniqueKey == "" {
+		if err := tx.Create(job).Error; err != nil {
+			return nil, fmt.Errorf("failed to enqueue %s job: %w", jobType, err)
+		}
+		return job, nil
+	}
+
+	// The partial unique index on unique_key makes concurrent enqueues of the same key
+	// insert one job; the others find it already queued
+	job.UniqueKey = &options.uniqueKey
+	result := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "unique_key"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: activeUniqueKeyPredicate}}},
+		DoNothing:   true,
+	}).Create(job)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job: %w", jobType, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return job, nil
+	}
+
+	var existing models.Job
+	if err := tx.Where("unique_key = ? AND "+activeUniqueKeyPredicate, options.uniqueKey).First(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to find queued %s job: %w", jobType, err)
+	}
+	return &existing, nil
+}
+
+// Retry moves a dead or cancelled job back to pending with a fresh set of attempts
+func Retry(db *gorm.DB, id uuid.UUID) (*models.Job, error) {
+	var job models.Job
+	if err := db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if job.Status != StatusDead && job.Status != StatusCancelled {
+		return nil, fmt.Errorf("job is %s, only dead or cancelled jobs can be retried", job.Status)
+	}
+	if job.UniqueKey != nil {
+		var queued int64
+		if err := db.Model(&models.Job{}).Where("unique_key = ? AND "+activeUniqueKeyPredicate, *job.UniqueKey).Count(&queued).Error; err != nil {
+			return nil, err
+		}
+		if queued > 0 {
+			return nil, errors.New("another job with the same unique key is already queued")
+		}
+	}
+
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	job.LastError = ""
+	job.LockedAt = nil
+	job.LockedBy = ""
+	if err := db.Save(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Cancel stops a pending job from running
+func Cancel(db *gorm.DB, id uuid.UUID) (*models.Job, error) {
+	var job models.Job
+	if err := db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if job.Status != StatusPending {
+		return nil, fmt.Errorf("job is %s, only pending jobs can be cancelled", job.Status)
+	}
+
+	job.Status = StatusCancelled
+	if err := db.Save(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// PurgeSucceeded deletes succeeded jobs completed before the cutoff and returns how many were removed
+func PurgeSucceeded(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("status = ? AND completed_at < ?", StatusSucceeded, before).Delete(&models.Job{})
+	return result.RowsAffected, result.Error
+}
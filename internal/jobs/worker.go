@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pollInterval = 2 * time.Second
+	jobTimeout   = 5 * time.Minute
+	// Running jobs locked longer than this were interrupted by a crash or restart
+	lockTimeout = 15 * time.Minute
+)
+
+type registration struct {
+	handler Handler
+	policy  RetryPolicy
+	onDead  func(ctx context.Context, job *models.Job)
+}
+
+// RegisterOption customises how a job type is executed
+type RegisterOption func(*registration)
+
+// WithRetryPolicy sets the retry policy for a job type
+func WithRetryPolicy(policy RetryPolicy) RegisterOption {
+	return func(r *registration) { r.policy = policy }
+}
+
+// OnDeadLetter runs fn after a job of this type has been dead-lettered
+func OnDeadLetter(fn func(ctx context.Context, job *models.Job)) RegisterOption {
+	return func(r *registration) { r.onDead = fn }
+}
+
+// Queue dispatches claimed jobs to the handlers registered for their type
+type Queue struct {
+	db       *gorm.DB
+	workerID string
+
+	mu       sync.RWMutex
+	handlers map[string]registration
+}
+
+func NewQueue(db *gorm.DB) *Queue {
+	hostname, _ := os.Hostname()
+	return &Queue{
+		db:       db,
+		workerID: fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+		handlers: make(map[string]registration),
+	}
+}
+
+// Register installs the handler for a job type
+func (q *Queue) Register(jobType string, handler Handler, opts ...RegisterOption) {
+	reg := registration{handler: handler, policy: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(&reg)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = reg
+}
+
+// Types returns the registered job types
+func (q *Queue) Types() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	types := make([]string, 0, len(q.handlers))
+	for jobType := range q.handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+// Start launches concurrency workers that poll the queue until ctx is cancelled
+func (q *Queue) Start(ctx context.Context, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for i := 0; i < concurrency; i++ {
+		go q.work(ctx)
+	}
+	log.Printf("[Jobs] Started %d workers (%s)", concurrency, q.workerID)
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		job, err := q.claim()
+		if err != nil {
+			log.Printf("[Jobs] Failed to claim job: %v", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		q.run(ctx, job)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// claim locks the next due job for a registered type and marks it running
+func (q *Queue) claim() (*models.Job, error) {
+	types := q.Types()
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var job models.Job
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("type IN ?", types).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				StatusPending, now, StatusRunning, now.Add(-lockTimeout)).
+			Order("run_at ASC").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = StatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		job.LockedBy = q.workerID
+		return tx.Save(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	q.mu.RLock()
+	reg, ok := q.handlers[job.Type]
+	q.mu.RUnlock()
+	if !ok {
+		q.finish(ctx, job, reg, fmt.Errorf("no handler registered for %s", job.Type))
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return reg.handler(jobCtx, job)
+	}()
+
+	q.finish(ctx, job, reg, err)
+}
+
+// finish records the outcome of an attempt, scheduling a retry or dead-lettering on failure
+func (q *Queue) finish(ctx context.Context, job *models.Job, reg registration, err error) {
+	now := time.Now()
+	job.LockedAt = nil
+	job.LockedBy = ""
+
+	if err == nil {
+		job.Status = StatusSucceeded
+		job.CompletedAt = &now
+		job.LastError = ""
+		if err := q.db.Save(job).Error; err != nil {
+			log.Printf("[Jobs] Failed to save job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = reg.policy.MaxAttempts
+	}
+
+	job.LastError = err.Error()
+	if IsPermanent(err) || job.Attempts >= maxAttempts {
+		job.Status = StatusDead
+		job.CompletedAt = &now
+	} else {
+		job.Status = StatusPending
+		job.RunAt = now.Add(reg.policy.Backoff(job.Attempts))
+	}
+
+	if err := q.db.Save(job).Error; err != nil {
+		log.Printf("[Jobs] Failed to save job %s: %v", job.ID, err)
+	}
+
+	if job.Status == StatusPending {
+		log.Printf("[Jobs] %s job %s failed (attempt %d/%d), retrying at %s: %v",
+			job.Type, job.ID, job.Attempts, maxAttempts, job.RunAt.Format(time.RFC3339), err)
+		return
+	}
+
+	log.Printf("[Jobs] %s job %s dead-lettered after %d attempts: %v", job.Type, job.ID, job.Attempts, err)
+	if reg.onDead != nil {
+		reg.onDead(ctx, job)
+	}
+}
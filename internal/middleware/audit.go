@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// AuditMiddleware makes sure every successful mutating admin request leaves an audit entry.
+// Handlers that record a detailed entry with a before/after diff set "audit_recorded";
+// for the others a generic entry naming the route is written.
+func AuditMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			return
+		}
+		if c.Writer.Status() >= http.StatusBadRequest || c.GetBool("audit_recorded") {
+			return
+		}
+
+		// /api/admin/<entity>/... names the entity type
+		route := c.FullPath()
+		entityType := strings.SplitN(strings.TrimPrefix(route, "/api/admin/"), "/", 2)[0]
+		entityID := c.Param("id")
+		if entityID == "" {
+			entityID = c.Param("name")
+		}
+
+		entry := models.AuditLog{
+			Action:     strings.ToLower(c.Request.Method) + " " + route,
+			EntityType: entityType,
+			EntityID:   entityID,
+			Changes:    "{}",
+			ActorEmail: c.GetString("user_email"),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			actorID := userID.(uuid.UUID)
+			entry.ActorID = &actorID
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			log.Printf("[Audit] Failed to record %s: %v", entry.Action, err)
+		}
+	}
+}
@@ -3,21 +3,34 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
 )
 
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
-	Role   string    `json:"role"`
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	Role       string    `json:"role"`
+	SessionID  uuid.UUID `json:"sid"`
+	MFAPending bool      `json:"mfa_pending,omitempty"` // logged in, second factor not entered yet
+	MFAAt      int64     `json:"mfa_at,omitempty"`      // unix time of the last second-factor verification
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// mfaPendingRoutes are the only routes a session waiting for its second factor may call
+var mfaPendingRoutes = map[string]bool{
+	"/api/auth/me":         true,
+	"/api/auth/2fa/verify": true,
+}
+
+// AuthMiddleware authenticates a bearer JWT from the login flow or a personal access token
+func AuthMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -33,6 +46,16 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(tokenString, models.AccessTokenPrefix) {
+			if _, err := authenticateAccessToken(db, c, tokenString); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 			return []byte(cfg.JWTSecret), nil
 		})
@@ -50,30 +73,46 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// Access tokens die with their session, so logout and deactivation take effect immediately
+		var active int64
+		db.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", claims.SessionID, claims.UserID, time.Now()).
+			Count(&active)
+		if active == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+			c.Abort()
+			return
+		}
+
+		if claims.MFAPending && !mfaPendingRoutes[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor verification required", "code": "mfa_required"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("mfa_pending", claims.MFAPending)
+		if claims.MFAAt > 0 {
+			c.Set("mfa_verified_at", time.Unix(claims.MFAAt, 0))
+		}
 
 		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("user_role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
-			return
+		// Browsers only send the refresh cookie cross-origin to an explicitly allowed origin
+		if origin := c.GetHeader("Origin"); origin != "" && origin == strings.TrimRight(cfg.FrontendURL, "/") {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Add("Vary", "Origin")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		}
-		c.Next()
-	}
-}
-
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// AdminMiddleware admits users whose role grants any admin permission. The role is read from the
+// database on every request, so role changes apply without waiting for the access token to expire.
+func AdminMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+
+		var role models.Role
+		err := db.Joins("JOIN users ON users.role = roles.name").
+			Where("users.id = ?", userID).
+			First(&role).Error
+		if err != nil || len(role.PermissionList()) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		// Full admins must use two-factor authentication
+		if role.Name == models.RoleAdmin {
+			if _, verified := c.Get("mfa_verified_at"); !verified {
+				var enrolled int64
+				db.Model(&models.UserTOTP{}).Where("user_id = ? AND enabled_at IS NOT NULL", userID).Count(&enrolled)
+				if enrolled == 0 {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Admins must enable two-factor authentication", "code": "mfa_enrollment_required"})
+				} else {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor verification required", "code": "mfa_required"})
+				}
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("user_role", role.Name)
+		c.Set("admin_role", &role)
+		c.Next()
+	}
+}
+
+// RequireRecentMFA guards sensitive actions with step-up verification: the session must
+// have passed a second-factor check within the configured window
+func RequireRecentMFA(cfg *config.Config) gin.HandlerFunc {
+	window := time.Duration(cfg.MFAStepUpMinutes) * time.Minute
+	return func(c *gin.Context) {
+		verifiedAt, ok := c.Get("mfa_verified_at")
+		if !ok || time.Since(verifiedAt.(time.Time)) > window {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Confirm this action with your two-factor code", "code": "mfa_step_up_required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission restricts a route to roles granted the permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Missing the %s permission", permission)})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ResourcePermission guards an admin route group: GET requests need "<resource>:read",
+// everything else "<resource>:write"
+func ResourcePermission(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permission := resource + ":write"
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			permission = resource + ":read"
+		}
+		if !HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Missing the %s permission", permission)})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the admin role loaded by AdminMiddleware grants a permission
+func HasPermission(c *gin.Context, permission string) bool {
+	role, ok := c.Get("admin_role")
+	return ok && role.(*models.Role).HasPermission(permission)
+}
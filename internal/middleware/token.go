@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often a token's last use is written back
+const lastUsedResolution = time.Minute
+
+var errInvalidAccessToken = errors.New("invalid or expired access token")
+
+// authenticateAccessToken looks up a raw personal access token and records its use
+func authenticateAccessToken(db *gorm.DB, c *gin.Context, raw string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	if err := db.Preload("User").Where("token_hash = ?", models.HashToken(raw)).First(&token).Error; err != nil {
+		return nil, errInvalidAccessToken
+	}
+
+	now := time.Now()
+	if !token.Usable(now) || !token.User.IsActive {
+		return nil, errInvalidAccessToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedResolution || token.LastUsedIP != c.ClientIP() {
+		db.Model(&token).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.ClientIP(),
+		})
+	}
+
+	c.Set("user_id", token.UserID)
+	c.Set("user_email", token.User.Email)
+	c.Set("user_role", token.User.Role)
+	c.Set("access_token_id", token.ID)
+	c.Set("access_token_scopes", token.Scopes)
+	return &token, nil
+}
+
+// PackageAuthMiddleware authenticates package manager requests with a personal access token,
+// sent either as the password of HTTP basic auth (GOPROXY, .netrc) or as a bearer token.
+func PackageAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := ""
+		if _, password, ok := c.Request.BasicAuth(); ok {
+			raw = password
+		} else if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			raw = strings.TrimPrefix(header, "Bearer ")
+		}
+
+		if raw == "" {
+			c.Header("WWW-Authenticate", `Basic realm="plugin store"`)
+			c.String(http.StatusUnauthorized, "access token required")
+			c.Abort()
+			return
+		}
+
+		token, err := authenticateAccessToken(db, c, raw)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Basic realm="plugin store"`)
+			c.String(http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+
+		if !token.HasScope(models.ScopePackages) {
+			c.String(http.StatusForbidden, "access token is missing the %s scope", models.ScopePackages)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope restricts a route to sessions and to access tokens granted the scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isToken := c.Get("access_token_scopes")
+		if isToken {
+			token := models.AccessToken{Scopes: scopes.(string)}
+			if !token.HasScope(scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Access token is missing the %s scope", scope)})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly rejects access tokens on routes that need an interactive login
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get("access_token_id"); isToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an access token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccessTokenPrefix marks tokens issued by the store so they are easy to spot in configs and logs
+const AccessTokenPrefix = "gst_"
+
+// Access token scopes
+const (
+	ScopeLicensesRead = "licenses:read" // list licenses, their history and releases
+	ScopeDownloads    = "downloads"     // download releases and create signed download URLs
+	ScopeOrdersRead   = "orders:read"   // list orders
+	ScopeOrdersWrite  = "orders:write"  // create orders
+	ScopePackages     = "packages"      // Go module proxy, npm and Composer registries
+)
+
+// AccessTokenScopes lists every scope a token can be granted
+var AccessTokenScopes = []string{ScopeLicensesRead, ScopeDownloads, ScopeOrdersRead, ScopeOrdersWrite, ScopePackages}
+
+// AccessToken is a personal token a user creates to authenticate CI systems and package managers against the store
+type AccessToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `json:"prefix"`                            // first characters of the token, to recognise it in the UI
+	Scopes     string     `gorm:"not null;default:''" json:"scopes"` // space separated
+	ExpiresAt  *time.Time `json:"expires_at"`                        // nil never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (t *AccessToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// HasScope reports whether the token was granted a scope
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range strings.Fields(t.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the token is neither revoked nor expired
+func (t *AccessToken) Usable(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// IsAccessTokenScope reports whether scope is a known access token scope
+func IsAccessTokenScope(scope string) bool {
+	for _, s := range AccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashToken returns the stored form of a raw access or refresh token
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
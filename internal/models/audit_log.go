@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLog records one mutating admin action. Rows are never updated or deleted.
+type AuditLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	ActorID    *uuid.UUID `gorm:"type:uuid;index" json:"actor_id"`
+	ActorEmail string     `json:"actor_email"`                  // kept as it was when the action happened
+	Action     string     `gorm:"not null;index" json:"action"` // e.g. license.revoke
+	EntityType string     `gorm:"index:idx_audit_logs_entity" json:"entity_type"`
+	EntityID   string     `gorm:"index:idx_audit_logs_entity" json:"entity_id"`
+	Changes    string     `gorm:"type:jsonb;not null;default:'{}'" json:"changes"` // {"field": {"from": ..., "to": ...}}
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
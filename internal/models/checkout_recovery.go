@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CheckoutRecovery records the reminder sent for an abandoned checkout and whether
+// the buyer came back and paid for the plugin
+type CheckoutRecovery struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrderID             uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	UserID              uuid.UUID  `gorm:"type:uuid;not null;index:idx_checkout_recoveries_user_plugin" json:"user_id"`
+	PluginID            uuid.UUID  `gorm:"type:uuid;not null;index:idx_checkout_recoveries_user_plugin" json:"plugin_id"`
+	EmailNotificationID *uuid.UUID `gorm:"type:uuid" json:"email_notification_id"`
+	Currency            string     `json:"currency"`
+	OriginalAmount      float64    `gorm:"type:decimal(10,2);not null" json:"original_amount"`
+	DiscountPercent     int        `gorm:"not null;default:0" json:"discount_percent"`           // 0 when the reminder offered no discount
+	DiscountedAmount    float64    `gorm:"type:decimal(10,2);not null" json:"discounted_amount"` // the order amount while the discount is valid
+	DiscountExpiresAt   *time.Time `json:"discount_expires_at"`
+	SentAt              time.Time  `gorm:"not null;index" json:"sent_at"`
+	RecoveredAt         *time.Time `json:"recovered_at"`
+	RecoveredOrderID    *uuid.UUID `gorm:"type:uuid" json:"recovered_order_id"` // the paid order, not always the reminded one
+	RecoveredAmount     float64    `gorm:"type:decimal(10,2);not null;default:0" json:"recovered_amount"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	Order  Order  `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+	User   User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Plugin Plugin `gorm:"foreignKey:PluginID" json:"plugin,omitempty"`
+}
+
+func (r *CheckoutRecovery) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// DiscountActive reports whether the reminder's discount can still be used at t
+func (r *CheckoutRecovery) DiscountActive(t time.Time) bool {
+	return r.DiscountPercent > 0 && r.DiscountExpiresAt != nil && t.Before(*r.DiscountExpiresAt)
+}
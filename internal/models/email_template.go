@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Languages customer emails are available in. Users without a template in
+// their language get the DefaultLanguage one.
+const DefaultLanguage = "en"
+
+var SupportedLanguages = []string{"en", "zh"}
+
+// IsSupportedLanguage reports whether emails can be sent in language
+func IsSupportedLanguage(language string) bool {
+	for _, l := range SupportedLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailTemplate is the admin-editable content of one email in one language. Subject
+// and text body are text/template, the HTML body html/template, all rendered with
+// services.EmailData. An empty text body is generated from the HTML body.
+type EmailTemplate struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_email_templates_name_language" json:"name"`
+	Language  string    `gorm:"not null;uniqueIndex:idx_email_templates_name_language" json:"language"`
+	Subject   string    `gorm:"not null" json:"subject"`
+	HTMLBody  string    `gorm:"column:html_body;type:text;not null" json:"html_body"`
+	TextBody  string    `gorm:"type:text;not null;default:''" json:"text_body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t *EmailTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
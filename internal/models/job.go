@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job is a unit of background work stored in Postgres and executed by the jobs worker
+type Job struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Type        string     `gorm:"not null;index" json:"type"`
+	Payload     string     `gorm:"type:jsonb;not null;default:'{}'" json:"payload"`
+	Status      string     `gorm:"default:'pending';index" json:"status"`                                                                             // pending, running, succeeded, dead, cancelled
+	UniqueKey   *string    `gorm:"uniqueIndex:idx_jobs_active_unique_key,where:status = 'pending' OR status = 'running'" json:"unique_key,omitempty"` // unique among pending and running jobs
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"default:0" json:"max_attempts"` // 0 uses the handler's retry policy
+	RunAt       time.Time  `gorm:"index" json:"run_at"`
+	LockedAt    *time.Time `json:"locked_at"`
+	LockedBy    string     `json:"locked_by"`
+	LastError   string     `json:"last_error"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
@@ -12,7 +12,8 @@ type User struct {
 	Email     string    `gorm:"unique;not null" json:"email"`
 	Name      string    `json:"name"`
 	AvatarURL string    `json:"avatar_url"`
-	Role      string    `gorm:"default:'user'" json:"role"` // user, admin
+	Role      string    `gorm:"default:'user'" json:"role"`   // user, admin
+	Language  string    `gorm:"default:'en'" json:"language"` // language of emails, see SupportedLanguages
 	IsActive  bool      `gorm:"default:true" json:"is_active"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -52,7 +53,12 @@ type Plugin struct {
 	Price                    float64   `gorm:"type:decimal(10,2);default:0.00" json:"price"`
 	Currency                 string    `gorm:"default:'USD'" json:"currency"`
 	DefaultMaintenanceMonths int       `gorm:"default:12" json:"default_maintenance_months"`
-	Status                   string    `gorm:"default:'draft'" json:"status"` // draft, published, archived
+	GracePeriodDays          int       `gorm:"default:0" json:"grace_period_days"`            // days of unchanged access after maintenance expires
+	CollaboratorPermission   string    `gorm:"default:'pull'" json:"collaborator_permission"` // GitHub permission while maintenance is active
+	GoModulePath             string    `json:"go_module_path"`                                // served by the Go module proxy; defaults to github.com/<repo>
+	NpmPackage               string    `json:"npm_package"`                                   // served by the npm registry when set
+	ComposerPackage          string    `json:"composer_package"`                              // served by the Composer repository when set (vendor/name)
+	Status                   string    `gorm:"default:'draft'" json:"status"`                 // draft, published, archived
 	Category                 string    `json:"category"`
 	Tags                     []string  `gorm:"type:text[]" json:"tags"`
 	IconURL                  string    `json:"icon_url"`
@@ -95,10 +101,10 @@ type License struct {
 	UserID           uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
 	PluginID         uuid.UUID  `gorm:"type:uuid;not null" json:"plugin_id"`
 	OrderID          uuid.UUID  `gorm:"type:uuid;not null" json:"order_id"`
-	GitHubAccountID  uuid.UUID  `gorm:"type:uuid;column:git_hub_account_id;not null" json:"github_account_id"`
-	LicenseType      string     `gorm:"default:'permanent'" json:"license_type"` // permanent, trial
+	GitHubAccountID  *uuid.UUID `gorm:"type:uuid;column:git_hub_account_id" json:"github_account_id"` // nil until GitHub is linked
+	LicenseType      string     `gorm:"default:'permanent'" json:"license_type"`                      // permanent, trial
 	MaintenanceUntil time.Time  `gorm:"type:date;not null" json:"maintenance_until"`
-	Status           string     `gorm:"default:'active'" json:"status"` // active, expired, revoked
+	Status           string     `gorm:"default:'active'" json:"status"` // active, grace, expired, revoked
 	RevokedReason    string     `json:"revoked_reason"`
 	RevokedAt        *time.Time `json:"revoked_at"`
 	CreatedAt        time.Time  `json:"created_at"`
@@ -114,7 +120,7 @@ type License struct {
 type LicenseHistory struct {
 	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	LicenseID   uuid.UUID  `gorm:"type:uuid;not null" json:"license_id"`
-	Action      string     `gorm:"not null" json:"action"` // granted, expired, renewed, revoked, github_access_granted, github_access_revoked
+	Action      string     `gorm:"not null" json:"action"` // granted, grace_started, expired, renewed, revoked, github_access_granted, github_access_downgraded, github_access_revoked
 	PerformedBy *uuid.UUID `gorm:"type:uuid" json:"performed_by"`
 	Metadata    string     `gorm:"type:jsonb" json:"metadata"`
 	OccurredAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"occurred_at"`
@@ -138,16 +144,31 @@ type Tutorial struct {
 	Plugin *Plugin `gorm:"foreignKey:PluginID" json:"plugin,omitempty"`
 }
 
+// Delivery states of an EmailNotification in the outbox
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+	EmailStatusSkipped = "skipped" // the recipient opted out or the address is suppressed
+)
+
+// EmailNotification is an email in the outbox. It is written as pending in the same
+// transaction as the change it reports and sent by the email.deliver job.
 type EmailNotification struct {
 	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID           uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	ToEmail          string     `gorm:"not null;default:''" json:"to_email"`
 	NotificationType string     `gorm:"not null" json:"notification_type"`
+	Category         string     `gorm:"not null;default:''" json:"category"` // notification category users can opt out of, empty for transactional emails
 	Subject          string     `gorm:"not null" json:"subject"`
 	Body             string     `gorm:"not null" json:"body"`
+	TextBody         string     `gorm:"not null;default:''" json:"text_body"`
 	SentAt           *time.Time `json:"sent_at"`
-	Status           string     `gorm:"default:'pending'" json:"status"` // pending, sent, failed
+	Status           string     `gorm:"default:'pending'" json:"status"` // pending, sent, failed, skipped
+	Attempts         int        `gorm:"not null;default:0" json:"attempts"`
+	LastAttemptAt    *time.Time `json:"last_attempt_at"`
 	ErrorMessage     string     `json:"error_message"`
-	Metadata         string     `gorm:"type:jsonb" json:"metadata"`
+	Metadata         string     `gorm:"type:jsonb;default:'{}'" json:"metadata"`
 	CreatedAt        time.Time  `json:"created_at"`
 
 	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -205,6 +226,11 @@ func (o *Order) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// GraceEndsAt returns when the grace period after maintenance ends. Plugin must be loaded.
+func (l *License) GraceEndsAt() time.Time {
+	return l.MaintenanceUntil.AddDate(0, 0, l.Plugin.GracePeriodDays)
+}
+
 func (l *License) BeforeCreate(tx *gorm.DB) error {
 	if l.ID == uuid.Nil {
 		l.ID = uuid.New()
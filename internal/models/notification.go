@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Types of in-app notifications
+const (
+	NotificationTypeLicenseGranted    = "license_granted"
+	NotificationTypeLicenseRenewed    = "license_renewed"
+	NotificationTypeGitHubLinkNeeded  = "github_link_required"
+	NotificationTypeAccessGranted     = "access_granted"
+	NotificationTypeLicenseExpiring   = "license_expiring"
+	NotificationTypeGracePeriodEnding = "grace_period_ending"
+	NotificationTypeLicenseExpired    = "license_expired"
+	NotificationTypeAccessDowngraded  = "access_downgraded"
+	NotificationTypeOrderRefunded     = "order_refunded"
+	NotificationTypeReleaseAvailable  = "release_available"
+	NotificationTypeReleaseRenewal    = "release_renewal"
+)
+
+// Notification is an entry in a user's in-app notification center
+type Notification struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_notifications_user_created" json:"user_id"`
+	Type      string     `gorm:"not null" json:"type"`
+	Title     string     `gorm:"not null" json:"title"`
+	Message   string     `gorm:"type:text" json:"message"`
+	Link      string     `json:"link"`                                // storefront path or URL the notification opens
+	Data      string     `gorm:"type:jsonb;default:'{}'" json:"data"` // values the text was built from, e.g. plugin and days
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `gorm:"index:idx_notifications_user_created" json:"created_at"`
+}
+
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
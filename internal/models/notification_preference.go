@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notification categories users can opt out of. Transactional emails (receipts,
+// access changes, login links) have no category and are always sent.
+const (
+	NotificationMarketing            = "marketing"
+	NotificationExpiryReminders      = "expiry_reminders"
+	NotificationReleaseAnnouncements = "release_announcements"
+)
+
+// NotificationCategories lists the categories in the order users see them
+var NotificationCategories = []string{
+	NotificationMarketing,
+	NotificationExpiryReminders,
+	NotificationReleaseAnnouncements,
+}
+
+// IsNotificationCategory reports whether users can opt out of the category
+func IsNotificationCategory(category string) bool {
+	for _, c := range NotificationCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreference records which optional emails a user receives. Users
+// without a row receive all of them.
+type NotificationPreference struct {
+	UserID               uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	Marketing            bool      `gorm:"not null" json:"marketing"`
+	ExpiryReminders      bool      `gorm:"not null" json:"expiry_reminders"`
+	ReleaseAnnouncements bool      `gorm:"not null" json:"release_announcements"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// DefaultNotificationPreference is the preference of users who never changed it
+func DefaultNotificationPreference(userID uuid.UUID) *NotificationPreference {
+	return &NotificationPreference{
+		UserID:               userID,
+		Marketing:            true,
+		ExpiryReminders:      true,
+		ReleaseAnnouncements: true,
+	}
+}
+
+// Allows reports whether emails of the category may be sent. Emails without a
+// category are always allowed.
+func (p *NotificationPreference) Allows(category string) bool {
+	switch category {
+	case NotificationMarketing:
+		return p.Marketing
+	case NotificationExpiryReminders:
+		return p.ExpiryReminders
+	case NotificationReleaseAnnouncements:
+		return p.ReleaseAnnouncements
+	}
+	return true
+}
+
+// Set changes the preference for a category and reports whether the category exists
+func (p *NotificationPreference) Set(category string, enabled bool) bool {
+	switch category {
+	case NotificationMarketing:
+		p.Marketing = enabled
+	case NotificationExpiryReminders:
+		p.ExpiryReminders = enabled
+	case NotificationReleaseAnnouncements:
+		p.ReleaseAnnouncements = enabled
+	default:
+		return false
+	}
+	return true
+}
+
+// Reasons an address is suppressed
+const (
+	SuppressionHardBounce = "hard_bounce"
+	SuppressionComplaint  = "complaint"
+	SuppressionManual     = "manual"
+)
+
+// EmailSuppression is an address no email is sent to, typically because it hard-bounced
+type EmailSuppression struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Email     string    `gorm:"uniqueIndex;not null" json:"email"` // lower case
+	Reason    string    `gorm:"not null" json:"reason"`            // hard_bounce, complaint, manual
+	Detail    string    `json:"detail"`                            // bounce message or admin note
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *EmailSuppression) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// EmailWebhookToken is the token of a signed provider webhook that was already
+// processed, kept while its timestamp is recent enough to be accepted
+type EmailWebhookToken struct {
+	Token     string    `gorm:"primary_key" json:"token"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
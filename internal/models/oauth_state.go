@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// OAuthState is a pending OAuth login: the state parameter, its PKCE verifier and
+// where to send the user once logged in. States are single use.
+type OAuthState struct {
+	State        string    `gorm:"primaryKey" json:"state"`
+	Provider     string    `gorm:"not null;default:'github'" json:"provider"`
+	CodeVerifier string    `gorm:"not null" json:"code_verifier"`
+	RedirectTo   string    `json:"redirect_to"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PluginRelease is a version of a plugin synced from the GitHub releases and tags of its repository
+type PluginRelease struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	PluginID        uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_plugin_releases_tag" json:"plugin_id"`
+	TagName         string     `gorm:"not null;uniqueIndex:idx_plugin_releases_tag" json:"tag_name"`
+	Name            string     `json:"name"`
+	Body            string     `gorm:"type:text" json:"body"`
+	CommitSHA       string     `json:"commit_sha"`
+	Source          string     `gorm:"not null;default:'release'" json:"source"` // release, tag
+	GitHubReleaseID *int64     `gorm:"column:github_release_id" json:"github_release_id"`
+	Prerelease      bool       `gorm:"default:false" json:"prerelease"`
+	PublishedAt     time.Time  `gorm:"not null;index" json:"published_at"`
+	DownloadCount   int        `gorm:"default:0" json:"download_count"`
+	AnnouncedAt     *time.Time `json:"announced_at"` // when licensees were emailed about the release
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	Plugin Plugin               `gorm:"foreignKey:PluginID" json:"plugin,omitempty"`
+	Assets []PluginReleaseAsset `gorm:"foreignKey:ReleaseID" json:"assets,omitempty"`
+}
+
+// PluginReleaseAsset is a file attached to a GitHub release
+type PluginReleaseAsset struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	ReleaseID     uuid.UUID `gorm:"type:uuid;not null;index" json:"release_id"`
+	GitHubAssetID int64     `gorm:"column:github_asset_id;uniqueIndex;not null" json:"github_asset_id"`
+	Name          string    `gorm:"not null" json:"name"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	DownloadCount int       `gorm:"default:0" json:"download_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ReleaseDownload records one download of a release archive or asset by a license holder
+type ReleaseDownload struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	LicenseID uuid.UUID  `gorm:"type:uuid;not null;index" json:"license_id"`
+	ReleaseID uuid.UUID  `gorm:"type:uuid;not null;index" json:"release_id"`
+	AssetID   *uuid.UUID `gorm:"type:uuid" json:"asset_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Format    string     `json:"format"` // zip, tar, asset
+	Via       string     `json:"via"`    // session, signed_url
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+
+	Release PluginRelease `gorm:"foreignKey:ReleaseID" json:"release,omitempty"`
+}
+
+func (r *PluginRelease) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (a *PluginReleaseAsset) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+func (d *ReleaseDownload) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// CoversRelease reports whether a release was published before the license's maintenance ended.
+// Buyers keep those releases for good; revoked licenses keep nothing.
+func (l *License) CoversRelease(release *PluginRelease) bool {
+	if l.Status == "revoked" {
+		return false
+	}
+	return release.PublishedAt.Before(l.MaintenanceUntil.AddDate(0, 0, 1))
+}
+
+// ReleaseManifest caches a package manifest (package.json, composer.json) of a release.
+// Found is false when the release has no such file.
+type ReleaseManifest struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	ReleaseID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_release_manifests_path" json:"release_id"`
+	Path      string    `gorm:"not null;uniqueIndex:idx_release_manifests_path" json:"path"`
+	Content   string    `gorm:"type:text" json:"content"`
+	Found     bool      `gorm:"default:false" json:"found"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (m *ReleaseManifest) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Admin permissions. Reads cover GET requests to an admin route group, writes everything else.
+const (
+	PermPluginsRead    = "plugins:read"
+	PermPluginsWrite   = "plugins:write" // plugins, releases, GitHub repository sync
+	PermOrdersRead     = "orders:read"
+	PermOrdersWrite    = "orders:write" // payment status, refunds
+	PermLicensesRead   = "licenses:read"
+	PermLicensesWrite  = "licenses:write" // revoke, extend
+	PermContentRead    = "content:read"
+	PermContentWrite   = "content:write" // tutorials, categories, pages, uploads
+	PermJobsRead       = "jobs:read"
+	PermJobsWrite      = "jobs:write" // background jobs and scheduled tasks
+	PermStatisticsRead = "statistics:read"
+	PermSettingsRead   = "settings:read"
+	PermSettingsWrite  = "settings:write" // system settings, exchange rates
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermRolesRead      = "roles:read"
+	PermRolesWrite     = "roles:write"
+	PermAuditRead      = "audit:read"
+	PermEmailsRead     = "emails:read"
+	PermEmailsWrite    = "emails:write" // resend outbox emails, edit email templates
+	PermWebhooksRead   = "webhooks:read"
+	PermWebhooksWrite  = "webhooks:write" // outgoing webhook endpoints and redeliveries
+)
+
+// PermissionAll grants every permission, including ones added later
+const PermissionAll = "*"
+
+// Built-in roles, which cannot be deleted
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// AdminPermissions lists every permission a role can be granted
+var AdminPermissions = []string{
+	PermPluginsRead, PermPluginsWrite,
+	PermOrdersRead, PermOrdersWrite,
+	PermLicensesRead, PermLicensesWrite,
+	PermContentRead, PermContentWrite,
+	PermJobsRead, PermJobsWrite,
+	PermStatisticsRead,
+	PermSettingsRead, PermSettingsWrite,
+	PermUsersRead, PermUsersWrite,
+	PermRolesRead, PermRolesWrite,
+	PermAuditRead,
+	PermEmailsRead, PermEmailsWrite,
+	PermWebhooksRead, PermWebhooksWrite,
+}
+
+// Role is a named set of admin permissions assigned to users through User.Role.
+// Roles without permissions (like "user") have no access to the admin API.
+type Role struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
+	Description string    `json:"description"`
+	Permissions string    `gorm:"not null;default:''" json:"permissions"` // space separated, "*" for all
+	IsSystem    bool      `gorm:"default:false" json:"is_system"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// PermissionList returns the granted permissions, expanding "*"
+func (r *Role) PermissionList() []string {
+	if r.HasPermission(PermissionAll) {
+		return AdminPermissions
+	}
+	return strings.Fields(r.Permissions)
+}
+
+// HasPermission reports whether the role was granted a permission
+func (r *Role) HasPermission(permission string) bool {
+	for _, p := range strings.Fields(r.Permissions) {
+		if p == permission || p == PermissionAll {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the role holds every permission of other, so that
+// users can only hand out the access they have themselves
+func (r *Role) Covers(other *Role) bool {
+	if r.HasPermission(PermissionAll) {
+		return true
+	}
+	if other.HasPermission(PermissionAll) {
+		return false
+	}
+	for _, p := range strings.Fields(other.Permissions) {
+		if !r.HasPermission(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsAdminPermission reports whether permission is a known admin permission
+func IsAdminPermission(permission string) bool {
+	if permission == PermissionAll {
+		return true
+	}
+	for _, p := range AdminPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SchedulerRun records one execution of a scheduled job
+type SchedulerRun struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	JobName      string     `gorm:"not null;uniqueIndex:idx_scheduler_runs_slot;index" json:"job_name"`
+	Trigger      string     `gorm:"not null" json:"trigger"` // schedule, manual
+	TriggeredBy  *uuid.UUID `gorm:"type:uuid" json:"triggered_by"`
+	ScheduledFor *time.Time `gorm:"uniqueIndex:idx_scheduler_runs_slot" json:"scheduled_for"`
+	Instance     string     `json:"instance"`
+	Status       string     `gorm:"default:'running';index" json:"status"` // running, succeeded, failed
+	Processed    int        `gorm:"default:0" json:"processed"`
+	Failed       int        `gorm:"default:0" json:"failed"`
+	Error        string     `json:"error"`
+	StartedAt    time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+}
+
+func (r *SchedulerRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Events outgoing webhooks can subscribe to
+const (
+	WebhookEventOrderPaid      = "order.paid"
+	WebhookEventLicenseGranted = "license.granted" // new purchases and renewals
+	WebhookEventLicenseExpired = "license.expired"
+	WebhookEventLicenseRevoked = "license.revoked"
+	WebhookEventRefundCreated  = "refund.created"
+	WebhookEventPing           = "ping" // test deliveries, sent whatever the endpoint subscribes to
+
+	// WebhookEventAll subscribes an endpoint to every event, including ones added later
+	WebhookEventAll = "*"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookEvents lists every event an endpoint can subscribe to
+var WebhookEvents = []string{
+	WebhookEventOrderPaid,
+	WebhookEventLicenseGranted,
+	WebhookEventLicenseExpired,
+	WebhookEventLicenseRevoked,
+	WebhookEventRefundCreated,
+}
+
+// IsWebhookEvent reports whether event is a known webhook event, or "*" for all of them
+func IsWebhookEvent(event string) bool {
+	if event == WebhookEventAll {
+		return true
+	}
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpoint is a URL of the store owner's own systems that receives store events
+type WebhookEndpoint struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	URL         string    `gorm:"not null" json:"url"`
+	Description string    `json:"description"`
+	Secret      string    `gorm:"not null" json:"-"`                 // HMAC key of the signature header, shown once
+	Events      string    `gorm:"not null;default:''" json:"events"` // space separated, "*" for all
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (e *WebhookEndpoint) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// Subscribes reports whether the endpoint receives an event
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	for _, s := range strings.Fields(e.Events) {
+		if s == event || s == WebhookEventAll {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent to one endpoint, with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	EndpointID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"endpoint_id"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"event_id"` // same for every endpoint and redelivery of an event
+	Event          string     `gorm:"not null" json:"event"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"payload"`
+	Status         string     `gorm:"not null;default:'pending'" json:"status"` // pending, succeeded, failed
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"` // first KB of the latest response
+	ErrorMessage   string     `json:"error_message"`
+	DurationMs     int64      `json:"duration_ms"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	RedeliveryOf   *uuid.UUID `gorm:"type:uuid" json:"redelivery_of"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Endpoint WebhookEndpoint `gorm:"foreignKey:EndpointID" json:"endpoint,omitempty"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
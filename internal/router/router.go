@@ -7,13 +7,15 @@ import (
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/handlers"
 	"github.com/nodeloc/git-store/internal/middleware"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/scheduler"
 	"github.com/nodeloc/git-store/internal/services"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
+func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config, sched *scheduler.Scheduler, stateStore services.OAuthStateStore) {
 	// Middleware
-	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.CORSMiddleware(cfg))
 
 	// Serve static files (uploaded images)
 	r.Static("/uploads", "./uploads")
@@ -28,7 +30,7 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	}
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg)
+	authHandler := handlers.NewAuthHandler(db, cfg, stateStore)
 	pluginHandler := handlers.NewPluginHandler(db, cfg)
 	orderHandler := handlers.NewOrderHandler(db, cfg)
 	paymentHandler := handlers.NewPaymentHandler(db, cfg)
@@ -38,9 +40,29 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	pageHandler := handlers.NewPageHandler(db)
 	adminHandler := handlers.NewAdminHandler(db, cfg, githubSvc)
 	dashboardHandler := handlers.NewDashboardHandler(db, cfg)
-	githubWebhookHandler := handlers.NewGitHubWebhookHandler(db, cfg)
 	uploadHandler := handlers.NewUploadHandler("./uploads")
 	configHandler := handlers.NewConfigHandler(cfg)
+	jobHandler := handlers.NewJobHandler(db)
+	schedulerHandler := handlers.NewSchedulerHandler(db, sched)
+	releaseSvc := services.NewReleaseService(db, githubSvc)
+	githubWebhookHandler := handlers.NewGitHubWebhookHandler(db, cfg, releaseSvc)
+	releaseHandler := handlers.NewReleaseHandler(db, cfg, releaseSvc)
+	goProxyHandler := handlers.NewGoProxyHandler(db, cfg, releaseSvc)
+	registryHandler := handlers.NewRegistryHandler(db, cfg, releaseSvc)
+	tokenHandler := handlers.NewTokenHandler(db)
+	roleHandler := handlers.NewRoleHandler(db)
+	auditHandler := handlers.NewAuditHandler(db)
+	twoFactorHandler := handlers.NewTwoFactorHandler(db, cfg)
+	emailNotificationHandler := handlers.NewEmailNotificationHandler(db, cfg)
+	emailTemplateHandler := handlers.NewEmailTemplateHandler(db, cfg)
+	mailboxHandler := handlers.NewMailboxHandler(cfg)
+	notificationPreferenceHandler := handlers.NewNotificationPreferenceHandler(db, cfg)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	webhookHandler := handlers.NewWebhookHandler(db)
+	chatOpsHandler := handlers.NewChatOpsHandler(db, cfg)
+	checkoutRecoveryHandler := handlers.NewCheckoutRecoveryHandler(db)
+	emailSuppressionHandler := handlers.NewEmailSuppressionHandler(db, cfg)
+	stepUp := middleware.RequireRecentMFA(cfg)
 
 	// Dev auth handler (only in development)
 	var devAuthHandler *handlers.DevAuthHandler
@@ -69,10 +91,15 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		// Auth routes
 		auth := api.Group("/auth")
 		{
-			auth.GET("/github", authHandler.GitHubLogin)
-			auth.GET("/github/callback", authHandler.GitHubCallback)
-			auth.GET("/me", middleware.AuthMiddleware(cfg), authHandler.GetMe)
-			auth.POST("/logout", middleware.AuthMiddleware(cfg), authHandler.Logout)
+			auth.GET("/providers", authHandler.ListProviders)
+			auth.GET("/:provider", authHandler.OAuthLogin)
+			auth.GET("/:provider/callback", authHandler.OAuthCallback)
+			auth.POST("/email", authHandler.RequestMagicLink)
+			auth.POST("/email/verify", authHandler.VerifyMagicLink)
+			auth.GET("/me", middleware.AuthMiddleware(db, cfg), authHandler.GetMe)
+			auth.POST("/2fa/verify", middleware.AuthMiddleware(db, cfg), middleware.SessionOnly(), twoFactorHandler.Verify)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
 		}
 
 		// Public plugin routes
@@ -81,6 +108,23 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 			plugins.GET("", pluginHandler.ListPlugins)
 			plugins.GET("/id/:id", pluginHandler.GetPluginByID)
 			plugins.GET("/:slug", pluginHandler.GetPlugin)
+			plugins.GET("/:slug/releases", releaseHandler.ListPluginReleases)
+		}
+
+		// Signed release downloads (for CI, no session)
+		api.GET("/downloads", releaseHandler.SignedDownload)
+
+		// Go module proxy for licensed plugins (GOPROXY=<APP_URL>/api/goproxy), authenticated with access tokens
+		api.GET("/goproxy/*path", middleware.PackageAuthMiddleware(db), goProxyHandler.Serve)
+
+		// npm registry (registry=<APP_URL>/api/npm/) and Composer repository (<APP_URL>/api/composer)
+		api.GET("/npm/*path", middleware.PackageAuthMiddleware(db), registryHandler.Npm)
+		composer := api.Group("/composer")
+		composer.Use(middleware.PackageAuthMiddleware(db))
+		{
+			composer.GET("/packages.json", registryHandler.ComposerPackages)
+			composer.GET("/p2/*path", registryHandler.ComposerMetadata)
+			composer.GET("/dist/*path", registryHandler.ComposerDist)
 		}
 
 		// Public tutorial routes
@@ -111,34 +155,83 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 			webhooks.POST("/alipay", paymentHandler.AlipayNotify)
 			webhooks.GET("/alipay", paymentHandler.AlipayNotify) // 易支付使用 GET 请求
 			webhooks.POST("/github", githubWebhookHandler.HandleGitHubAppWebhook)
+			webhooks.POST("/email-bounces", emailSuppressionHandler.BounceWebhook)
 		}
 
+		// Signed unsubscribe links (List-Unsubscribe one-click and the storefront page)
+		api.GET("/unsubscribe", notificationPreferenceHandler.UnsubscribePage)
+		api.POST("/unsubscribe", notificationPreferenceHandler.Unsubscribe)
+
 		// Public license verification API (no auth required)
 		api.GET("/licenses/:id/verify", licenseHandler.VerifyLicense)
 	}
 
 	// Protected routes (require authentication)
 	protected := api.Group("")
-	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.AuthMiddleware(db, cfg))
 	{
 		// User routes
 		user := protected.Group("/user")
 		{
-			user.GET("/licenses", licenseHandler.GetUserLicenses)
-			user.GET("/orders", orderHandler.GetUserOrders)
-			user.GET("/github-accounts", authHandler.GetGitHubAccounts)
-			user.GET("/github-app/status", githubWebhookHandler.GetInstallationStatus)
+			user.GET("/licenses", middleware.RequireScope(models.ScopeLicensesRead), licenseHandler.GetUserLicenses)
+			user.GET("/orders", middleware.RequireScope(models.ScopeOrdersRead), orderHandler.GetUserOrders)
+			user.PUT("/profile", middleware.SessionOnly(), authHandler.UpdateProfile)
+			user.GET("/notification-preferences", middleware.SessionOnly(), notificationPreferenceHandler.GetPreferences)
+			user.PUT("/notification-preferences", middleware.SessionOnly(), notificationPreferenceHandler.UpdatePreferences)
+			user.GET("/github-accounts", middleware.SessionOnly(), authHandler.GetGitHubAccounts)
+			user.GET("/github-app/status", middleware.SessionOnly(), githubWebhookHandler.GetInstallationStatus)
+
+			// In-app notification center
+			notifications := user.Group("/notifications")
+			notifications.Use(middleware.SessionOnly())
+			{
+				notifications.GET("", notificationHandler.ListNotifications)
+				notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
+				notifications.GET("/stream", notificationHandler.Stream)
+				notifications.POST("/read-all", notificationHandler.MarkAllRead)
+				notifications.POST("/:id/read", notificationHandler.MarkRead)
+			}
+
+			// Login sessions (devices)
+			sessions := user.Group("/sessions")
+			sessions.Use(middleware.SessionOnly())
+			{
+				sessions.GET("", authHandler.ListSessions)
+				sessions.DELETE("", authHandler.RevokeOtherSessions)
+				sessions.DELETE("/:id", authHandler.RevokeSession)
+			}
+
+			// Two-factor authentication
+			twoFactor := user.Group("/2fa")
+			twoFactor.Use(middleware.SessionOnly())
+			{
+				twoFactor.GET("", twoFactorHandler.GetStatus)
+				twoFactor.POST("/setup", twoFactorHandler.Setup)
+				twoFactor.POST("/enable", twoFactorHandler.Enable)
+				twoFactor.POST("/disable", twoFactorHandler.Disable)
+				twoFactor.POST("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+			}
+
+			// Personal access tokens can only be managed from a login session
+			tokens := user.Group("/tokens")
+			tokens.Use(middleware.SessionOnly())
+			{
+				tokens.GET("", tokenHandler.ListTokens)
+				tokens.POST("", tokenHandler.CreateToken)
+				tokens.DELETE("/:id", tokenHandler.RevokeToken)
+			}
 		}
 
 		// Order routes
 		orders := protected.Group("/orders")
 		{
-			orders.POST("", orderHandler.CreateOrder)
-			orders.GET("/:id", orderHandler.GetOrder)
+			orders.POST("", middleware.RequireScope(models.ScopeOrdersWrite), orderHandler.CreateOrder)
+			orders.GET("/:id", middleware.RequireScope(models.ScopeOrdersRead), orderHandler.GetOrder)
 		}
 
 		// Payment routes
 		payments := protected.Group("/payments")
+		payments.Use(middleware.SessionOnly())
 		{
 			payments.POST("/stripe/create-intent", paymentHandler.CreateStripePaymentIntent)
 			payments.POST("/paypal/create-order", paymentHandler.CreatePayPalOrder)
@@ -149,25 +242,37 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		// License routes
 		licenses := protected.Group("/licenses")
 		{
-			licenses.GET("/:id", licenseHandler.GetLicense)
-			licenses.POST("/:id/renew", licenseHandler.RenewLicense)
-			licenses.GET("/:id/history", licenseHandler.GetLicenseHistory)
+			licenses.GET("/:id", middleware.RequireScope(models.ScopeLicensesRead), licenseHandler.GetLicense)
+			licenses.POST("/:id/renew", middleware.RequireScope(models.ScopeOrdersWrite), licenseHandler.RenewLicense)
+			licenses.GET("/:id/history", middleware.RequireScope(models.ScopeLicensesRead), licenseHandler.GetLicenseHistory)
+			licenses.GET("/:id/releases", middleware.RequireScope(models.ScopeLicensesRead), releaseHandler.ListLicenseReleases)
+			licenses.GET("/:id/releases/:tag/download", middleware.RequireScope(models.ScopeDownloads), releaseHandler.DownloadLicenseRelease)
+		}
+
+		// Release downloads
+		releases := protected.Group("/plugins/:slug/releases")
+		releases.Use(middleware.RequireScope(models.ScopeDownloads))
+		{
+			releases.GET("/:tag/download", releaseHandler.DownloadPluginRelease)
+			releases.POST("/:tag/download-url", releaseHandler.CreateDownloadURL)
 		}
 
 		// Tutorial routes (protected)
 		tutorialsProtected := protected.Group("/tutorials")
 		{
-			tutorialsProtected.GET("", tutorialHandler.ListTutorials)
+			tutorialsProtected.GET("", middleware.SessionOnly(), tutorialHandler.ListTutorials)
 		}
 	}
 
-	// Admin routes (require admin role)
+	// Admin routes (require a role with admin permissions; each group checks its own)
 	admin := api.Group("/admin")
-	admin.Use(middleware.AuthMiddleware(cfg))
-	admin.Use(middleware.AdminMiddleware())
+	admin.Use(middleware.AuthMiddleware(db, cfg))
+	admin.Use(middleware.SessionOnly())
+	admin.Use(middleware.AdminMiddleware(db))
+	admin.Use(middleware.AuditMiddleware(db))
 	{
 		// Plugin management
-		adminPlugins := admin.Group("/plugins")
+		adminPlugins := admin.Group("/plugins", middleware.ResourcePermission("plugins"))
 		{
 			adminPlugins.GET("", adminHandler.ListAllPlugins)
 			adminPlugins.POST("", adminHandler.CreatePlugin)
@@ -175,25 +280,35 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 			adminPlugins.PUT("/:id", adminHandler.UpdatePlugin)
 			adminPlugins.DELETE("/:id", adminHandler.DeletePlugin)
 			adminPlugins.POST("/sync-repos", adminHandler.SyncGitHubRepos)
+			adminPlugins.GET("/:id/releases", releaseHandler.ListAdminPluginReleases)
+			adminPlugins.GET("/:id/downloads", releaseHandler.GetPluginDownloadStats)
+			adminPlugins.POST("/:id/releases/sync", releaseHandler.SyncPluginReleases)
 		}
 
 		// GitHub integration
-		adminGitHub := admin.Group("/github")
+		adminGitHub := admin.Group("/github", middleware.ResourcePermission("plugins"))
 		{
 			adminGitHub.GET("/repositories", adminHandler.ListGitHubRepos)
 		}
 
 		// Order management
-		adminOrders := admin.Group("/orders")
+		adminOrders := admin.Group("/orders", middleware.ResourcePermission("orders"))
 		{
 			adminOrders.GET("", adminHandler.ListAllOrders)
 			adminOrders.GET("/:id", adminHandler.GetOrderByID)
 			adminOrders.PUT("/:id/status", adminHandler.UpdateOrderPaymentStatus)
-			adminOrders.POST("/:id/refund", adminHandler.RefundOrder)
+			adminOrders.POST("/:id/refund", stepUp, adminHandler.RefundOrder)
+		}
+
+		// Abandoned checkout reminders
+		adminCheckoutRecoveries := admin.Group("/checkout-recoveries", middleware.ResourcePermission("orders"))
+		{
+			adminCheckoutRecoveries.GET("", checkoutRecoveryHandler.ListRecoveries)
+			adminCheckoutRecoveries.GET("/stats", checkoutRecoveryHandler.GetStats)
 		}
 
 		// License management
-		adminLicenses := admin.Group("/licenses")
+		adminLicenses := admin.Group("/licenses", middleware.ResourcePermission("licenses"))
 		{
 			adminLicenses.GET("", adminHandler.ListAllLicenses)
 			adminLicenses.GET("/:id", adminHandler.GetLicenseByID)
@@ -201,8 +316,27 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 			adminLicenses.POST("/:id/extend", adminHandler.ExtendLicense)
 		}
 
+		// Background jobs
+		adminJobs := admin.Group("/jobs", middleware.ResourcePermission("jobs"))
+		{
+			adminJobs.GET("", jobHandler.ListJobs)
+			adminJobs.GET("/stats", jobHandler.GetJobStats)
+			adminJobs.GET("/:id", jobHandler.GetJob)
+			adminJobs.POST("/:id/retry", jobHandler.RetryJob)
+			adminJobs.POST("/:id/cancel", jobHandler.CancelJob)
+		}
+
+		// Scheduled jobs
+		adminScheduler := admin.Group("/scheduler", middleware.ResourcePermission("jobs"))
+		{
+			adminScheduler.GET("/jobs", schedulerHandler.ListSchedulerJobs)
+			adminScheduler.PUT("/jobs/:name", schedulerHandler.UpdateSchedulerJob)
+			adminScheduler.POST("/jobs/:name/run", schedulerHandler.TriggerSchedulerJob)
+			adminScheduler.GET("/runs", schedulerHandler.ListSchedulerRuns)
+		}
+
 		// Tutorial management
-		adminTutorials := admin.Group("/tutorials")
+		adminTutorials := admin.Group("/tutorials", middleware.ResourcePermission("content"))
 		{
 			adminTutorials.GET("", adminHandler.ListAllTutorials)
 			adminTutorials.POST("", adminHandler.CreateTutorial)
@@ -212,7 +346,7 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		}
 
 		// Categories management
-		adminCategories := admin.Group("/categories")
+		adminCategories := admin.Group("/categories", middleware.ResourcePermission("content"))
 		{
 			adminCategories.GET("", categoryHandler.GetAllCategories)
 			adminCategories.POST("", categoryHandler.CreateCategory)
@@ -221,7 +355,7 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		}
 
 		// Pages management
-		adminPages := admin.Group("/pages")
+		adminPages := admin.Group("/pages", middleware.ResourcePermission("content"))
 		{
 			adminPages.GET("", pageHandler.GetAdminPages)
 			adminPages.GET("/:id", pageHandler.GetAdminPageByID)
@@ -231,10 +365,10 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		}
 
 		// Image upload
-		admin.POST("/upload/image", uploadHandler.UploadImage)
+		admin.POST("/upload/image", middleware.RequirePermission(models.PermContentWrite), uploadHandler.UploadImage)
 
 		// Statistics & Dashboard
-		adminStats := admin.Group("/statistics")
+		adminStats := admin.Group("/statistics", middleware.RequirePermission(models.PermStatisticsRead))
 		{
 			adminStats.GET("/dashboard", dashboardHandler.GetDashboardStats)
 			adminStats.GET("/revenue", dashboardHandler.GetRevenueStats)
@@ -243,26 +377,100 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		}
 
 		// System settings
-		adminSettings := admin.Group("/settings")
+		adminSettings := admin.Group("/settings", middleware.ResourcePermission("settings"))
 		{
 			adminSettings.GET("", adminHandler.GetSettings)
-			adminSettings.PUT("", adminHandler.UpdateSettings)
+			adminSettings.PUT("", stepUp, adminHandler.UpdateSettings)
 		}
 
 		// Exchange rates management
-		adminExchangeRates := admin.Group("/exchange-rates")
+		adminExchangeRates := admin.Group("/exchange-rates", middleware.ResourcePermission("settings"))
 		{
 			adminExchangeRates.GET("", adminHandler.GetExchangeRates)
 			adminExchangeRates.POST("/update", adminHandler.UpdateExchangeRates)
 		}
 
+		// Chat-ops channels (configured in settings)
+		adminChatOps := admin.Group("/chatops", middleware.ResourcePermission("settings"))
+		{
+			adminChatOps.GET("/channels", chatOpsHandler.ListChannels)
+			adminChatOps.POST("/channels/:channel/test", chatOpsHandler.TestChannel)
+		}
+
 		// User management
-		adminUsers := admin.Group("/users")
+		adminUsers := admin.Group("/users", middleware.ResourcePermission("users"))
 		{
 			adminUsers.GET("", adminHandler.ListAllUsers)
 			adminUsers.GET("/:id", adminHandler.GetUserByID)
-			adminUsers.PUT("/:id", adminHandler.UpdateUser)
-			adminUsers.DELETE("/:id", adminHandler.DeleteUser)
+			adminUsers.PUT("/:id", stepUp, adminHandler.UpdateUser)
+			adminUsers.DELETE("/:id", stepUp, adminHandler.DeleteUser)
+		}
+
+		// Roles and permissions
+		adminRoles := admin.Group("/roles", middleware.ResourcePermission("roles"))
+		{
+			adminRoles.GET("", roleHandler.ListRoles)
+			adminRoles.POST("", stepUp, roleHandler.CreateRole)
+			adminRoles.PUT("/:id", stepUp, roleHandler.UpdateRole)
+			adminRoles.DELETE("/:id", stepUp, roleHandler.DeleteRole)
+		}
+
+		// Email outbox
+		adminEmails := admin.Group("/emails", middleware.ResourcePermission("emails"))
+		{
+			adminEmails.GET("", emailNotificationHandler.ListEmailNotifications)
+			adminEmails.GET("/:id", emailNotificationHandler.GetEmailNotification)
+			adminEmails.POST("/:id/resend", emailNotificationHandler.ResendEmailNotification)
+		}
+
+		// Email templates
+		adminEmailTemplates := admin.Group("/email-templates", middleware.ResourcePermission("emails"))
+		{
+			adminEmailTemplates.GET("", emailTemplateHandler.ListEmailTemplates)
+			adminEmailTemplates.GET("/:id", emailTemplateHandler.GetEmailTemplate)
+			adminEmailTemplates.PUT("/:id", emailTemplateHandler.UpdateEmailTemplate)
+			adminEmailTemplates.POST("/:id/reset", emailTemplateHandler.ResetEmailTemplate)
+			adminEmailTemplates.POST("/:id/preview", emailTemplateHandler.PreviewEmailTemplate)
+			adminEmailTemplates.POST("/:id/test", emailTemplateHandler.TestEmailTemplate)
+		}
+
+		// Suppressed addresses (hard bounces, complaints, manual)
+		adminEmailSuppressions := admin.Group("/email-suppressions", middleware.ResourcePermission("emails"))
+		{
+			adminEmailSuppressions.GET("", emailSuppressionHandler.ListSuppressions)
+			adminEmailSuppressions.POST("", emailSuppressionHandler.CreateSuppression)
+			adminEmailSuppressions.DELETE("/:id", emailSuppressionHandler.DeleteSuppression)
+		}
+
+		// Development mailbox (EMAIL_TRANSPORT=mailbox)
+		adminMailbox := admin.Group("/mailbox", middleware.ResourcePermission("emails"))
+		{
+			adminMailbox.GET("", mailboxHandler.ListMessages)
+			adminMailbox.GET("/:name", mailboxHandler.GetMessage)
+			adminMailbox.DELETE("/:name", mailboxHandler.DeleteMessage)
+			adminMailbox.DELETE("", mailboxHandler.ClearMessages)
+		}
+
+		// Outgoing webhooks
+		adminWebhooks := admin.Group("/webhooks", middleware.ResourcePermission("webhooks"))
+		{
+			adminWebhooks.GET("", webhookHandler.ListEndpoints)
+			adminWebhooks.POST("", stepUp, webhookHandler.CreateEndpoint)
+			adminWebhooks.GET("/deliveries", webhookHandler.ListDeliveries)
+			adminWebhooks.GET("/deliveries/:id", webhookHandler.GetDelivery)
+			adminWebhooks.POST("/deliveries/:id/redeliver", webhookHandler.Redeliver)
+			adminWebhooks.GET("/:id", webhookHandler.GetEndpoint)
+			adminWebhooks.PUT("/:id", stepUp, webhookHandler.UpdateEndpoint)
+			adminWebhooks.DELETE("/:id", stepUp, webhookHandler.DeleteEndpoint)
+			adminWebhooks.POST("/:id/rotate-secret", stepUp, webhookHandler.RotateSecret)
+			adminWebhooks.POST("/:id/test", webhookHandler.TestEndpoint)
+		}
+
+		// Audit log (append-only)
+		adminAudit := admin.Group("/audit-logs", middleware.RequirePermission(models.PermAuditRead))
+		{
+			adminAudit.GET("", auditHandler.ListAuditLogs)
+			adminAudit.GET("/export", auditHandler.ExportAuditLogs)
 		}
 	}
 }
@@ -0,0 +1,351 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/services"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown scheduler job")
+	ErrJobRunning = errors.New("job is already running")
+)
+
+const scheduleSettingPrefix = "schedule_"
+
+// RunResult summarises what a scheduled job did
+type RunResult struct {
+	Processed int
+	Failed    int
+}
+
+// task is a scheduled job that runs on at most one instance at a time
+type task struct {
+	name        string
+	description string
+	defaultSpec string
+	run         func(ctx context.Context) (RunResult, error)
+
+	spec    string
+	entryID cron.EntryID
+}
+
+// JobInfo describes a scheduled job for the admin API
+type JobInfo struct {
+	Name            string               `json:"name"`
+	Description     string               `json:"description"`
+	Schedule        string               `json:"schedule"`
+	DefaultSchedule string               `json:"default_schedule"`
+	NextRun         *time.Time           `json:"next_run"`
+	LastRun         *models.SchedulerRun `json:"last_run"`
+}
+
+// addTask registers a job using the schedule stored in system settings, falling back to defaultSpec
+func (s *Scheduler) addTask(name, description, defaultSpec string, run func(ctx context.Context) (RunResult, error)) {
+	t := &task{
+		name:        name,
+		description: description,
+		defaultSpec: defaultSpec,
+		run:         run,
+	}
+
+	setting := models.SystemSetting{
+		Key:         scheduleSettingPrefix + name,
+		Value:       defaultSpec,
+		Description: fmt.Sprintf("Cron schedule: %s", description),
+	}
+	if err := s.db.Where("key = ?", setting.Key).FirstOrCreate(&setting).Error; err != nil {
+		log.Printf("Scheduler: failed to load schedule for %s: %v", name, err)
+	}
+
+	spec := setting.Value
+	if _, err := cron.ParseStandard(spec); err != nil {
+		log.Printf("Scheduler: invalid schedule %q for %s, using default %q", spec, name, defaultSpec)
+		spec = defaultSpec
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[name] = t
+	s.order = append(s.order, name)
+	if err := s.schedule(t, spec); err != nil {
+		log.Printf("Scheduler: failed to schedule %s: %v", name, err)
+	}
+}
+
+// schedule (re)registers a task with cron. Callers must hold s.mu.
+func (s *Scheduler) schedule(t *task, spec string) error {
+	entryID, err := s.cron.AddFunc(spec, func() { s.runScheduled(t) })
+	if err != nil {
+		return err
+	}
+	if t.entryID != 0 {
+		s.cron.Remove(t.entryID)
+	}
+	t.entryID = entryID
+	t.spec = spec
+	return nil
+}
+
+// reloadSchedules applies schedule changes saved by other instances
+func (s *Scheduler) reloadSchedules() {
+	var settings []models.SystemSetting
+	if err := s.db.Where("key LIKE ?", scheduleSettingPrefix+"%").Find(&settings).Error; err != nil {
+		log.Printf("Scheduler: failed to reload schedules: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, setting := range settings {
+		t, ok := s.tasks[strings.TrimPrefix(setting.Key, scheduleSettingPrefix)]
+		if !ok || t.spec == setting.Value {
+			continue
+		}
+		if err := s.schedule(t, setting.Value); err != nil {
+			log.Printf("Scheduler: ignoring invalid schedule %q for %s: %v", setting.Value, t.name, err)
+			continue
+		}
+		log.Printf("Scheduler: %s rescheduled to %q", t.name, setting.Value)
+	}
+}
+
+// UpdateSchedule changes a job's cron schedule on this instance and persists it for the others
+func (s *Scheduler) UpdateSchedule(name, spec string) error {
+	if _, err := cron.ParseStandard(spec); err != nil {
+		return fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	// Upsert in case the row was deleted since the task was registered
+	setting := models.SystemSetting{
+		Key:         scheduleSettingPrefix + name,
+		Value:       spec,
+		Description: fmt.Sprintf("Cron schedule: %s", t.description),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	return s.schedule(t, spec)
+}
+
+// Jobs lists the registered jobs with their schedules and last run
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]JobInfo, 0, len(s.order))
+	for _, name := range s.order {
+		t := s.tasks[name]
+		info := JobInfo{
+			Name:            t.name,
+			Description:     t.description,
+			Schedule:        t.spec,
+			DefaultSchedule: t.defaultSpec,
+		}
+
+		if entry := s.cron.Entry(t.entryID); entry.Valid() && !entry.Next.IsZero() {
+			next := entry.Next
+			info.NextRun = &next
+		}
+
+		var lastRun models.SchedulerRun
+		if err := s.db.Where("job_name = ?", t.name).Order("started_at DESC").First(&lastRun).Error; err == nil {
+			info.LastRun = &lastRun
+		}
+
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Trigger starts a job immediately in the background and returns its run record
+func (s *Scheduler) Trigger(name string, triggeredBy *uuid.UUID) (*models.SchedulerRun, error) {
+	s.mu.RLock()
+	t, ok := s.tasks[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+
+	release, locked, err := s.acquire(context.Background(), t.name)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrJobRunning
+	}
+
+	run := &models.SchedulerRun{
+		JobName:     t.name,
+		Trigger:     "manual",
+		TriggeredBy: triggeredBy,
+		Instance:    s.instanceID,
+		Status:      "running",
+		StartedAt:   time.Now(),
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		release()
+		return nil, fmt.Errorf("failed to record run: %w", err)
+	}
+
+	go func() {
+		defer release()
+		s.execute(t, run)
+	}()
+
+	return run, nil
+}
+
+// runScheduled runs a cron tick unless another instance holds the job's lock or already ran this tick
+func (s *Scheduler) runScheduled(t *task) {
+	slot := time.Now().Truncate(time.Minute)
+
+	release, locked, err := s.acquire(context.Background(), t.name)
+	if err != nil {
+		log.Printf("Scheduler: failed to acquire lock for %s: %v", t.name, err)
+		return
+	}
+	if !locked {
+		log.Printf("Scheduler: %s is running on another instance, skipping", t.name)
+		return
+	}
+	defer release()
+
+	run := &models.SchedulerRun{
+		JobName:      t.name,
+		Trigger:      "schedule",
+		ScheduledFor: &slot,
+		Instance:     s.instanceID,
+		Status:       "running",
+		StartedAt:    time.Now(),
+	}
+	// The unique (job_name, scheduled_for) index rejects the run if another instance already took this tick
+	if err := s.db.Create(run).Error; err != nil {
+		log.Printf("Scheduler: %s already ran for %s, skipping", t.name, slot.Format(time.RFC3339))
+		return
+	}
+
+	s.execute(t, run)
+}
+
+func (s *Scheduler) execute(t *task, run *models.SchedulerRun) {
+	log.Printf("Running %s (%s)...", t.name, run.Trigger)
+
+	result, err := func() (result RunResult, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return t.run(context.Background())
+	}()
+
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Processed = result.Processed
+	run.Failed = result.Failed
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		log.Printf("Scheduler: %s failed: %v", t.name, err)
+	} else {
+		run.Status = "succeeded"
+		log.Printf("Scheduler: %s finished in %s (processed %d, failed %d)",
+			t.name, now.Sub(run.StartedAt).Round(time.Millisecond), result.Processed, result.Failed)
+	}
+
+	if err := s.db.Save(run).Error; err != nil {
+		log.Printf("Scheduler: failed to record run of %s: %v", t.name, err)
+	}
+
+	if err != nil || result.Failed > 0 {
+		s.notifyRunFailed(t, run)
+	}
+}
+
+// notifyRunFailed posts failed runs and runs that failed some items to chat
+func (s *Scheduler) notifyRunFailed(t *task, run *models.SchedulerRun) {
+	msg := services.ChatOpsMessage{
+		Event: services.ChatOpsSchedulerFailed,
+		Title: fmt.Sprintf("Scheduled job %s failed", t.name),
+		Text:  t.description,
+		Fields: []services.ChatOpsField{
+			{Name: "Trigger", Value: run.Trigger},
+			{Name: "Instance", Value: run.Instance},
+			{Name: "Processed", Value: strconv.Itoa(run.Processed)},
+			{Name: "Failed", Value: strconv.Itoa(run.Failed)},
+		},
+		Path: "/admin",
+	}
+	if run.Status != "failed" {
+		msg.Title = fmt.Sprintf("Scheduled job %s finished with %d failures", t.name, run.Failed)
+	}
+	if run.Error != "" {
+		msg.Fields = append(msg.Fields, services.ChatOpsField{Name: "Error", Value: run.Error})
+	}
+	if err := services.NotifyChatOps(s.db, msg); err != nil {
+		log.Printf("Scheduler: failed to post chat-ops message about %s: %v", t.name, err)
+	}
+}
+
+// acquire takes a Postgres session advisory lock for the job on a dedicated connection.
+// The returned release function unlocks it and returns the connection to the pool.
+func (s *Scheduler) acquire(ctx context.Context, name string) (func(), bool, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := advisoryLockKey(name)
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Printf("Scheduler: failed to release lock for %s: %v", name, err)
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
+
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
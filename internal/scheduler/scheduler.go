@@ -2,11 +2,16 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/jobs"
 	"github.com/nodeloc/git-store/internal/models"
 	"github.com/nodeloc/git-store/internal/services"
 	"github.com/robfig/cron/v3"
@@ -16,79 +21,146 @@ import (
 type Scheduler struct {
 	db              *gorm.DB
 	config          *config.Config
-	githubSvc       *services.GitHubService
+	cron            *cron.Cron
 	emailSvc        *services.EmailService
+	releaseSvc      *services.ReleaseService
 	exchangeRateSvc *services.ExchangeRateService
+	sessionSvc      *services.SessionService
+	recoverySvc     *services.CheckoutRecoveryService
+	digestSvc       *services.AdminDigestService
+	instanceID      string
+
+	mu    sync.RWMutex
+	tasks map[string]*task
+	order []string
 }
 
-// Helper function to split "owner/repo" format
-func splitRepoName(repoName string) []string {
-	return strings.Split(repoName, "/")
-}
-
-func SetupScheduler(c *cron.Cron, db *gorm.DB, cfg *config.Config) {
+func SetupScheduler(c *cron.Cron, db *gorm.DB, cfg *config.Config) *Scheduler {
+	hostname, _ := os.Hostname()
 	scheduler := &Scheduler{
 		db:              db,
 		config:          cfg,
+		cron:            c,
 		emailSvc:        services.NewEmailService(cfg, db),
 		exchangeRateSvc: services.NewExchangeRateService(db, cfg),
+		sessionSvc:      services.NewSessionService(db, cfg),
+		recoverySvc:     services.NewCheckoutRecoveryService(db, cfg),
+		digestSvc:       services.NewAdminDigestService(db, cfg),
+		instanceID:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		tasks:           make(map[string]*task),
 	}
 
-	// Initialize GitHub Service
+	var githubSvc *services.GitHubService
 	if cfg.GitHubAdminToken != "" {
-		scheduler.githubSvc = services.NewGitHubService(cfg)
-		log.Println("Scheduler: GitHub Service initialized")
+		githubSvc = services.NewGitHubService(cfg)
 	} else {
-		log.Println("Scheduler: GitHub Admin Token not configured, some features may not work")
+		log.Println("Scheduler: GitHub Admin Token not configured, release sync is disabled")
 	}
+	scheduler.releaseSvc = services.NewReleaseService(db, githubSvc)
 
-	// Schedule maintenance expiry check (default: daily at 2 AM)
-	c.AddFunc(cfg.CronMaintenanceCheck, func() {
-		log.Println("Running maintenance expiry check...")
-		scheduler.CheckMaintenanceExpiry()
-	})
+	// Maintenance expiry check (default: daily at 2 AM)
+	scheduler.addTask("maintenance_check", "Expire licenses, end grace periods and send expiry warnings",
+		cfg.CronMaintenanceCheck, scheduler.CheckMaintenanceExpiry)
 
-	// Schedule daily statistics aggregation (daily at 1 AM)
-	c.AddFunc("0 1 * * *", func() {
-		log.Println("Running daily statistics aggregation...")
-		scheduler.AggregateStatistics()
-	})
+	// Daily statistics aggregation (daily at 1 AM)
+	scheduler.addTask("aggregate_statistics", "Aggregate yesterday's statistics",
+		"0 1 * * *", scheduler.AggregateStatistics)
 
-	// Schedule daily exchange rate update (daily at 3 AM)
-	c.AddFunc("0 3 * * *", func() {
-		log.Println("Running daily exchange rate update...")
-		scheduler.UpdateExchangeRates()
-	})
+	// Daily exchange rate update (daily at 3 AM)
+	scheduler.addTask("update_exchange_rates", "Refresh currency exchange rates",
+		"0 3 * * *", scheduler.UpdateExchangeRates)
+
+	// Purge old succeeded background jobs (daily at 4 AM)
+	scheduler.addTask("purge_jobs", "Delete succeeded background jobs older than 30 days",
+		"0 4 * * *", scheduler.PurgeJobs)
+
+	// Purge ended login sessions (daily at 4:30 AM)
+	scheduler.addTask("purge_sessions", "Delete login sessions that ended more than 30 days ago",
+		"30 4 * * *", scheduler.PurgeSessions)
+
+	// Sync plugin releases from GitHub (hourly)
+	scheduler.addTask("sync_releases", "Sync plugin releases and versions from GitHub",
+		"0 * * * *", scheduler.SyncReleases)
+
+	// Remind buyers of unpaid orders (hourly at :15)
+	scheduler.addTask("recover_checkouts", "Email buyers whose orders are still unpaid, optionally with a discount",
+		"15 * * * *", scheduler.RecoverCheckouts)
+
+	// Admin digest emails (daily at 7 AM, weekly on Monday at 7:30 AM)
+	scheduler.addTask("admin_digest_daily", "Email yesterday's report to the admins",
+		"0 7 * * *", scheduler.SendDailyDigest)
+	scheduler.addTask("admin_digest_weekly", "Email last week's report to the admins",
+		"30 7 * * 1", scheduler.SendWeeklyDigest)
+
+	// Pick up schedule changes made on other instances
+	c.AddFunc("@every 1m", scheduler.reloadSchedules)
 
 	log.Println("Scheduler initialized")
+	return scheduler
 }
 
-// CheckMaintenanceExpiry checks for expired and expiring licenses
-func (s *Scheduler) CheckMaintenanceExpiry() {
-	ctx := context.Background()
+// CheckMaintenanceExpiry moves licenses through the grace period and sends expiry warnings
+func (s *Scheduler) CheckMaintenanceExpiry(ctx context.Context) (RunResult, error) {
+	var result RunResult
 	today := time.Now()
 
-	// 1. Find expired licenses (status=active, maintenance_until < today)
+	// 1. Find licenses whose maintenance just expired (status=active, maintenance_until < today)
 	var expiredLicenses []models.License
 	err := s.db.Preload("User").Preload("Plugin").Preload("GitHubAccount").
 		Where("status = ? AND maintenance_until < ?", "active", today).
 		Find(&expiredLicenses).Error
 
 	if err != nil {
-		log.Printf("Error finding expired licenses: %v", err)
-		return
+		return result, fmt.Errorf("failed to find expired licenses: %w", err)
 	}
 
 	log.Printf("Found %d expired licenses", len(expiredLicenses))
 
-	// Process each expired license
 	for _, license := range expiredLicenses {
-		if err := s.processExpiredLicense(ctx, &license); err != nil {
+		if err := s.processExpiredLicense(&license, today); err != nil {
 			log.Printf("Error processing expired license %s: %v", license.ID, err)
+			result.Failed++
+			continue
 		}
+		result.Processed++
 	}
 
-	// 2. Find licenses expiring in 30, 7, 1 days
+	// 2. Downgrade licenses whose grace period has ended, warn the ones still in it
+	var graceLicenses []models.License
+	if err := s.db.Preload("User").Preload("Plugin").
+		Where("status = ?", "grace").
+		Find(&graceLicenses).Error; err != nil {
+		return result, fmt.Errorf("failed to find licenses in grace period: %w", err)
+	}
+
+	warningDays := s.graceWarningDays()
+	for _, license := range graceLicenses {
+		graceEnd := license.GraceEndsAt()
+		if graceEnd.Before(today) {
+			if err := s.downgradeLicense(&license); err != nil {
+				log.Printf("Error downgrading license %s: %v", license.ID, err)
+				result.Failed++
+				continue
+			}
+			result.Processed++
+			continue
+		}
+
+		daysRemaining := daysUntil(today, graceEnd)
+		for _, days := range warningDays {
+			if days != daysRemaining {
+				continue
+			}
+			if err := s.sendGraceWarning(&license, days); err != nil {
+				log.Printf("Error sending grace warning for license %s: %v", license.ID, err)
+				result.Failed++
+				continue
+			}
+			result.Processed++
+		}
+	}
+
+	// 3. Find licenses expiring in 30, 7, 1 days
 	expiryWarningDays := []int{30, 7, 1}
 
 	for _, days := range expiryWarningDays {
@@ -104,6 +176,7 @@ func (s *Scheduler) CheckMaintenanceExpiry() {
 
 		if err != nil {
 			log.Printf("Error finding licenses expiring in %d days: %v", days, err)
+			result.Failed++
 			continue
 		}
 
@@ -113,67 +186,151 @@ func (s *Scheduler) CheckMaintenanceExpiry() {
 		for _, license := range expiringLicenses {
 			if err := s.sendExpiryWarning(&license, days); err != nil {
 				log.Printf("Error sending expiry warning for license %s: %v", license.ID, err)
+				result.Failed++
+				continue
 			}
+			result.Processed++
 		}
 	}
+
+	return result, nil
 }
 
-func (s *Scheduler) processExpiredLicense(ctx context.Context, license *models.License) error {
+// processExpiredLicense starts the grace period of a license, or downgrades it
+// right away when the plugin has no grace period or it has already passed
+func (s *Scheduler) processExpiredLicense(license *models.License, now time.Time) error {
 	log.Printf("Processing expired license: %s (Plugin: %s, User: %s)",
 		license.ID, license.Plugin.Name, license.User.Email)
 
-	// 1. Remove GitHub repository collaborator access
-	if s.githubSvc != nil && license.Plugin.GitHubRepoName != "" && license.GitHubAccount.Login != "" {
-		// Parse owner/repo
-		repoParts := splitRepoName(license.Plugin.GitHubRepoName)
-		if len(repoParts) == 2 {
-			owner := repoParts[0]
-			repo := repoParts[1]
-			username := license.GitHubAccount.Login
-
-			log.Printf("[License Expiry] Removing %s from %s/%s", username, owner, repo)
-
-			err := s.githubSvc.RemoveRepositoryCollaborator(ctx, owner, repo, username)
-			if err != nil {
-				log.Printf("Failed to remove collaborator: %v", err)
-				// Continue even if removal fails
-			} else {
-				log.Printf("Successfully removed %s from %s/%s", username, owner, repo)
-
-				// Log the action
-				history := models.LicenseHistory{
-					LicenseID:  license.ID,
-					Action:     "github_access_revoked",
-					OccurredAt: time.Now(),
-				}
-				s.db.Create(&history)
-			}
+	if license.Plugin.GracePeriodDays <= 0 {
+		return s.expireLicense(license, s.emailSvc.QueueMaintenanceExpiredEmail, models.NotificationTypeLicenseExpired)
+	}
+
+	if license.GraceEndsAt().Before(now) {
+		return s.downgradeLicense(license)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(license).Update("status", "grace").Error; err != nil {
+			return err
+		}
+		history := models.LicenseHistory{
+			LicenseID:  license.ID,
+			Action:     "grace_started",
+			Metadata:   fmt.Sprintf(`{"grace_until": "%s"}`, license.GraceEndsAt().Format("2006-01-02")),
+			OccurredAt: time.Now(),
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
 		}
+		if err := s.emailSvc.QueueMaintenanceExpiredEmail(tx, &license.User, &license.Plugin, license); err != nil {
+			return err
+		}
+		return services.NotifyLicense(tx, models.NotificationTypeLicenseExpired, &license.User, &license.Plugin, license, nil)
+	})
+	if err != nil {
+		return err
 	}
 
-	// 2. Update license status to expired
-	license.Status = "expired"
-	if err := s.db.Save(license).Error; err != nil {
+	log.Printf("License %s entered grace period until %s", license.ID, license.GraceEndsAt().Format("2006-01-02"))
+	return nil
+}
+
+// downgradeLicense ends the grace period and tells the buyer their access is read-only
+func (s *Scheduler) downgradeLicense(license *models.License) error {
+	return s.expireLicense(license, s.emailSvc.QueueAccessDowngradedEmail, models.NotificationTypeAccessDowngraded)
+}
+
+// expireLicense marks a license expired and queues the downgrade of its repository
+// access along with the email and notification telling the buyer and the
+// license.expired webhook
+func (s *Scheduler) expireLicense(license *models.License, queueEmail func(tx *gorm.DB, user *models.User, plugin *models.Plugin, license *models.License) error, notificationType string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(license).Update("status", "expired").Error; err != nil {
+			return err
+		}
+		history := models.LicenseHistory{
+			LicenseID:  license.ID,
+			Action:     "expired",
+			OccurredAt: time.Now(),
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		if err := queueEmail(tx, &license.User, &license.Plugin, license); err != nil {
+			return err
+		}
+		if err := services.NotifyLicense(tx, notificationType, &license.User, &license.Plugin, license, nil); err != nil {
+			return err
+		}
+		if err := services.EmitWebhookEvent(tx, models.WebhookEventLicenseExpired, services.LicenseWebhookData(license, &license.User, &license.Plugin)); err != nil {
+			return err
+		}
+		if license.Plugin.GitHubRepoName == "" {
+			return nil
+		}
+		return services.EnqueueAccessDowngrade(tx, license.ID)
+	})
+	if err != nil {
 		return err
 	}
 
-	// 3. Log expiry action
-	history := models.LicenseHistory{
-		LicenseID:  license.ID,
-		Action:     "expired",
-		OccurredAt: time.Now(),
+	log.Printf("License %s expired, repository access will be downgraded", license.ID)
+	return nil
+}
+
+func (s *Scheduler) sendGraceWarning(license *models.License, daysRemaining int) error {
+	notificationType := fmt.Sprintf("grace_period_ending_%d", daysRemaining)
+
+	var count int64
+	s.db.Model(&models.EmailNotification{}).
+		Where("user_id = ? AND notification_type = ? AND created_at > ?",
+			license.UserID, notificationType, time.Now().AddDate(0, 0, -1)).
+		Count(&count)
+
+	if count > 0 {
+		log.Printf("Grace warning already sent for license %s", license.ID)
+		return nil
 	}
-	if err := s.db.Create(&history).Error; err != nil {
-		log.Printf("Failed to create history record: %v", err)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.emailSvc.QueueGracePeriodEndingEmail(tx, &license.User, &license.Plugin, license, daysRemaining); err != nil {
+			return err
+		}
+		return services.NotifyLicense(tx, models.NotificationTypeGracePeriodEnding, &license.User, &license.Plugin, license, map[string]interface{}{
+			"days":        daysRemaining,
+			"grace_until": license.GraceEndsAt().Format("2006-01-02"),
+		})
+	})
+}
+
+// graceWarningDays reads the days before the end of the grace period on which warnings are sent
+func (s *Scheduler) graceWarningDays() []int {
+	setting := models.SystemSetting{
+		Key:         "grace_warning_days",
+		Value:       "7,1",
+		Description: "Comma-separated days before the grace period ends to send warning emails",
+	}
+	if err := s.db.Where("key = ?", setting.Key).FirstOrCreate(&setting).Error; err != nil {
+		log.Printf("Scheduler: failed to load grace warning days: %v", err)
 	}
 
-	// 4. Send expiry email notification
-	if err := s.emailSvc.SendMaintenanceExpiredEmail(&license.User, &license.Plugin, license); err != nil {
-		log.Printf("Failed to send expiry email: %v", err)
+	var days []int
+	for _, part := range strings.Split(setting.Value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			continue
+		}
+		days = append(days, n)
 	}
+	return days
+}
 
-	log.Printf("Successfully processed expired license %s", license.ID)
-	return nil
+// daysUntil counts calendar days from now until t
+func daysUntil(now, t time.Time) int {
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
 }
 
 func (s *Scheduler) sendExpiryWarning(license *models.License, daysRemaining int) error {
@@ -202,12 +359,19 @@ func (s *Scheduler) sendExpiryWarning(license *models.License, daysRemaining int
 		return nil
 	}
 
-	// Send warning email
-	return s.emailSvc.SendMaintenanceExpiringEmail(&license.User, &license.Plugin, license, daysRemaining)
+	// Queue warning email and notification
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.emailSvc.QueueMaintenanceExpiringEmail(tx, &license.User, &license.Plugin, license, daysRemaining); err != nil {
+			return err
+		}
+		return services.NotifyLicense(tx, models.NotificationTypeLicenseExpiring, &license.User, &license.Plugin, license, map[string]interface{}{
+			"days": daysRemaining,
+		})
+	})
 }
 
 // AggregateStatistics aggregates daily statistics
-func (s *Scheduler) AggregateStatistics() {
+func (s *Scheduler) AggregateStatistics(ctx context.Context) (RunResult, error) {
 	yesterday := time.Now().AddDate(0, 0, -1)
 	startOfDay := time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), 0, 0, 0, 0, yesterday.Location())
 	endOfDay := startOfDay.Add(24 * time.Hour)
@@ -277,24 +441,80 @@ func (s *Scheduler) AggregateStatistics() {
 	if err == gorm.ErrRecordNotFound {
 		// Create new statistic
 		if err := s.db.Create(&stat).Error; err != nil {
-			log.Printf("Error creating statistics: %v", err)
-			return
+			return RunResult{}, fmt.Errorf("failed to create statistics: %w", err)
 		}
 	} else {
 		// Update existing statistic
 		stat.ID = existingStat.ID
 		if err := s.db.Save(&stat).Error; err != nil {
-			log.Printf("Error updating statistics: %v", err)
-			return
+			return RunResult{}, fmt.Errorf("failed to update statistics: %w", err)
 		}
 	}
 
 	log.Printf("Successfully aggregated statistics for %s", startOfDay.Format("2006-01-02"))
+	return RunResult{Processed: 1}, nil
+}
+
+// PurgeJobs removes succeeded background jobs older than 30 days
+func (s *Scheduler) PurgeJobs(ctx context.Context) (RunResult, error) {
+	removed, err := jobs.PurgeSucceeded(s.db, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		return RunResult{}, fmt.Errorf("failed to purge background jobs: %w", err)
+	}
+	log.Printf("Purged %d succeeded background jobs", removed)
+	return RunResult{Processed: int(removed)}, nil
+}
+
+// PurgeSessions removes login sessions that expired or were revoked more than 30 days ago
+func (s *Scheduler) PurgeSessions(ctx context.Context) (RunResult, error) {
+	removed, err := s.sessionSvc.PurgeEnded(time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		return RunResult{}, fmt.Errorf("failed to purge sessions: %w", err)
+	}
+	log.Printf("Purged %d ended login sessions", removed)
+	return RunResult{Processed: int(removed)}, nil
+}
+
+// SyncReleases imports new GitHub releases and tags for every plugin
+func (s *Scheduler) SyncReleases(ctx context.Context) (RunResult, error) {
+	synced, failed, err := s.releaseSvc.SyncAll(ctx)
+	if err != nil {
+		return RunResult{}, fmt.Errorf("failed to sync releases: %w", err)
+	}
+	return RunResult{Processed: synced, Failed: failed}, nil
+}
+
+// RecoverCheckouts sends reminders for abandoned checkouts
+func (s *Scheduler) RecoverCheckouts(ctx context.Context) (RunResult, error) {
+	sent, failed, err := s.recoverySvc.RemindAbandoned(ctx)
+	if err != nil {
+		return RunResult{Processed: sent, Failed: failed}, fmt.Errorf("failed to send checkout reminders: %w", err)
+	}
+	return RunResult{Processed: sent, Failed: failed}, nil
+}
+
+// SendDailyDigest emails the report of yesterday to the admins
+func (s *Scheduler) SendDailyDigest(ctx context.Context) (RunResult, error) {
+	return s.sendDigest(ctx, services.DigestDaily)
+}
+
+// SendWeeklyDigest emails the report of the last seven days to the admins
+func (s *Scheduler) SendWeeklyDigest(ctx context.Context) (RunResult, error) {
+	return s.sendDigest(ctx, services.DigestWeekly)
+}
+
+func (s *Scheduler) sendDigest(ctx context.Context, period string) (RunResult, error) {
+	sent, failed, err := s.digestSvc.Send(ctx, period)
+	if err != nil {
+		return RunResult{Processed: sent, Failed: failed}, fmt.Errorf("failed to send %s digest: %w", period, err)
+	}
+	return RunResult{Processed: sent, Failed: failed}, nil
 }
 
 // UpdateExchangeRates 更新汇率
-func (s *Scheduler) UpdateExchangeRates() {
+func (s *Scheduler) UpdateExchangeRates(ctx context.Context) (RunResult, error) {
 	if err := s.exchangeRateSvc.UpdateExchangeRates(); err != nil {
-		log.Printf("❌ Failed to update exchange rates: %v", err)
+		return RunResult{}, fmt.Errorf("failed to update exchange rates: %w", err)
 	}
+	return RunResult{Processed: 1}, nil
 }
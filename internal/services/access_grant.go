@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/jobs"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	JobGrantAccess                = "github.grant_access"
+	JobDowngradeAccess            = "github.downgrade_access"
+	JobRevokeAccess               = "github.revoke_access"
+	defaultCollaboratorPermission = "pull"
+	// Permission kept by buyers whose maintenance and grace period have ended
+	downgradedPermission = "pull"
+)
+
+// GrantAccessPayload is the payload of a github.grant_access job.
+// An empty Permission uses the plugin's collaborator permission.
+type GrantAccessPayload struct {
+	LicenseID  uuid.UUID `json:"license_id"`
+	Permission string    `json:"permission"`
+}
+
+// LicenseAccessPayload identifies the license a downgrade or revocation job is about
+type LicenseAccessPayload struct {
+	LicenseID uuid.UUID `json:"license_id"`
+}
+
+// AccessGrantService invites license holders to plugin repositories
+type AccessGrantService struct {
+	db        *gorm.DB
+	config    *config.Config
+	githubSvc *GitHubService
+	emailSvc  *EmailService
+}
+
+func NewAccessGrantService(db *gorm.DB, cfg *config.Config, githubSvc *GitHubService) *AccessGrantService {
+	return &AccessGrantService{
+		db:        db,
+		config:    cfg,
+		githubSvc: githubSvc,
+		emailSvc:  NewEmailService(cfg, db),
+	}
+}
+
+// EnqueueAccessGrant queues a collaborator invitation for a license unless one is already pending
+func EnqueueAccessGrant(tx *gorm.DB, licenseID uuid.UUID) error {
+	payload := GrantAccessPayload{LicenseID: licenseID}
+	_, err := jobs.Enqueue(tx, JobGrantAccess, payload, jobs.UniqueKey("grant_access:"+licenseID.String()))
+	return err
+}
+
+// EnqueueAccessDowngrade queues lowering an expired license's collaborator permission to read-only
+func EnqueueAccessDowngrade(tx *gorm.DB, licenseID uuid.UUID) error {
+	_, err := jobs.Enqueue(tx, JobDowngradeAccess, LicenseAccessPayload{LicenseID: licenseID},
+		jobs.UniqueKey("downgrade_access:"+licenseID.String()))
+	return err
+}
+
+// EnqueueAccessRevocation queues removing a revoked license's holder from the repository
+func EnqueueAccessRevocation(tx *gorm.DB, licenseID uuid.UUID) error {
+	_, err := jobs.Enqueue(tx, JobRevokeAccess, LicenseAccessPayload{LicenseID: licenseID},
+		jobs.UniqueKey("revoke_access:"+licenseID.String()))
+	return err
+}
+
+// Register installs the access grant job handlers on the queue
+func (s *AccessGrantService) Register(queue *jobs.Queue) {
+	queue.Register(JobGrantAccess, jobs.Typed(s.grantAccess),
+		jobs.WithRetryPolicy(jobs.RetryPolicy{
+			MaxAttempts: 8,
+			BaseDelay:   time.Minute,
+			MaxDelay:    6 * time.Hour,
+		}),
+		jobs.OnDeadLetter(s.notifyGrantFailed),
+	)
+	queue.Register(JobDowngradeAccess, jobs.Typed(s.downgradeAccess),
+		jobs.WithRetryPolicy(jobs.RetryPolicy{
+			MaxAttempts: 8,
+			BaseDelay:   time.Minute,
+			MaxDelay:    6 * time.Hour,
+		}),
+	)
+	queue.Register(JobRevokeAccess, jobs.Typed(s.revokeAccess),
+		jobs.WithRetryPolicy(jobs.RetryPolicy{
+			MaxAttempts: 8,
+			BaseDelay:   time.Minute,
+			MaxDelay:    6 * time.Hour,
+		}),
+	)
+}
+
+func (s *AccessGrantService) grantAccess(ctx context.Context, payload GrantAccessPayload) error {
+	if s.githubSvc == nil {
+		return errors.New("GitHub service not configured")
+	}
+
+	var license models.License
+	if err := s.db.Preload("User").Preload("Plugin").Preload("GitHubAccount").
+		First(&license, "id = ?", payload.LicenseID).Error; err != nil {
+		return jobs.Permanent(fmt.Errorf("license not found: %w", err))
+	}
+
+	if license.Status != "active" && license.Status != "grace" {
+		log.Printf("[Access Grant] License %s is %s, skipping access grant", license.ID, license.Status)
+		return nil
+	}
+
+	owner, repo, ok := splitRepoFullName(license.Plugin.GitHubRepoName)
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("invalid repository name: %q", license.Plugin.GitHubRepoName))
+	}
+	if license.GitHubAccountID == nil {
+		log.Printf("[Access Grant] License %s has no linked GitHub account yet, skipping access grant", license.ID)
+		return nil
+	}
+	if license.GitHubAccount.Login == "" {
+		return jobs.Permanent(errors.New("license has no GitHub login"))
+	}
+
+	permission := payload.Permission
+	if permission == "" {
+		permission = license.Plugin.CollaboratorPermission
+	}
+	if permission == "" {
+		permission = defaultCollaboratorPermission
+	}
+
+	log.Printf("[Access Grant] Inviting %s to %s/%s (%s)", license.GitHubAccount.Login, owner, repo, permission)
+	if err := s.githubSvc.AddRepositoryCollaborator(ctx, owner, repo, license.GitHubAccount.Login, permission); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		history := models.LicenseHistory{
+			LicenseID:  license.ID,
+			Action:     "github_access_granted",
+			Metadata:   fmt.Sprintf(`{"repository": "%s/%s", "login": "%s"}`, owner, repo, license.GitHubAccount.Login),
+			OccurredAt: time.Now(),
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		if err := s.emailSvc.QueueAccessGrantedEmail(tx, &license.User, &license.Plugin, &license); err != nil {
+			return err
+		}
+		return NotifyLicense(tx, models.NotificationTypeAccessGranted, &license.User, &license.Plugin, &license, map[string]interface{}{
+			"github_login":   license.GitHubAccount.Login,
+			"invitation_url": fmt.Sprintf("https://github.com/%s/%s/invitations", owner, repo),
+		})
+	})
+}
+
+// downgradeAccess leaves an expired license's holder with read-only access to the repository
+func (s *AccessGrantService) downgradeAccess(ctx context.Context, payload LicenseAccessPayload) error {
+	license, owner, repo, err := s.loadLicenseRepo(payload.LicenseID)
+	if err != nil || license == nil {
+		return err
+	}
+	if license.Status != "expired" {
+		log.Printf("[Access Grant] License %s is %s, skipping downgrade", license.ID, license.Status)
+		return nil
+	}
+	if license.Plugin.CollaboratorPermission == "" || license.Plugin.CollaboratorPermission == downgradedPermission {
+		log.Printf("[Access Grant] %s already has %s on %s/%s, nothing to downgrade", license.GitHubAccount.Login, downgradedPermission, owner, repo)
+		return nil
+	}
+
+	// A current license for the same account keeps the plugin's full permission
+	var others int64
+	s.db.Model(&models.License{}).
+		Where("id <> ? AND plugin_id = ? AND git_hub_account_id = ? AND status IN ?",
+			license.ID, license.PluginID, license.GitHubAccountID, []string{"active", "grace"}).
+		Count(&others)
+	if others > 0 {
+		log.Printf("[Access Grant] %s holds a current license for %s/%s, keeping access", license.GitHubAccount.Login, owner, repo)
+		return nil
+	}
+
+	log.Printf("[Access Grant] Downgrading %s on %s/%s to %s", license.GitHubAccount.Login, owner, repo, downgradedPermission)
+	if err := s.githubSvc.AddRepositoryCollaborator(ctx, owner, repo, license.GitHubAccount.Login, downgradedPermission); err != nil {
+		return err
+	}
+
+	history := models.LicenseHistory{
+		LicenseID:  license.ID,
+		Action:     "github_access_downgraded",
+		Metadata:   fmt.Sprintf(`{"repository": "%s/%s", "login": "%s", "permission": "%s"}`, owner, repo, license.GitHubAccount.Login, downgradedPermission),
+		OccurredAt: time.Now(),
+	}
+	return s.db.Create(&history).Error
+}
+
+// revokeAccess removes a revoked license's holder from the repository
+func (s *AccessGrantService) revokeAccess(ctx context.Context, payload LicenseAccessPayload) error {
+	license, owner, repo, err := s.loadLicenseRepo(payload.LicenseID)
+	if err != nil || license == nil {
+		return err
+	}
+	if license.Status != "revoked" {
+		log.Printf("[Access Grant] License %s is %s, skipping access removal", license.ID, license.Status)
+		return nil
+	}
+
+	// Keep access when the same account still holds another usable license for the plugin
+	var others int64
+	s.db.Model(&models.License{}).
+		Where("id <> ? AND plugin_id = ? AND git_hub_account_id = ? AND status IN ?",
+			license.ID, license.PluginID, license.GitHubAccountID, []string{"active", "grace", "expired"}).
+		Count(&others)
+	if others > 0 {
+		log.Printf("[Access Grant] %s holds another license for %s/%s, keeping access", license.GitHubAccount.Login, owner, repo)
+		return nil
+	}
+
+	log.Printf("[Access Grant] Removing %s from %s/%s", license.GitHubAccount.Login, owner, repo)
+	if err := s.githubSvc.RemoveRepositoryCollaborator(ctx, owner, repo, license.GitHubAccount.Login); err != nil {
+		return err
+	}
+
+	history := models.LicenseHistory{
+		LicenseID:  license.ID,
+		Action:     "github_access_revoked",
+		Metadata:   fmt.Sprintf(`{"repository": "%s/%s", "login": "%s"}`, owner, repo, license.GitHubAccount.Login),
+		OccurredAt: time.Now(),
+	}
+	return s.db.Create(&history).Error
+}
+
+// loadLicenseRepo loads a license with its repository coordinates. A nil license
+// without error means the plugin has no repository and there is nothing to do.
+func (s *AccessGrantService) loadLicenseRepo(licenseID uuid.UUID) (*models.License, string, string, error) {
+	if s.githubSvc == nil {
+		return nil, "", "", errors.New("GitHub service not configured")
+	}
+
+	var license models.License
+	if err := s.db.Preload("Plugin").Preload("GitHubAccount").
+		First(&license, "id = ?", licenseID).Error; err != nil {
+		return nil, "", "", jobs.Permanent(fmt.Errorf("license not found: %w", err))
+	}
+	if license.Plugin.GitHubRepoName == "" {
+		return nil, "", "", nil
+	}
+
+	owner, repo, ok := splitRepoFullName(license.Plugin.GitHubRepoName)
+	if !ok {
+		return nil, "", "", jobs.Permanent(fmt.Errorf("invalid repository name: %q", license.Plugin.GitHubRepoName))
+	}
+	if license.GitHubAccountID == nil {
+		// Never linked to GitHub, so there is no repository access to change
+		return nil, "", "", nil
+	}
+	if license.GitHubAccount.Login == "" {
+		return nil, "", "", jobs.Permanent(errors.New("license has no GitHub login"))
+	}
+	return &license, owner, repo, nil
+}
+
+// notifyGrantFailed tells the admin by email and chat that a buyer could not be given
+// repository access
+func (s *AccessGrantService) notifyGrantFailed(ctx context.Context, job *models.Job) {
+	var payload GrantAccessPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return
+	}
+
+	var license models.License
+	if err := s.db.Preload("User").Preload("Plugin").Preload("GitHubAccount").
+		First(&license, "id = ?", payload.LicenseID).Error; err != nil {
+		return
+	}
+
+	if err := s.emailSvc.SendAccessGrantFailedEmail(&license, job.LastError); err != nil {
+		log.Printf("[Access Grant] Failed to notify admin about job %s: %v", job.ID, err)
+	}
+	err := NotifyChatOps(s.db, ChatOpsMessage{
+		Event: ChatOpsGrantFailed,
+		Title: fmt.Sprintf("GitHub access grant failed: %s", license.Plugin.Name),
+		Text:  fmt.Sprintf("Job %s gave up after %d attempts.", job.ID, job.Attempts),
+		Fields: []ChatOpsField{
+			{Name: "Customer", Value: license.User.Email},
+			{Name: "GitHub", Value: license.GitHubAccount.Login},
+			{Name: "Repository", Value: license.Plugin.GitHubRepoName},
+			{Name: "Error", Value: job.LastError},
+		},
+		Path: "/admin",
+	})
+	if err != nil {
+		log.Printf("[Access Grant] Failed to post chat-ops message about job %s: %v", job.ID, err)
+	}
+}
+
+// splitRepoFullName splits an "owner/repo" name into its parts
+func splitRepoFullName(fullName string) (string, string, bool) {
+	parts := strings.Split(fullName, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
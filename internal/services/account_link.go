@@ -0,0 +1,46 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// AttachGitHubAccount hands licenses bought before the user linked GitHub to the account
+// and queues their repository access. It returns how many licenses were attached.
+func AttachGitHubAccount(tx *gorm.DB, userID, githubAccountID uuid.UUID) (int, error) {
+	var licenses []models.License
+	if err := tx.Preload("Plugin").
+		Where("user_id = ? AND git_hub_account_id IS NULL AND status <> ?", userID, "revoked").
+		Find(&licenses).Error; err != nil {
+		return 0, fmt.Errorf("failed to find unlinked licenses: %w", err)
+	}
+
+	for _, license := range licenses {
+		if err := tx.Model(&license).Update("git_hub_account_id", githubAccountID).Error; err != nil {
+			return 0, fmt.Errorf("failed to link license %s: %w", license.ID, err)
+		}
+		if license.Plugin.GitHubRepoName == "" {
+			continue
+		}
+
+		var err error
+		switch license.Status {
+		case "active", "grace":
+			err = EnqueueAccessGrant(tx, license.ID)
+		case "expired":
+			err = EnqueueAccessDowngrade(tx, license.ID)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(licenses) > 0 {
+		log.Printf("[Account Link] Linked %d licenses of user %s to GitHub account %s", len(licenses), userID, githubAccountID)
+	}
+	return len(licenses), nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// Digest periods
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Settings of the admin digest
+const (
+	digestRecipientsSetting = "admin_digest_recipients"
+	digestPeriodsSetting    = "admin_digest_periods"
+)
+
+// digestListLimit caps the rows of each list in a digest; the counts cover all of them
+const digestListLimit = 20
+
+// digestExpiryDays is how far ahead the digest looks for expiring licenses
+const digestExpiryDays = 30
+
+// AdminDigest is the content of a daily or weekly report to the store admins
+type AdminDigest struct {
+	Period string // DigestDaily or DigestWeekly
+	From   string // first day of the period
+	To     string // last day of the period
+
+	Revenue       []DigestRevenue // paid orders by plugin and currency
+	RevenueTotals []DigestRevenue // paid orders by currency
+	NewUsers      int64
+	TotalUsers    int64
+
+	ExpiringLicenses     []DigestLicense
+	ExpiringLicenseCount int64
+	FailedPayments       []DigestOrder
+	FailedPaymentCount   int64
+	WebhookErrors        []DigestWebhookError
+	WebhookErrorCount    int64
+
+	DashboardURL string
+}
+
+// DigestRevenue is the revenue of paid orders in one currency
+type DigestRevenue struct {
+	PluginName string
+	Currency   string
+	Orders     int64
+	Amount     string
+}
+
+// DigestLicense is a license whose maintenance ends soon
+type DigestLicense struct {
+	PluginName       string
+	UserEmail        string
+	MaintenanceUntil string
+}
+
+// DigestOrder is an order whose payment failed
+type DigestOrder struct {
+	OrderNumber string
+	PluginName  string
+	UserEmail   string
+	Amount      string
+}
+
+// DigestWebhookError is a webhook delivery that ran out of attempts
+type DigestWebhookError struct {
+	URL      string
+	Event    string
+	Attempts int
+	Error    string
+}
+
+// AdminDigestService emails the daily and weekly reports to the admins
+type AdminDigestService struct {
+	db       *gorm.DB
+	config   *config.Config
+	emailSvc *EmailService
+}
+
+func NewAdminDigestService(db *gorm.DB, cfg *config.Config) *AdminDigestService {
+	return &AdminDigestService{
+		db:       db,
+		config:   cfg,
+		emailSvc: NewEmailService(cfg, db),
+	}
+}
+
+// Send builds the digest of the period that ended at the start of today and emails it
+// to every recipient. Nothing is sent when the period is turned off in settings.
+func (s *AdminDigestService) Send(ctx context.Context, period string) (sent, failed int, err error) {
+	recipients, enabled, err := s.settings(period)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !enabled {
+		log.Printf("[Digest] %s digest is turned off", period)
+		return 0, 0, nil
+	}
+	if len(recipients) == 0 {
+		log.Printf("[Digest] No recipients for the %s digest, set %s or ADMIN_EMAIL", period, digestRecipientsSetting)
+		return 0, 0, nil
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -1)
+	if period == DigestWeekly {
+		from = to.AddDate(0, 0, -7)
+	}
+
+	digest, err := s.Build(period, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, recipient := range recipients {
+		if ctx.Err() != nil {
+			return sent, failed, ctx.Err()
+		}
+		if err := s.sendTo(recipient, digest); err != nil {
+			log.Printf("[Digest] Failed to send %s digest to %s: %v", period, recipient, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	return sent, failed, nil
+}
+
+// sendTo emails the digest in the recipient's language if they have an account
+func (s *AdminDigestService) sendTo(recipient string, digest *AdminDigest) error {
+	language := models.DefaultLanguage
+	var user models.User
+	if err := s.db.Select("language").Where("LOWER(email) = ?", strings.ToLower(recipient)).First(&user).Error; err == nil {
+		language = user.Language
+	}
+
+	rendered, err := s.emailSvc.Render("admin_digest", language, EmailData{Digest: digest})
+	if err != nil {
+		return err
+	}
+	return s.emailSvc.SendEmail(recipient, rendered.Subject, rendered.HTMLBody, rendered.TextBody)
+}
+
+// settings reads the recipients, falling back to ADMIN_EMAIL, and whether the digest of a period is sent
+func (s *AdminDigestService) settings(period string) (recipients []string, enabled bool, err error) {
+	var rows []models.SystemSetting
+	if err := s.db.Where("key IN ?", []string{digestRecipientsSetting, digestPeriodsSetting}).Find(&rows).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to load digest settings: %w", err)
+	}
+
+	enabled = true
+	for _, row := range rows {
+		switch row.Key {
+		case digestRecipientsSetting:
+			for _, part := range strings.Split(row.Value, ",") {
+				addr, err := mail.ParseAddress(strings.TrimSpace(part))
+				if err != nil {
+					continue
+				}
+				recipients = append(recipients, addr.Address)
+			}
+		case digestPeriodsSetting:
+			enabled = false
+			for _, part := range strings.Split(row.Value, ",") {
+				if strings.TrimSpace(part) == period {
+					enabled = true
+				}
+			}
+		}
+	}
+	if len(recipients) == 0 && s.config.AdminEmail != "" {
+		recipients = []string{s.config.AdminEmail}
+	}
+	return recipients, enabled, nil
+}
+
+// Build collects the numbers of the period [from, to)
+func (s *AdminDigestService) Build(period string, from, to time.Time) (*AdminDigest, error) {
+	digest := &AdminDigest{
+		Period:       period,
+		From:         from.Format("2006-01-02"),
+		To:           to.AddDate(0, 0, -1).Format("2006-01-02"),
+		DashboardURL: s.config.FrontendURL + "/admin",
+	}
+
+	var revenue []struct {
+		PluginName string
+		Currency   string
+		Orders     int64
+		Amount     float64
+	}
+	if err := s.db.Model(&models.Order{}).
+		Select("plugins.name AS plugin_name, orders.currency, COUNT(*) AS orders, COALESCE(SUM(orders.amount), 0) AS amount").
+		Joins("JOIN plugins ON plugins.id = orders.plugin_id").
+		Where("orders.payment_status = ? AND orders.paid_at >= ? AND orders.paid_at < ?", "paid", from, to).
+		Group("plugins.name, orders.currency").
+		Order("amount DESC").
+		Scan(&revenue).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum revenue: %w", err)
+	}
+	totals := map[string]*DigestRevenue{}
+	amounts := map[string]float64{}
+	for _, row := range revenue {
+		digest.Revenue = append(digest.Revenue, DigestRevenue{
+			PluginName: row.PluginName,
+			Currency:   row.Currency,
+			Orders:     row.Orders,
+			Amount:     fmt.Sprintf("%.2f", row.Amount),
+		})
+		if _, ok := totals[row.Currency]; !ok {
+			totals[row.Currency] = &DigestRevenue{Currency: row.Currency}
+		}
+		totals[row.Currency].Orders += row.Orders
+		amounts[row.Currency] += row.Amount
+	}
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		total := totals[currency]
+		total.Amount = fmt.Sprintf("%.2f", amounts[currency])
+		digest.RevenueTotals = append(digest.RevenueTotals, *total)
+	}
+
+	if err := s.db.Model(&models.User{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&digest.NewUsers).Error; err != nil {
+		return nil, fmt.Errorf("failed to count new users: %w", err)
+	}
+	if err := s.db.Model(&models.User{}).Count(&digest.TotalUsers).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	expiring := s.db.Model(&models.License{}).
+		Where("status = ? AND maintenance_until >= ? AND maintenance_until < ?", "active", to, to.AddDate(0, 0, digestExpiryDays))
+	if err := expiring.Session(&gorm.Session{}).Count(&digest.ExpiringLicenseCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count expiring licenses: %w", err)
+	}
+	var licenses []models.License
+	if err := expiring.Session(&gorm.Session{}).Preload("User").Preload("Plugin").
+		Order("maintenance_until ASC").Limit(digestListLimit).Find(&licenses).Error; err != nil {
+		return nil, fmt.Errorf("failed to find expiring licenses: %w", err)
+	}
+	for _, license := range licenses {
+		digest.ExpiringLicenses = append(digest.ExpiringLicenses, DigestLicense{
+			PluginName:       license.Plugin.Name,
+			UserEmail:        license.User.Email,
+			MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
+		})
+	}
+
+	// Orders carry no failure time; a failed payment is the last change to the order
+	failedOrders := s.db.Model(&models.Order{}).
+		Where("payment_status = ? AND updated_at >= ? AND updated_at < ?", "failed", from, to)
+	if err := failedOrders.Session(&gorm.Session{}).Count(&digest.FailedPaymentCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count failed payments: %w", err)
+	}
+	var orders []models.Order
+	if err := failedOrders.Session(&gorm.Session{}).Preload("User").Preload("Plugin").
+		Order("updated_at DESC").Limit(digestListLimit).Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to find failed payments: %w", err)
+	}
+	for _, order := range orders {
+		digest.FailedPayments = append(digest.FailedPayments, DigestOrder{
+			OrderNumber: order.OrderNumber,
+			PluginName:  order.Plugin.Name,
+			UserEmail:   order.User.Email,
+			Amount:      fmt.Sprintf("%.2f %s", order.Amount, order.Currency),
+		})
+	}
+
+	failedDeliveries := s.db.Model(&models.WebhookDelivery{}).
+		Where("status = ? AND updated_at >= ? AND updated_at < ?", models.WebhookDeliveryFailed, from, to)
+	if err := failedDeliveries.Session(&gorm.Session{}).Count(&digest.WebhookErrorCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count webhook errors: %w", err)
+	}
+	var deliveries []models.WebhookDelivery
+	if err := failedDeliveries.Session(&gorm.Session{}).Omit("payload").Preload("Endpoint").
+		Order("updated_at DESC").Limit(digestListLimit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to find webhook errors: %w", err)
+	}
+	for _, delivery := range deliveries {
+		digest.WebhookErrors = append(digest.WebhookErrors, DigestWebhookError{
+			URL:      delivery.Endpoint.URL,
+			Event:    delivery.Event,
+			Attempts: delivery.Attempts,
+			Error:    delivery.ErrorMessage,
+		})
+	}
+
+	return digest, nil
+}
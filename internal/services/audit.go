@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// auditChange is the before and after value of one field
+type auditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditDiff returns the JSON encoded fields that differ between two versions of an entity.
+// Either side may be nil for creations and deletions. Fields hidden from JSON are never recorded.
+func AuditDiff(before, after interface{}) string {
+	from, to := auditFields(before), auditFields(after)
+
+	changes := map[string]auditChange{}
+	for key, value := range from {
+		if !reflect.DeepEqual(value, to[key]) {
+			changes[key] = auditChange{From: value, To: to[key]}
+		}
+	}
+	for key, value := range to {
+		if _, seen := from[key]; !seen && value != nil {
+			changes[key] = auditChange{To: value}
+		}
+	}
+
+	// Timestamps maintained by GORM are noise in a diff
+	delete(changes, "updated_at")
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// auditFields flattens a struct or map into its JSON fields
+func auditFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
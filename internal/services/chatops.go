@@ -0,0 +1,354 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/jobs"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// JobChatOpsNotify posts one message to one chat channel
+const JobChatOpsNotify = "chatops.notify"
+
+// Events posted to chat channels
+const (
+	ChatOpsOrderPaid       = "order.paid"
+	ChatOpsRefundCreated   = "refund.created"
+	ChatOpsGrantFailed     = "github_grant.failed"
+	ChatOpsSchedulerFailed = "scheduler.failed"
+	chatOpsAllEvents       = "*"
+)
+
+// Settings are chatops_<channel>_webhook_url and chatops_<channel>_events, plus the
+// chat of the Telegram bot
+const (
+	chatOpsSettingPrefix   = "chatops_"
+	chatOpsSettingURL      = "_webhook_url"
+	chatOpsSettingEvents   = "_events"
+	chatOpsSettingTelegram = "chatops_telegram_chat_id"
+)
+
+const chatOpsMaxFieldValueLen = 500
+
+// ChatOpsEvents lists every event a channel can be filtered to
+var ChatOpsEvents = []string{ChatOpsOrderPaid, ChatOpsRefundCreated, ChatOpsGrantFailed, ChatOpsSchedulerFailed}
+
+// ChatOpsChannels are the supported chat services
+var ChatOpsChannels = []string{"slack", "discord", "telegram"}
+
+// ChatOpsMessage is a chat message independent of the channel's format
+type ChatOpsMessage struct {
+	Event  string         `json:"event"`
+	Title  string         `json:"title"`
+	Text   string         `json:"text"`
+	Fields []ChatOpsField `json:"fields"`
+	Path   string         `json:"path"` // storefront path the message links to, e.g. /admin
+}
+
+type ChatOpsField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ChatOpsPayload is the payload of chatops.notify jobs
+type ChatOpsPayload struct {
+	Channel string         `json:"channel"`
+	Message ChatOpsMessage `json:"message"`
+}
+
+// ChatOpsChannelConfig is a channel's settings
+type ChatOpsChannelConfig struct {
+	Channel    string   `json:"channel"`
+	WebhookURL string   `json:"-"`
+	Configured bool     `json:"configured"`
+	Events     []string `json:"events"`
+	ChatID     string   `json:"chat_id,omitempty"` // telegram only
+}
+
+// Accepts reports whether the channel's filter lets an event through
+func (c *ChatOpsChannelConfig) Accepts(event string) bool {
+	for _, e := range c.Events {
+		if e == event || e == chatOpsAllEvents {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatOpsChannelConfigs reads the settings of every channel
+func ChatOpsChannelConfigs(db *gorm.DB) ([]ChatOpsChannelConfig, error) {
+	var settings []models.SystemSetting
+	if err := db.Where("key LIKE ?", chatOpsSettingPrefix+"%").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to load chat-ops settings: %w", err)
+	}
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		values[setting.Key] = strings.TrimSpace(setting.Value)
+	}
+
+	configs := make([]ChatOpsChannelConfig, 0, len(ChatOpsChannels))
+	for _, channel := range ChatOpsChannels {
+		cfg := ChatOpsChannelConfig{
+			Channel:    channel,
+			WebhookURL: values[chatOpsSettingPrefix+channel+chatOpsSettingURL],
+			Events:     []string{},
+		}
+		for _, event := range strings.Split(values[chatOpsSettingPrefix+channel+chatOpsSettingEvents], ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				cfg.Events = append(cfg.Events, event)
+			}
+		}
+		if channel == "telegram" {
+			cfg.ChatID = values[chatOpsSettingTelegram]
+		}
+		cfg.Configured = cfg.WebhookURL != "" && (channel != "telegram" || cfg.ChatID != "")
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// NotifyChatOps queues a message to every configured channel whose filter accepts
+// its event. Pass the transaction of the change it reports.
+func NotifyChatOps(tx *gorm.DB, msg ChatOpsMessage) error {
+	configs, err := ChatOpsChannelConfigs(tx)
+	if err != nil {
+		return err
+	}
+	for i := range configs {
+		if !configs[i].Configured || !configs[i].Accepts(msg.Event) {
+			continue
+		}
+		if _, err := jobs.Enqueue(tx, JobChatOpsNotify, ChatOpsPayload{Channel: configs[i].Channel, Message: msg}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChatOpsOrderMessage describes a paid or refunded order
+func ChatOpsOrderMessage(event string, order *models.Order, user *models.User, plugin *models.Plugin) ChatOpsMessage {
+	title := fmt.Sprintf("New order: %s", plugin.Name)
+	if event == ChatOpsRefundCreated {
+		title = fmt.Sprintf("Refund: %s", plugin.Name)
+	}
+	return ChatOpsMessage{
+		Event: event,
+		Title: title,
+		Fields: []ChatOpsField{
+			{Name: "Amount", Value: fmt.Sprintf("%.2f %s", order.Amount, order.Currency)},
+			{Name: "Order", Value: order.OrderNumber},
+			{Name: "Customer", Value: user.Email},
+			{Name: "Payment", Value: order.PaymentMethod},
+		},
+		Path: "/admin",
+	}
+}
+
+// ChatOpsService posts chat-ops messages to the configured channels
+type ChatOpsService struct {
+	db     *gorm.DB
+	config *config.Config
+	client *http.Client
+}
+
+func NewChatOpsService(db *gorm.DB, cfg *config.Config) *ChatOpsService {
+	return &ChatOpsService{
+		db:     db,
+		config: cfg,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Register installs the chat-ops job handler on the queue
+func (s *ChatOpsService) Register(queue *jobs.Queue) {
+	queue.Register(JobChatOpsNotify, jobs.Typed(s.deliver),
+		jobs.WithRetryPolicy(jobs.RetryPolicy{
+			MaxAttempts: 5,
+			BaseDelay:   30 * time.Second,
+			MaxDelay:    30 * time.Minute,
+		}),
+	)
+}
+
+// deliver posts a queued message with the channel's current settings
+func (s *ChatOpsService) deliver(ctx context.Context, payload ChatOpsPayload) error {
+	configs, err := ChatOpsChannelConfigs(s.db)
+	if err != nil {
+		return err
+	}
+	for i := range configs {
+		if configs[i].Channel != payload.Channel {
+			continue
+		}
+		if !configs[i].Configured {
+			// Turned off since the message was queued
+			return nil
+		}
+		return s.Send(ctx, &configs[i], &payload.Message)
+	}
+	return jobs.Permanent(fmt.Errorf("unknown chat-ops channel: %s", payload.Channel))
+}
+
+// Send posts a message to a channel right away
+func (s *ChatOpsService) Send(ctx context.Context, channel *ChatOpsChannelConfig, msg *ChatOpsMessage) error {
+	if !channel.Configured {
+		return errors.New("channel is not configured")
+	}
+
+	var body interface{}
+	switch channel.Channel {
+	case "slack":
+		body = s.slackBody(msg)
+	case "discord":
+		body = s.discordBody(msg)
+	case "telegram":
+		body = s.telegramBody(channel.ChatID, msg)
+	default:
+		return jobs.Permanent(fmt.Errorf("unknown chat-ops channel: %s", channel.Channel))
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.WebhookURL, bytes.NewReader(data))
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The URL holds the webhook token (the bot token for Telegram), keep it out of job errors and logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s request failed: %w", channel.Channel, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("%s returned %d: %s", channel.Channel, resp.StatusCode, strings.TrimSpace(string(detail)))
+		// Wrong URLs and malformed messages do not fix themselves
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return jobs.Permanent(err)
+		}
+		log.Printf("[ChatOps] %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *ChatOpsService) link(msg *ChatOpsMessage) string {
+	if msg.Path == "" {
+		return ""
+	}
+	return strings.TrimRight(s.config.FrontendURL, "/") + msg.Path
+}
+
+// chatOpsEmoji marks the kind of event at a glance in every channel
+func chatOpsEmoji(event string) string {
+	switch event {
+	case ChatOpsOrderPaid:
+		return "💰"
+	case ChatOpsRefundCreated:
+		return "↩️"
+	default:
+		return "🚨"
+	}
+}
+
+func truncateChatOpsValue(value string) string {
+	if runes := []rune(value); len(runes) > chatOpsMaxFieldValueLen {
+		return string(runes[:chatOpsMaxFieldValueLen]) + "…"
+	}
+	return value
+}
+
+// slackBody formats an incoming webhook message with mrkdwn
+func (s *ChatOpsService) slackBody(msg *ChatOpsMessage) map[string]interface{} {
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *%s*", chatOpsEmoji(msg.Event), escape(msg.Title))
+	if msg.Text != "" {
+		fmt.Fprintf(&b, "\n%s", escape(msg.Text))
+	}
+	for _, field := range msg.Fields {
+		fmt.Fprintf(&b, "\n*%s:* %s", escape(field.Name), escape(truncateChatOpsValue(field.Value)))
+	}
+	if link := s.link(msg); link != "" {
+		fmt.Fprintf(&b, "\n<%s|Open dashboard>", link)
+	}
+	return map[string]interface{}{"text": b.String()}
+}
+
+// discordBody formats a webhook message with an embed
+func (s *ChatOpsService) discordBody(msg *ChatOpsMessage) map[string]interface{} {
+	color := 0xE74C3C
+	switch msg.Event {
+	case ChatOpsOrderPaid:
+		color = 0x2ECC71
+	case ChatOpsRefundCreated:
+		color = 0xF39C12
+	}
+
+	fields := make([]map[string]interface{}, 0, len(msg.Fields))
+	for _, field := range msg.Fields {
+		fields = append(fields, map[string]interface{}{
+			"name":   field.Name,
+			"value":  truncateChatOpsValue(field.Value),
+			"inline": len(field.Value) <= 40,
+		})
+	}
+	embed := map[string]interface{}{
+		"title":       chatOpsEmoji(msg.Event) + " " + msg.Title,
+		"description": msg.Text,
+		"color":       color,
+		"fields":      fields,
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	}
+	if link := s.link(msg); link != "" {
+		embed["url"] = link
+	}
+	return map[string]interface{}{
+		"embeds":           []interface{}{embed},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+}
+
+// telegramBody formats a Bot API sendMessage request with HTML markup
+func (s *ChatOpsService) telegramBody(chatID string, msg *ChatOpsMessage) map[string]interface{} {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s <b>%s</b>", chatOpsEmoji(msg.Event), html.EscapeString(msg.Title))
+	if msg.Text != "" {
+		fmt.Fprintf(&b, "\n%s", html.EscapeString(msg.Text))
+	}
+	for _, field := range msg.Fields {
+		fmt.Fprintf(&b, "\n<b>%s:</b> %s", html.EscapeString(field.Name), html.EscapeString(truncateChatOpsValue(field.Value)))
+	}
+	if link := s.link(msg); link != "" {
+		fmt.Fprintf(&b, "\n<a href=\"%s\">Open dashboard</a>", html.EscapeString(link))
+	}
+	return map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     b.String(),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+}
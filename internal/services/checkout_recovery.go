@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Settings of abandoned checkout reminders, see checkoutRecoverySettings
+const checkoutRecoverySettingPrefix = "checkout_recovery_"
+
+// checkoutRecoveryBatchSize caps the reminders sent per run, so turning the job on
+// for a store with a backlog of pending orders does not send them all at once
+const checkoutRecoveryBatchSize = 200
+
+// checkoutRecoveryAttributionWindow is how long after a reminder a purchase of the
+// plugin counts as recovered by it
+const checkoutRecoveryAttributionWindow = 30 * 24 * time.Hour
+
+// errCheckoutNotPending is returned when an order was paid or cancelled while its reminder was prepared
+var errCheckoutNotPending = errors.New("order is no longer pending")
+
+// CheckoutRecoverySettings controls abandoned checkout reminders
+type CheckoutRecoverySettings struct {
+	DelayHours      int `json:"delay_hours"`      // remind once an order has been pending this long
+	MaxAgeDays      int `json:"max_age_days"`     // orders older than this are left alone
+	DiscountPercent int `json:"discount_percent"` // 0 sends reminders without a discount
+	DiscountHours   int `json:"discount_hours"`   // how long the discount can be used
+}
+
+// checkoutRecoverySettings reads the reminder settings, using the defaults for missing or invalid values
+func checkoutRecoverySettings(db *gorm.DB) (CheckoutRecoverySettings, error) {
+	settings := CheckoutRecoverySettings{DelayHours: 24, MaxAgeDays: 7, DiscountPercent: 0, DiscountHours: 48}
+
+	var rows []models.SystemSetting
+	if err := db.Where("key LIKE ?", checkoutRecoverySettingPrefix+"%").Find(&rows).Error; err != nil {
+		return settings, fmt.Errorf("failed to load checkout recovery settings: %w", err)
+	}
+	for _, row := range rows {
+		n, err := strconv.Atoi(strings.TrimSpace(row.Value))
+		if err != nil || n < 0 {
+			continue
+		}
+		switch strings.TrimPrefix(row.Key, checkoutRecoverySettingPrefix) {
+		case "delay_hours":
+			if n > 0 {
+				settings.DelayHours = n
+			}
+		case "max_age_days":
+			if n > 0 {
+				settings.MaxAgeDays = n
+			}
+		case "discount_percent":
+			if n < 100 {
+				settings.DiscountPercent = n
+			}
+		case "discount_hours":
+			if n > 0 {
+				settings.DiscountHours = n
+			}
+		}
+	}
+	return settings, nil
+}
+
+// CheckoutRecoveryService reminds buyers of orders they did not pay for
+type CheckoutRecoveryService struct {
+	db       *gorm.DB
+	config   *config.Config
+	emailSvc *EmailService
+}
+
+func NewCheckoutRecoveryService(db *gorm.DB, cfg *config.Config) *CheckoutRecoveryService {
+	return &CheckoutRecoveryService{
+		db:       db,
+		config:   cfg,
+		emailSvc: NewEmailService(cfg, db),
+	}
+}
+
+// RemindAbandoned emails the buyers of orders that have been pending for longer than
+// the configured delay. Only the newest pending order of a user for a plugin is
+// reminded, never one followed by a paid order, and a user gets at most one reminder
+// per plugin within the maximum order age.
+func (s *CheckoutRecoveryService) RemindAbandoned(ctx context.Context) (sent, failed int, err error) {
+	settings, err := checkoutRecoverySettings(s.db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	oldest := now.AddDate(0, 0, -settings.MaxAgeDays)
+
+	var orders []models.Order
+	err = s.db.Preload("User").Preload("Plugin").
+		Joins("JOIN users ON users.id = orders.user_id AND users.is_active").
+		Joins("JOIN plugins ON plugins.id = orders.plugin_id AND plugins.status = ?", "published").
+		Where("orders.payment_status = ? AND orders.created_at < ? AND orders.created_at >= ?",
+			"pending", now.Add(-time.Duration(settings.DelayHours)*time.Hour), oldest).
+		Where(`NOT EXISTS (SELECT 1 FROM orders later WHERE later.user_id = orders.user_id
+			AND later.plugin_id = orders.plugin_id AND later.created_at > orders.created_at
+			AND later.payment_status IN ?)`, []string{"pending", "paid"}).
+		Where(`NOT EXISTS (SELECT 1 FROM licenses WHERE licenses.user_id = orders.user_id
+			AND licenses.plugin_id = orders.plugin_id AND licenses.status = ? AND licenses.maintenance_until > ?)`, "active", now).
+		Where(`NOT EXISTS (SELECT 1 FROM checkout_recoveries WHERE checkout_recoveries.user_id = orders.user_id
+			AND checkout_recoveries.plugin_id = orders.plugin_id AND checkout_recoveries.sent_at >= ?)`, oldest).
+		Order("orders.created_at ASC").
+		Limit(checkoutRecoveryBatchSize).
+		Find(&orders).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find abandoned checkouts: %w", err)
+	}
+
+	for i := range orders {
+		if ctx.Err() != nil {
+			return sent, failed, ctx.Err()
+		}
+
+		order := &orders[i]
+		// Users who opted out of marketing emails get no reminder and no discount
+		skipReason, err := s.emailSvc.skipReason(s.db, order.UserID, order.User.Email, models.NotificationMarketing)
+		if err != nil {
+			log.Printf("[CheckoutRecovery] Failed to check preferences for order %s: %v", order.OrderNumber, err)
+			failed++
+			continue
+		}
+		if skipReason != "" {
+			continue
+		}
+
+		err = s.remind(order, settings, now)
+		if errors.Is(err, errCheckoutNotPending) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[CheckoutRecovery] Failed to remind order %s: %v", order.OrderNumber, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	log.Printf("[CheckoutRecovery] Sent %d abandoned checkout reminders (%d failed)", sent, failed)
+	return sent, failed, nil
+}
+
+// remind applies the discount, if any, to the order and queues the reminder email
+func (s *CheckoutRecoveryService) remind(order *models.Order, settings CheckoutRecoverySettings, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the order so a payment arriving meanwhile is not discounted afterwards
+		var locked models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&locked, "id = ? AND payment_status = ?", order.ID, "pending").Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errCheckoutNotPending
+			}
+			return err
+		}
+
+		recovery := models.CheckoutRecovery{
+			OrderID:          locked.ID,
+			UserID:           locked.UserID,
+			PluginID:         locked.PluginID,
+			Currency:         locked.Currency,
+			OriginalAmount:   locked.Amount,
+			DiscountedAmount: locked.Amount,
+			SentAt:           now,
+		}
+		data := EmailData{
+			UserName:    order.User.Name,
+			PluginName:  order.Plugin.Name,
+			OrderNumber: locked.OrderNumber,
+			Amount:      fmt.Sprintf("%.2f %s", locked.Amount, locked.Currency),
+			ResumeURL:   fmt.Sprintf("%s/purchase/%s?order_id=%s", s.config.FrontendURL, locked.PluginID, locked.ID),
+		}
+
+		if settings.DiscountPercent > 0 && locked.Amount > 0 {
+			expiresAt := now.Add(time.Duration(settings.DiscountHours) * time.Hour)
+			recovery.DiscountPercent = settings.DiscountPercent
+			recovery.DiscountedAmount = math.Round(locked.Amount*float64(100-settings.DiscountPercent)) / 100
+			recovery.DiscountExpiresAt = &expiresAt
+			if err := tx.Model(&locked).Update("amount", recovery.DiscountedAmount).Error; err != nil {
+				return fmt.Errorf("failed to discount order: %w", err)
+			}
+
+			data.Amount = fmt.Sprintf("%.2f %s", recovery.DiscountedAmount, locked.Currency)
+			data.OriginalAmount = fmt.Sprintf("%.2f %s", recovery.OriginalAmount, locked.Currency)
+			data.DiscountPercent = recovery.DiscountPercent
+			data.DiscountExpiresAt = expiresAt.UTC().Format("2006-01-02 15:04 UTC")
+		}
+
+		metadata := map[string]interface{}{"order_id": locked.ID, "plugin_id": locked.PluginID}
+		notification, err := s.emailSvc.QueueEmail(tx, &order.User, "checkout_recovery", "checkout_recovery", data, metadata)
+		if err != nil {
+			return err
+		}
+		recovery.EmailNotificationID = &notification.ID
+
+		if err := tx.Create(&recovery).Error; err != nil {
+			return fmt.Errorf("failed to record checkout recovery: %w", err)
+		}
+		return nil
+	})
+}
+
+// ExpireCheckoutDiscount puts a pending order back to its full price once the discount
+// offered by its reminder has run out. Call it before the order amount is shown or charged.
+func ExpireCheckoutDiscount(db *gorm.DB, order *models.Order) error {
+	if order.PaymentStatus != "pending" {
+		return nil
+	}
+
+	var recovery models.CheckoutRecovery
+	err := db.Where("order_id = ? AND discount_percent > 0", order.ID).First(&recovery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if recovery.DiscountActive(time.Now()) || order.Amount != recovery.DiscountedAmount {
+		return nil
+	}
+
+	if err := db.Model(order).Update("amount", recovery.OriginalAmount).Error; err != nil {
+		return fmt.Errorf("failed to restore order price: %w", err)
+	}
+	order.Amount = recovery.OriginalAmount
+	return nil
+}
+
+// MarkCheckoutRecovered attributes a paid order to the latest reminder the buyer got
+// for the plugin, whichever of their orders was paid. Pass the transaction that marks
+// the order as paid.
+func MarkCheckoutRecovered(tx *gorm.DB, order *models.Order) error {
+	paidAt := time.Now()
+	if order.PaidAt != nil {
+		paidAt = *order.PaidAt
+	}
+
+	var recovery models.CheckoutRecovery
+	err := tx.Where("user_id = ? AND plugin_id = ? AND recovered_at IS NULL AND sent_at BETWEEN ? AND ?",
+		order.UserID, order.PluginID, paidAt.Add(-checkoutRecoveryAttributionWindow), paidAt).
+		Order("sent_at DESC").First(&recovery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up checkout recovery: %w", err)
+	}
+
+	return tx.Model(&recovery).Updates(map[string]interface{}{
+		"recovered_at":       paidAt,
+		"recovered_order_id": order.ID,
+		"recovered_amount":   order.Amount,
+	}).Error
+}
+
+// CheckoutRecoveryRevenue is the revenue of recovered orders in one currency
+type CheckoutRecoveryRevenue struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// CheckoutRecoveryStats summarises the reminders sent since a point in time
+type CheckoutRecoveryStats struct {
+	Sent             int64                     `json:"sent"`
+	Discounted       int64                     `json:"discounted"`
+	Recovered        int64                     `json:"recovered"`
+	ConversionRate   float64                   `json:"conversion_rate"` // recovered / sent, 0 to 1
+	RecoveredRevenue []CheckoutRecoveryRevenue `json:"recovered_revenue"`
+}
+
+// GetCheckoutRecoveryStats counts the reminders sent since a time and how many of them were recovered
+func GetCheckoutRecoveryStats(db *gorm.DB, since time.Time) (*CheckoutRecoveryStats, error) {
+	stats := &CheckoutRecoveryStats{RecoveredRevenue: []CheckoutRecoveryRevenue{}}
+	query := func() *gorm.DB {
+		return db.Model(&models.CheckoutRecovery{}).Where("sent_at >= ?", since)
+	}
+
+	if err := query().Count(&stats.Sent).Error; err != nil {
+		return nil, err
+	}
+	if err := query().Where("discount_percent > 0").Count(&stats.Discounted).Error; err != nil {
+		return nil, err
+	}
+	if err := query().Where("recovered_at IS NOT NULL").Count(&stats.Recovered).Error; err != nil {
+		return nil, err
+	}
+	if err := query().Where("recovered_at IS NOT NULL").
+		Select("currency, COALESCE(SUM(recovered_amount), 0) AS amount").
+		Group("currency").Order("currency").
+		Scan(&stats.RecoveredRevenue).Error; err != nil {
+		return nil, err
+	}
+
+	if stats.Sent > 0 {
+		stats.ConversionRate = float64(stats.Recovered) / float64(stats.Sent)
+	}
+	return stats, nil
+}
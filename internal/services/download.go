@@ -0,0 +1,121 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// DownloadGrant is what a signed download URL allows: one release of one license until ExpiresAt
+type DownloadGrant struct {
+	LicenseID uuid.UUID
+	ReleaseID uuid.UUID
+	AssetID   *uuid.UUID
+	Format    string
+	ExpiresAt time.Time
+}
+
+// DownloadService signs download URLs and records release downloads
+type DownloadService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+func NewDownloadService(db *gorm.DB, cfg *config.Config) *DownloadService {
+	return &DownloadService{db: db, config: cfg}
+}
+
+// SignedURL returns a URL that downloads the granted release without further authentication
+func (s *DownloadService) SignedURL(grant DownloadGrant) string {
+	query := url.Values{}
+	query.Set("license_id", grant.LicenseID.String())
+	query.Set("release_id", grant.ReleaseID.String())
+	if grant.AssetID != nil {
+		query.Set("asset_id", grant.AssetID.String())
+	} else {
+		query.Set("format", grant.Format)
+	}
+	query.Set("expires", strconv.FormatInt(grant.ExpiresAt.Unix(), 10))
+	query.Set("signature", s.sign(grant))
+	return fmt.Sprintf("%s/api/downloads?%s", s.config.AppURL, query.Encode())
+}
+
+// ParseSignedURL validates the query of a signed download URL and returns its grant
+func (s *DownloadService) ParseSignedURL(query url.Values) (*DownloadGrant, error) {
+	licenseID, err := uuid.Parse(query.Get("license_id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid license_id")
+	}
+	releaseID, err := uuid.Parse(query.Get("release_id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid release_id")
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expires")
+	}
+
+	grant := &DownloadGrant{
+		LicenseID: licenseID,
+		ReleaseID: releaseID,
+		Format:    query.Get("format"),
+		ExpiresAt: time.Unix(expires, 0),
+	}
+	if assetID := query.Get("asset_id"); assetID != "" {
+		id, err := uuid.Parse(assetID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset_id")
+		}
+		grant.AssetID = &id
+		grant.Format = ""
+	}
+
+	if !hmac.Equal([]byte(s.sign(*grant)), []byte(query.Get("signature"))) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	if time.Now().After(grant.ExpiresAt) {
+		return nil, fmt.Errorf("download link has expired")
+	}
+	return grant, nil
+}
+
+func (s *DownloadService) sign(grant DownloadGrant) string {
+	asset := ""
+	if grant.AssetID != nil {
+		asset = grant.AssetID.String()
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.JWTSecret))
+	fmt.Fprintf(mac, "download:%s:%s:%s:%s:%d",
+		grant.LicenseID, grant.ReleaseID, asset, grant.Format, grant.ExpiresAt.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Record stores a download and bumps the release, asset and plugin download counters
+func (s *DownloadService) Record(download *models.ReleaseDownload, pluginID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(download).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PluginRelease{}).Where("id = ?", download.ReleaseID).
+			UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error; err != nil {
+			return err
+		}
+		if download.AssetID != nil {
+			if err := tx.Model(&models.PluginReleaseAsset{}).Where("id = ?", *download.AssetID).
+				UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Plugin{}).Where("id = ?", pluginID).
+			UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error
+	})
+}
@@ -1,352 +1,583 @@
 package services
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/jobs"
 	"github.com/nodeloc/git-store/internal/models"
-	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
 
+// JobDeliverEmail sends a pending EmailNotification from the outbox
+const JobDeliverEmail = "email.deliver"
+
+// EmailDeliveryPayload is the payload of email delivery jobs
+type EmailDeliveryPayload struct {
+	NotificationID uuid.UUID `json:"notification_id"`
+}
+
 type EmailService struct {
-	config *config.Config
-	db     *gorm.DB
-	dialer *gomail.Dialer
+	config    *config.Config
+	db        *gorm.DB
+	transport EmailTransport
 }
 
 type EmailData struct {
-	UserName         string
-	PluginName       string
-	OrderNumber      string
-	Amount           string
-	MaintenanceUntil string
-	DaysRemaining    int
-	RepoURL          string
-	TutorialURL      string
-	RenewalURL       string
-	SupportEmail     string
-	SiteName         string
+	UserName          string
+	PluginName        string
+	OrderNumber       string
+	Amount            string
+	MaintenanceUntil  string
+	GraceUntil        string
+	DaysRemaining     int
+	RepoURL           string
+	TutorialURL       string
+	RenewalURL        string
+	SupportEmail      string
+	SiteName          string
+	GitHubLogin       string
+	ErrorMessage      string
+	LoginURL          string
+	LinkTTLMinutes    int
+	Version           string
+	ReleaseNotes      string // release changelog, plain text or Markdown
+	ReleaseURL        string
+	UnsubscribeURL    string // set for emails users can opt out of
+	ResumeURL         string // checkout page of a pending order
+	DiscountPercent   int
+	DiscountExpiresAt string
+	OriginalAmount    string       // amount before the discount
+	Digest            *AdminDigest // admin digest report
+	Year              int
 }
 
 func NewEmailService(cfg *config.Config, db *gorm.DB) *EmailService {
-	dialer := gomail.NewDialer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword)
-
 	return &EmailService{
-		config: cfg,
-		db:     db,
-		dialer: dialer,
+		config:    cfg,
+		db:        db,
+		transport: NewEmailTransport(cfg),
 	}
 }
 
-func (s *EmailService) SendEmail(to, subject, htmlBody string) error {
-	m := gomail.NewMessage()
-	m.SetHeader("From", fmt.Sprintf("%s <%s>", s.config.SMTPFromName, s.config.SMTPFrom))
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", htmlBody)
+// Register adds the outbox delivery job to the queue. Failed sends are retried
+// with backoff and the notification is marked failed once attempts run out.
+func (s *EmailService) Register(queue *jobs.Queue) {
+	queue.Register(JobDeliverEmail, jobs.Typed(s.deliver),
+		jobs.WithRetryPolicy(jobs.RetryPolicy{
+			MaxAttempts: 6,
+			BaseDelay:   time.Minute,
+			MaxDelay:    2 * time.Hour,
+		}),
+		jobs.OnDeadLetter(s.markDeliveryFailed),
+	)
+}
+
+// SendEmail sends a multipart email. The text part is generated from the HTML when textBody is empty.
+func (s *EmailService) SendEmail(to, subject, htmlBody, textBody string) error {
+	return s.send(&EmailMessage{
+		To:       to,
+		Subject:  subject,
+		HTMLBody: htmlBody,
+		TextBody: textBody,
+	})
+}
+
+// send is the single path every email leaves through. Suppressed addresses are
+// refused with ErrEmailSuppressed and SMTP hard bounces suppress the address.
+func (s *EmailService) send(msg *EmailMessage) error {
+	suppressed, err := s.IsSuppressed(s.db, msg.To)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		return ErrEmailSuppressed
+	}
 
-	if err := s.dialer.DialAndSend(m); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+	msg.From = fmt.Sprintf("%s <%s>", s.config.SMTPFromName, s.config.SMTPFrom)
+	if msg.TextBody == "" {
+		msg.TextBody = HTMLToText(msg.HTMLBody)
 	}
 
-	return nil
+	err = s.transport.Send(context.Background(), msg)
+	if err != nil && isHardBounce(err) {
+		if bounceErr := s.RecordBounce(msg.To, true, false, err.Error()); bounceErr != nil {
+			log.Printf("[Email] Failed to suppress %s: %v", msg.To, bounceErr)
+		}
+	}
+	return err
 }
 
-func (s *EmailService) SendPurchaseSuccessEmail(user *models.User, plugin *models.Plugin, order *models.Order, license *models.License) error {
-	data := EmailData{
-		UserName:         user.Name,
-		PluginName:       plugin.Name,
-		OrderNumber:      order.OrderNumber,
-		Amount:           fmt.Sprintf("%.2f %s", order.Amount, order.Currency),
-		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
-		RepoURL:          plugin.GitHubRepoURL,
-		TutorialURL:      fmt.Sprintf("%s/tutorials/%s", s.config.FrontendURL, plugin.Slug),
-		SupportEmail:     s.config.AdminEmail,
-		SiteName:         "Plugin Store",
+// QueueEmail renders a template in the user's language into a pending notification and
+// enqueues its delivery. Pass the transaction of the change the email is about, so the
+// email is only sent if the change is committed. Emails the user opted out of, or to a
+// suppressed address, are recorded as skipped and not sent.
+func (s *EmailService) QueueEmail(tx *gorm.DB, user *models.User, notificationType, templateName string, data EmailData, metadata map[string]interface{}) (*models.EmailNotification, error) {
+	category := EmailCategory(templateName)
+	if category != "" {
+		data.UnsubscribeURL = s.UnsubscribeURL(user.ID, category)
 	}
 
-	subject := fmt.Sprintf("Purchase Successful - %s", plugin.Name)
-	htmlBody, err := s.renderTemplate("purchase_success", data)
+	skipReason, err := s.skipReason(tx, user.ID, user.Email, category)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Send email
-	if err := s.SendEmail(user.Email, subject, htmlBody); err != nil {
-		return err
+	rendered, err := s.Render(templateName, user.Language, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
 	}
 
-	// Log email notification
 	notification := &models.EmailNotification{
 		UserID:           user.ID,
-		NotificationType: "purchase_success",
-		Subject:          subject,
-		Body:             htmlBody,
-		Status:           "sent",
-		SentAt:           &time.Time{},
+		ToEmail:          user.Email,
+		NotificationType: notificationType,
+		Category:         category,
+		Subject:          rendered.Subject,
+		Body:             rendered.HTMLBody,
+		TextBody:         rendered.TextBody,
+		Status:           models.EmailStatusPending,
+		Metadata:         string(metadataJSON),
+	}
+	if skipReason != "" {
+		notification.Status = models.EmailStatusSkipped
+		notification.ErrorMessage = skipReason
 	}
-	*notification.SentAt = time.Now()
+	if err := tx.Create(notification).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue %s email: %w", notificationType, err)
+	}
+	if skipReason != "" {
+		return notification, nil
+	}
+
+	if _, err := jobs.Enqueue(tx, JobDeliverEmail, EmailDeliveryPayload{NotificationID: notification.ID}); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
 
-	return s.db.Create(notification).Error
+// Resend puts a notification back into the outbox, keeping its rendered content
+func (s *EmailService) Resend(notification *models.EmailNotification) error {
+	notification.Status = models.EmailStatusPending
+	notification.ErrorMessage = ""
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(notification).Updates(map[string]interface{}{
+			"status":        models.EmailStatusPending,
+			"error_message": "",
+		}).Error; err != nil {
+			return err
+		}
+		_, err := jobs.Enqueue(tx, JobDeliverEmail, EmailDeliveryPayload{NotificationID: notification.ID})
+		return err
+	})
 }
 
-func (s *EmailService) SendMaintenanceExpiringEmail(user *models.User, plugin *models.Plugin, license *models.License, daysRemaining int) error {
+// deliver sends a queued notification and records the outcome of the attempt
+func (s *EmailService) deliver(ctx context.Context, payload EmailDeliveryPayload) error {
+	var notification models.EmailNotification
+	if err := s.db.First(&notification, "id = ?", payload.NotificationID).Error; err != nil {
+		return jobs.Permanent(fmt.Errorf("email notification not found: %w", err))
+	}
+	if notification.Status == models.EmailStatusSent {
+		return nil
+	}
+
+	// Preferences may have changed since the email was queued
+	skipReason, err := s.skipReason(s.db, notification.UserID, notification.ToEmail, notification.Category)
+	if err != nil {
+		return err
+	}
+	if skipReason != "" {
+		return s.markSkipped(&notification, skipReason)
+	}
+
+	msg := &EmailMessage{
+		To:       notification.ToEmail,
+		Subject:  notification.Subject,
+		HTMLBody: notification.Body,
+		TextBody: notification.TextBody,
+	}
+	if notification.Category != "" {
+		msg.Headers = s.unsubscribeHeaders(notification.UserID, notification.Category)
+	}
+
+	now := time.Now()
+	sendErr := s.send(msg)
+	if errors.Is(sendErr, ErrEmailSuppressed) {
+		return s.markSkipped(&notification, "address is suppressed")
+	}
+
+	updates := map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_attempt_at": now,
+	}
+	if sendErr != nil {
+		updates["error_message"] = sendErr.Error()
+	} else {
+		updates["status"] = models.EmailStatusSent
+		updates["sent_at"] = now
+		updates["error_message"] = ""
+	}
+	if err := s.db.Model(&notification).Updates(updates).Error; err != nil {
+		log.Printf("[Email] Failed to record delivery of %s: %v", notification.ID, err)
+	}
+
+	if sendErr != nil {
+		log.Printf("[Email] Delivery of %s (%s) to %s failed: %v", notification.ID, notification.NotificationType, notification.ToEmail, sendErr)
+		if isHardBounce(sendErr) {
+			return jobs.Permanent(sendErr)
+		}
+	}
+	return sendErr
+}
+
+// skipReason explains why an email must not be sent to a user, or is empty if it may be
+func (s *EmailService) skipReason(db *gorm.DB, userID uuid.UUID, email, category string) (string, error) {
+	suppressed, err := s.IsSuppressed(db, email)
+	if err != nil {
+		return "", err
+	}
+	if suppressed {
+		return "address is suppressed", nil
+	}
+	if category == "" {
+		return "", nil
+	}
+
+	pref, err := s.NotificationPreferences(db, userID)
+	if err != nil {
+		return "", err
+	}
+	if !pref.Allows(category) {
+		return "recipient unsubscribed from " + category, nil
+	}
+	return "", nil
+}
+
+// markSkipped records that a queued email was not sent on purpose
+func (s *EmailService) markSkipped(notification *models.EmailNotification, reason string) error {
+	log.Printf("[Email] Skipped %s (%s) to %s: %s", notification.ID, notification.NotificationType, notification.ToEmail, reason)
+	return s.db.Model(notification).Updates(map[string]interface{}{
+		"status":        models.EmailStatusSkipped,
+		"error_message": reason,
+	}).Error
+}
+
+// markDeliveryFailed marks a notification failed once its delivery job has no attempts left
+func (s *EmailService) markDeliveryFailed(ctx context.Context, job *models.Job) {
+	var payload EmailDeliveryPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return
+	}
+	updates := map[string]interface{}{"status": models.EmailStatusFailed}
+	if job.LastError != "" {
+		updates["error_message"] = job.LastError
+	}
+	if err := s.db.Model(&models.EmailNotification{}).
+		Where("id = ? AND status = ?", payload.NotificationID, models.EmailStatusPending).
+		Updates(updates).Error; err != nil {
+		log.Printf("[Email] Failed to mark %s as failed: %v", payload.NotificationID, err)
+	}
+}
+
+// QueuePurchaseSuccessEmail confirms a new purchase with the order and repository details
+func (s *EmailService) QueuePurchaseSuccessEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, order *models.Order, license *models.License) error {
 	data := EmailData{
 		UserName:         user.Name,
 		PluginName:       plugin.Name,
+		OrderNumber:      order.OrderNumber,
+		Amount:           fmt.Sprintf("%.2f %s", order.Amount, order.Currency),
 		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
-		DaysRemaining:    daysRemaining,
 		RepoURL:          plugin.GitHubRepoURL,
-		RenewalURL:       fmt.Sprintf("%s/renew/%s", s.config.FrontendURL, license.ID),
-		SupportEmail:     s.config.AdminEmail,
-		SiteName:         "Plugin Store",
+		TutorialURL:      fmt.Sprintf("%s/tutorials/%s", s.config.FrontendURL, plugin.Slug),
 	}
 
-	subject := fmt.Sprintf("Maintenance Expiring Soon - %s (%d days remaining)", plugin.Name, daysRemaining)
-	htmlBody, err := s.renderTemplate("maintenance_expiring", data)
-	if err != nil {
-		return err
-	}
+	_, err := s.QueueEmail(tx, user, "purchase_success", "purchase_success", data, licenseEmailMetadata(license, order))
+	return err
+}
 
-	if err := s.SendEmail(user.Email, subject, htmlBody); err != nil {
-		return err
+func (s *EmailService) QueueMaintenanceExpiringEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, license *models.License, daysRemaining int) error {
+	data := EmailData{
+		UserName:         user.Name,
+		PluginName:       plugin.Name,
+		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
+		DaysRemaining:    daysRemaining,
+		RepoURL:          plugin.GitHubRepoURL,
+		RenewalURL:       fmt.Sprintf("%s/renew/%s", s.config.FrontendURL, license.ID),
 	}
 
 	notificationType := fmt.Sprintf("maintenance_expiring_%d", daysRemaining)
-	notification := &models.EmailNotification{
-		UserID:           user.ID,
-		NotificationType: notificationType,
-		Subject:          subject,
-		Body:             htmlBody,
-		Status:           "sent",
-		SentAt:           &time.Time{},
+	_, err := s.QueueEmail(tx, user, notificationType, "maintenance_expiring", data, licenseEmailMetadata(license, nil))
+	return err
+}
+
+func (s *EmailService) QueueMaintenanceExpiredEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, license *models.License) error {
+	data := EmailData{
+		UserName:         user.Name,
+		PluginName:       plugin.Name,
+		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
+		RenewalURL:       fmt.Sprintf("%s/renew/%s", s.config.FrontendURL, license.ID),
+	}
+	if plugin.GracePeriodDays > 0 {
+		data.GraceUntil = license.MaintenanceUntil.AddDate(0, 0, plugin.GracePeriodDays).Format("2006-01-02")
 	}
-	*notification.SentAt = time.Now()
 
-	return s.db.Create(notification).Error
+	_, err := s.QueueEmail(tx, user, "maintenance_expired", "maintenance_expired", data, licenseEmailMetadata(license, nil))
+	return err
 }
 
-func (s *EmailService) SendMaintenanceExpiredEmail(user *models.User, plugin *models.Plugin, license *models.License) error {
+// QueueGracePeriodEndingEmail warns that repository access will be downgraded when the grace period ends
+func (s *EmailService) QueueGracePeriodEndingEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, license *models.License, daysRemaining int) error {
 	data := EmailData{
 		UserName:         user.Name,
 		PluginName:       plugin.Name,
 		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
+		GraceUntil:       license.MaintenanceUntil.AddDate(0, 0, plugin.GracePeriodDays).Format("2006-01-02"),
+		DaysRemaining:    daysRemaining,
 		RenewalURL:       fmt.Sprintf("%s/renew/%s", s.config.FrontendURL, license.ID),
-		SupportEmail:     s.config.AdminEmail,
-		SiteName:         "Plugin Store",
 	}
 
-	subject := fmt.Sprintf("Maintenance Expired - %s", plugin.Name)
-	htmlBody, err := s.renderTemplate("maintenance_expired", data)
-	if err != nil {
-		return err
+	notificationType := fmt.Sprintf("grace_period_ending_%d", daysRemaining)
+	_, err := s.QueueEmail(tx, user, notificationType, "grace_period_ending", data, licenseEmailMetadata(license, nil))
+	return err
+}
+
+// QueueAccessDowngradedEmail tells the buyer their repository access is now read-only
+func (s *EmailService) QueueAccessDowngradedEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, license *models.License) error {
+	data := EmailData{
+		UserName:         user.Name,
+		PluginName:       plugin.Name,
+		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
+		RepoURL:          plugin.GitHubRepoURL,
+		RenewalURL:       fmt.Sprintf("%s/renew/%s", s.config.FrontendURL, license.ID),
 	}
 
-	if err := s.SendEmail(user.Email, subject, htmlBody); err != nil {
-		return err
+	_, err := s.QueueEmail(tx, user, "access_downgraded", "access_downgraded", data, licenseEmailMetadata(license, nil))
+	return err
+}
+
+func (s *EmailService) QueueRenewalSuccessEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, order *models.Order, license *models.License) error {
+	data := EmailData{
+		UserName:         user.Name,
+		PluginName:       plugin.Name,
+		OrderNumber:      order.OrderNumber,
+		Amount:           fmt.Sprintf("%.2f %s", order.Amount, order.Currency),
+		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
+		RepoURL:          plugin.GitHubRepoURL,
 	}
 
-	notification := &models.EmailNotification{
-		UserID:           user.ID,
-		NotificationType: "maintenance_expired",
-		Subject:          subject,
-		Body:             htmlBody,
-		Status:           "sent",
-		SentAt:           &time.Time{},
+	_, err := s.QueueEmail(tx, user, "renewal_success", "renewal_success", data, licenseEmailMetadata(license, order))
+	return err
+}
+
+// QueueOrderRefundedEmail confirms a refund and that the order's licenses were revoked
+func (s *EmailService) QueueOrderRefundedEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, order *models.Order) error {
+	data := EmailData{
+		UserName:    user.Name,
+		PluginName:  plugin.Name,
+		OrderNumber: order.OrderNumber,
+		Amount:      fmt.Sprintf("%.2f %s", order.Amount, order.Currency),
 	}
-	*notification.SentAt = time.Now()
 
-	return s.db.Create(notification).Error
+	metadata := map[string]interface{}{"order_id": order.ID, "plugin_id": plugin.ID}
+	_, err := s.QueueEmail(tx, user, "order_refunded", "order_refunded", data, metadata)
+	return err
 }
 
-func (s *EmailService) SendRenewalSuccessEmail(user *models.User, plugin *models.Plugin, license *models.License) error {
+func (s *EmailService) QueueAccessGrantedEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, license *models.License) error {
 	data := EmailData{
 		UserName:         user.Name,
 		PluginName:       plugin.Name,
 		MaintenanceUntil: license.MaintenanceUntil.Format("2006-01-02"),
 		RepoURL:          plugin.GitHubRepoURL,
-		SupportEmail:     s.config.AdminEmail,
-		SiteName:         "Plugin Store",
+		GitHubLogin:      license.GitHubAccount.Login,
+	}
+
+	_, err := s.QueueEmail(tx, user, "access_granted", "access_granted", data, licenseEmailMetadata(license, nil))
+	return err
+}
+
+// maxReleaseNotesLength keeps long changelogs from bloating announcement emails;
+// the full notes are behind the release link
+const maxReleaseNotesLength = 4000
+
+// releaseEmailData fills the fields shared by the release announcement emails
+func (s *EmailService) releaseEmailData(user *models.User, plugin *models.Plugin, release *models.PluginRelease) EmailData {
+	notes := strings.TrimSpace(strings.ReplaceAll(release.Body, "\r\n", "\n"))
+	if runes := []rune(notes); len(runes) > maxReleaseNotesLength {
+		notes = strings.TrimSpace(string(runes[:maxReleaseNotesLength])) + "\n…"
+	}
+	releaseURL := fmt.Sprintf("%s/plugins/%s", s.config.FrontendURL, plugin.Slug)
+	if plugin.GitHubRepoURL != "" {
+		releaseURL = fmt.Sprintf("%s/releases/tag/%s", strings.TrimSuffix(plugin.GitHubRepoURL, "/"), release.TagName)
+	}
+	return EmailData{
+		UserName:     user.Name,
+		PluginName:   plugin.Name,
+		Version:      release.TagName,
+		ReleaseNotes: notes,
+		ReleaseURL:   releaseURL,
+		RepoURL:      plugin.GitHubRepoURL,
+	}
+}
+
+// QueueReleaseAvailableEmail sends the changelog of a release the license covers
+func (s *EmailService) QueueReleaseAvailableEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, release *models.PluginRelease, license *models.License) error {
+	data := s.releaseEmailData(user, plugin, release)
+	data.MaintenanceUntil = license.MaintenanceUntil.Format("2006-01-02")
+
+	metadata := licenseEmailMetadata(license, nil)
+	metadata["release_id"] = release.ID
+	_, err := s.QueueEmail(tx, user, "release_available", "release_available", data, metadata)
+	return err
+}
+
+// QueueReleaseRenewalEmail tells the holder of an expired license that renewing gets them a release
+func (s *EmailService) QueueReleaseRenewalEmail(tx *gorm.DB, user *models.User, plugin *models.Plugin, release *models.PluginRelease, license *models.License) error {
+	data := s.releaseEmailData(user, plugin, release)
+	data.MaintenanceUntil = license.MaintenanceUntil.Format("2006-01-02")
+	data.RenewalURL = fmt.Sprintf("%s/renew/%s", s.config.FrontendURL, license.ID)
+
+	metadata := licenseEmailMetadata(license, nil)
+	metadata["release_id"] = release.ID
+	_, err := s.QueueEmail(tx, user, "release_renewal", "release_renewal", data, metadata)
+	return err
+}
+
+// licenseEmailMetadata links a notification to the license (and order) it is about
+func licenseEmailMetadata(license *models.License, order *models.Order) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"license_id": license.ID,
+		"plugin_id":  license.PluginID,
+	}
+	if order != nil {
+		metadata["order_id"] = order.ID
+	}
+	return metadata
+}
+
+// SendAccessGrantFailedEmail tells the store admin that a buyer could not be given repository access
+func (s *EmailService) SendAccessGrantFailedEmail(license *models.License, lastError string) error {
+	if s.config.AdminEmail == "" {
+		return nil
 	}
 
-	subject := fmt.Sprintf("Renewal Successful - %s", plugin.Name)
-	htmlBody, err := s.renderTemplate("renewal_success", data)
+	data := EmailData{
+		UserName:     license.User.Email,
+		PluginName:   license.Plugin.Name,
+		RepoURL:      license.Plugin.GitHubRepoURL,
+		GitHubLogin:  license.GitHubAccount.Login,
+		ErrorMessage: lastError,
+	}
+
+	rendered, err := s.Render("access_grant_failed", models.DefaultLanguage, data)
 	if err != nil {
 		return err
 	}
 
-	if err := s.SendEmail(user.Email, subject, htmlBody); err != nil {
+	return s.SendEmail(s.config.AdminEmail, rendered.Subject, rendered.HTMLBody, rendered.TextBody)
+}
+
+// SendMagicLinkEmail sends a single-use login link. The address may not belong to a user yet.
+func (s *EmailService) SendMagicLinkEmail(email, language, loginURL string, ttlMinutes int) error {
+	data := EmailData{
+		LoginURL:       loginURL,
+		LinkTTLMinutes: ttlMinutes,
+	}
+
+	rendered, err := s.Render("magic_link", language, data)
+	if err != nil {
 		return err
 	}
 
-	notification := &models.EmailNotification{
-		UserID:           user.ID,
-		NotificationType: "renewal_success",
-		Subject:          subject,
-		Body:             htmlBody,
-		Status:           "sent",
-		SentAt:           &time.Time{},
-	}
-	*notification.SentAt = time.Now()
-
-	return s.db.Create(notification).Error
-}
-
-func (s *EmailService) renderTemplate(templateName string, data EmailData) (string, error) {
-	templates := map[string]string{
-		"purchase_success": `
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background-color: #4CAF50; color: white; padding: 20px; text-align: center; }
-        .content { padding: 20px; background-color: #f9f9f9; }
-        .footer { padding: 20px; text-align: center; font-size: 12px; color: #666; }
-        .button { display: inline-block; padding: 10px 20px; background-color: #4CAF50; color: white; text-decoration: none; border-radius: 5px; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Purchase Successful!</h1>
-        </div>
-        <div class="content">
-            <p>Hi {{.UserName}},</p>
-            <p>Thank you for purchasing <strong>{{.PluginName}}</strong>!</p>
-            <p><strong>Order Details:</strong></p>
-            <ul>
-                <li>Order Number: {{.OrderNumber}}</li>
-                <li>Amount: {{.Amount}}</li>
-                <li>Maintenance Until: {{.MaintenanceUntil}}</li>
-            </ul>
-            <p><strong>Repository URL:</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
-            <p><a href="{{.TutorialURL}}" class="button">View Installation Tutorial</a></p>
-            <p>Your plugin is now accessible via GitHub. You'll receive update access until {{.MaintenanceUntil}}.</p>
-        </div>
-        <div class="footer">
-            <p>Need help? Contact us at {{.SupportEmail}}</p>
-            <p>&copy; 2024 {{.SiteName}}. All rights reserved.</p>
-        </div>
-    </div>
-</body>
-</html>`,
-		"maintenance_expiring": `
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background-color: #FF9800; color: white; padding: 20px; text-align: center; }
-        .content { padding: 20px; background-color: #f9f9f9; }
-        .footer { padding: 20px; text-align: center; font-size: 12px; color: #666; }
-        .button { display: inline-block; padding: 10px 20px; background-color: #FF9800; color: white; text-decoration: none; border-radius: 5px; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Maintenance Expiring Soon</h1>
-        </div>
-        <div class="content">
-            <p>Hi {{.UserName}},</p>
-            <p>Your maintenance period for <strong>{{.PluginName}}</strong> will expire in <strong>{{.DaysRemaining}} days</strong>.</p>
-            <p><strong>Expiry Date:</strong> {{.MaintenanceUntil}}</p>
-            <p>After expiry, you'll no longer be able to pull updates from the repository, but the plugin will continue to work.</p>
-            <p><a href="{{.RenewalURL}}" class="button">Renew Maintenance</a></p>
-        </div>
-        <div class="footer">
-            <p>Need help? Contact us at {{.SupportEmail}}</p>
-            <p>&copy; 2024 {{.SiteName}}. All rights reserved.</p>
-        </div>
-    </div>
-</body>
-</html>`,
-		"maintenance_expired": `
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background-color: #F44336; color: white; padding: 20px; text-align: center; }
-        .content { padding: 20px; background-color: #f9f9f9; }
-        .footer { padding: 20px; text-align: center; font-size: 12px; color: #666; }
-        .button { display: inline-block; padding: 10px 20px; background-color: #F44336; color: white; text-decoration: none; border-radius: 5px; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Maintenance Expired</h1>
-        </div>
-        <div class="content">
-            <p>Hi {{.UserName}},</p>
-            <p>Your maintenance period for <strong>{{.PluginName}}</strong> has expired on {{.MaintenanceUntil}}.</p>
-            <p>Your plugin will continue to work, but you can no longer pull updates from the repository.</p>
-            <p>To restore update access, please renew your maintenance.</p>
-            <p><a href="{{.RenewalURL}}" class="button">Renew Now</a></p>
-        </div>
-        <div class="footer">
-            <p>Need help? Contact us at {{.SupportEmail}}</p>
-            <p>&copy; 2024 {{.SiteName}}. All rights reserved.</p>
-        </div>
-    </div>
-</body>
-</html>`,
-		"renewal_success": `
-<!DOCTYPE html>
-<html>
-<head>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background-color: #4CAF50; color: white; padding: 20px; text-align: center; }
-        .content { padding: 20px; background-color: #f9f9f9; }
-        .footer { padding: 20px; text-align: center; font-size: 12px; color: #666; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Renewal Successful!</h1>
-        </div>
-        <div class="content">
-            <p>Hi {{.UserName}},</p>
-            <p>Your maintenance for <strong>{{.PluginName}}</strong> has been successfully renewed!</p>
-            <p><strong>New Expiry Date:</strong> {{.MaintenanceUntil}}</p>
-            <p>You can now continue receiving updates from the repository.</p>
-            <p><strong>Repository URL:</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
-        </div>
-        <div class="footer">
-            <p>Need help? Contact us at {{.SupportEmail}}</p>
-            <p>&copy; 2024 {{.SiteName}}. All rights reserved.</p>
-        </div>
-    </div>
-</body>
-</html>`,
-	}
-
-	tmplStr, ok := templates[templateName]
-	if !ok {
-		return "", fmt.Errorf("template not found: %s", templateName)
+	return s.SendEmail(email, rendered.Subject, rendered.HTMLBody, rendered.TextBody)
+}
+
+// Render renders an email in the given language, falling back to English when the
+// template has no translation
+func (s *EmailService) Render(templateName, language string, data EmailData) (*RenderedEmail, error) {
+	tpl, err := s.loadTemplate(templateName, language)
+	if err != nil {
+		return nil, err
 	}
+	return RenderEmailTemplate(tpl, s.withDefaults(data))
+}
 
-	tmpl, err := template.New(templateName).Parse(tmplStr)
+// RenderTemplate renders a (possibly unsaved) template with sample data
+func (s *EmailService) RenderTemplate(tpl *models.EmailTemplate) (*RenderedEmail, error) {
+	return RenderEmailTemplate(tpl, s.withDefaults(SampleEmailData()))
+}
+
+// SendTestEmail sends a template rendered with sample data
+func (s *EmailService) SendTestEmail(to string, tpl *models.EmailTemplate) error {
+	rendered, err := s.RenderTemplate(tpl)
 	if err != nil {
-		return "", err
+		return err
 	}
+	return s.SendEmail(to, "[Test] "+rendered.Subject, rendered.HTMLBody, rendered.TextBody)
+}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", err
+// loadTemplate looks up a template in the database, then among the built-in ones
+func (s *EmailService) loadTemplate(name, language string) (*models.EmailTemplate, error) {
+	if !models.IsSupportedLanguage(language) {
+		language = models.DefaultLanguage
 	}
 
-	return buf.String(), nil
+	var templates []models.EmailTemplate
+	if err := s.db.Where("name = ? AND language IN ?", name, []string{language, models.DefaultLanguage}).
+		Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		if templates[i].Language == language {
+			return &templates[i], nil
+		}
+	}
+	if len(templates) > 0 {
+		return &templates[0], nil
+	}
+
+	def, ok := DefaultEmailTemplate(name, language)
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+	return def, nil
+}
+
+// withDefaults fills the fields every email shares
+func (s *EmailService) withDefaults(data EmailData) EmailData {
+	if data.SiteName == "" {
+		data.SiteName = s.siteName()
+	}
+	if data.SupportEmail == "" {
+		data.SupportEmail = s.config.AdminEmail
+	}
+	if data.Year == 0 {
+		data.Year = time.Now().Year()
+	}
+	return data
+}
+
+// siteName is the store name configured in system settings
+func (s *EmailService) siteName() string {
+	var setting models.SystemSetting
+	if err := s.db.Where("key = ?", "site_name").First(&setting).Error; err == nil && setting.Value != "" {
+		return setting.Value
+	}
+	return "Plugin Store"
 }
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrEmailSuppressed is returned when sending to an address on the suppression list
+var ErrEmailSuppressed = errors.New("email address is suppressed")
+
+// emailTemplateCategories assigns templates to the notification categories users can
+// opt out of. Templates that are not listed are transactional.
+var emailTemplateCategories = map[string]string{
+	"maintenance_expiring": models.NotificationExpiryReminders,
+	"maintenance_expired":  models.NotificationExpiryReminders,
+	"grace_period_ending":  models.NotificationExpiryReminders,
+	"release_available":    models.NotificationReleaseAnnouncements,
+	"release_renewal":      models.NotificationReleaseAnnouncements,
+	"checkout_recovery":    models.NotificationMarketing,
+}
+
+// EmailCategory returns the notification category of a template, empty for transactional emails
+func EmailCategory(templateName string) string {
+	return emailTemplateCategories[templateName]
+}
+
+// NotificationPreferences returns a user's preferences, all enabled if never changed
+func (s *EmailService) NotificationPreferences(db *gorm.DB, userID uuid.UUID) (*models.NotificationPreference, error) {
+	var pref models.NotificationPreference
+	err := db.First(&pref, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreference(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// SaveNotificationPreferences stores a user's preferences
+func (s *EmailService) SaveNotificationPreferences(db *gorm.DB, pref *models.NotificationPreference) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"marketing", "expiry_reminders", "release_announcements", "updated_at"}),
+	}).Create(pref).Error
+}
+
+// IsSuppressed reports whether an address is on the suppression list
+func (s *EmailService) IsSuppressed(db *gorm.DB, email string) (bool, error) {
+	var count int64
+	if err := db.Model(&models.EmailSuppression{}).
+		Where("email = ?", normalizeEmail(email)).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Suppress adds an address to the suppression list. Suppressing an address again
+// keeps the original reason.
+func (s *EmailService) Suppress(email, reason, detail string) (*models.EmailSuppression, error) {
+	suppression := models.EmailSuppression{
+		Email:  normalizeEmail(email),
+		Reason: reason,
+		Detail: detail,
+	}
+	if suppression.Email == "" {
+		return nil, errors.New("email is required")
+	}
+	if err := s.db.Where("email = ?", suppression.Email).FirstOrCreate(&suppression).Error; err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+// RecordBounce handles a bounce or complaint reported by the email provider. Hard
+// bounces and complaints suppress the address; soft bounces are left to the retries.
+func (s *EmailService) RecordBounce(email string, permanent, complaint bool, detail string) error {
+	reason := models.SuppressionHardBounce
+	switch {
+	case complaint:
+		reason = models.SuppressionComplaint
+	case !permanent:
+		return nil
+	}
+	if _, err := s.Suppress(email, reason, detail); err != nil {
+		return err
+	}
+	log.Printf("[Email] Suppressed %s after %s: %s", normalizeEmail(email), reason, detail)
+	return nil
+}
+
+// isHardBounce reports whether an SMTP send failed because the mailbox does not
+// exist or refuses mail for good (5.1.x/5.5.x rejections of the recipient)
+func isHardBounce(err error) bool {
+	var smtpErr *textproto.Error
+	if !errors.As(err, &smtpErr) {
+		return false
+	}
+	return smtpErr.Code == 550 || smtpErr.Code == 551 || smtpErr.Code == 553
+}
+
+// UnsubscribeToken is the signed token of an unsubscribe link for one category
+func (s *EmailService) UnsubscribeToken(userID uuid.UUID, category string) string {
+	return fmt.Sprintf("%s.%s.%s", userID, category, s.signUnsubscribe(userID, category))
+}
+
+// ParseUnsubscribeToken validates an unsubscribe token and returns its user and category
+func (s *EmailService) ParseUnsubscribeToken(token string) (uuid.UUID, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return uuid.Nil, "", errors.New("invalid unsubscribe token")
+	}
+	userID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", errors.New("invalid unsubscribe token")
+	}
+	category := parts[1]
+	if !hmac.Equal([]byte(s.signUnsubscribe(userID, category)), []byte(parts[2])) {
+		return uuid.Nil, "", errors.New("invalid unsubscribe token")
+	}
+	if !models.IsNotificationCategory(category) {
+		return uuid.Nil, "", errors.New("unknown notification category")
+	}
+	return userID, category, nil
+}
+
+// Unsubscribe turns off the category of a signed unsubscribe token
+func (s *EmailService) Unsubscribe(token string) (string, error) {
+	userID, category, err := s.ParseUnsubscribeToken(token)
+	if err != nil {
+		return "", err
+	}
+	return category, s.db.Transaction(func(tx *gorm.DB) error {
+		pref, err := s.NotificationPreferences(tx, userID)
+		if err != nil {
+			return err
+		}
+		pref.Set(category, false)
+		return s.SaveNotificationPreferences(tx, pref)
+	})
+}
+
+// UnsubscribeURL is the storefront page linked from the email footer
+func (s *EmailService) UnsubscribeURL(userID uuid.UUID, category string) string {
+	return s.UnsubscribeURLForToken(s.UnsubscribeToken(userID, category))
+}
+
+// UnsubscribeURLForToken is the storefront unsubscribe page of a token
+func (s *EmailService) UnsubscribeURLForToken(token string) string {
+	return fmt.Sprintf("%s/unsubscribe?token=%s", s.config.FrontendURL, url.QueryEscape(token))
+}
+
+// unsubscribeHeaders are the List-Unsubscribe headers that let mail clients
+// unsubscribe with one click (RFC 8058)
+func (s *EmailService) unsubscribeHeaders(userID uuid.UUID, category string) map[string]string {
+	oneClickURL := fmt.Sprintf("%s/api/unsubscribe?token=%s", s.config.AppURL, url.QueryEscape(s.UnsubscribeToken(userID, category)))
+	return map[string]string{
+		"List-Unsubscribe":      "<" + oneClickURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func (s *EmailService) signUnsubscribe(userID uuid.UUID, category string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.JWTSecret))
+	fmt.Fprintf(mac, "unsubscribe:%s:%s", userID, category)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,638 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"reflect"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// EmailTemplateNames lists every email the store sends, in the order admins see them
+var EmailTemplateNames = []string{
+	"purchase_success",
+	"renewal_success",
+	"access_granted",
+	"maintenance_expiring",
+	"maintenance_expired",
+	"grace_period_ending",
+	"access_downgraded",
+	"order_refunded",
+	"release_available",
+	"release_renewal",
+	"checkout_recovery",
+	"magic_link",
+	"access_grant_failed",
+	"admin_digest",
+}
+
+// RenderedEmail is an email ready to be sent
+type RenderedEmail struct {
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"html_body"`
+	TextBody string `json:"text_body"`
+}
+
+// defaultEmailTemplate is the built-in content a template is seeded with and reset to
+type defaultEmailTemplate struct {
+	subject string
+	color   string
+	heading string
+	content string
+	footer  string // defaults to the support and copyright footer of the language
+}
+
+var defaultEmailFooters = map[string]string{
+	"en": `<p>Need help? Contact us at {{.SupportEmail}}</p>
+            {{if .UnsubscribeURL}}<p><a href="{{.UnsubscribeURL}}">Unsubscribe</a> from emails like this.</p>{{end}}
+            <p>&copy; {{.Year}} {{.SiteName}}. All rights reserved.</p>`,
+	"zh": `<p>需要帮助？请联系 {{.SupportEmail}}</p>
+            {{if .UnsubscribeURL}}<p>不想再收到此类邮件？<a href="{{.UnsubscribeURL}}">退订</a></p>{{end}}
+            <p>&copy; {{.Year}} {{.SiteName}}. 保留所有权利。</p>`,
+}
+
+var defaultEmailTemplates = map[string]map[string]defaultEmailTemplate{
+	"purchase_success": {
+		"en": {
+			subject: "Purchase Successful - {{.PluginName}}",
+			color:   "#4CAF50",
+			heading: "Purchase Successful!",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>Thank you for purchasing <strong>{{.PluginName}}</strong>!</p>
+            <p><strong>Order Details:</strong></p>
+            <ul>
+                <li>Order Number: {{.OrderNumber}}</li>
+                <li>Amount: {{.Amount}}</li>
+                <li>Maintenance Until: {{.MaintenanceUntil}}</li>
+            </ul>
+            <p><strong>Repository URL:</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
+            <p><a href="{{.TutorialURL}}" class="button">View Installation Tutorial</a></p>
+            <p>Your plugin is now accessible via GitHub. You'll receive update access until {{.MaintenanceUntil}}.</p>`,
+		},
+		"zh": {
+			subject: "购买成功 - {{.PluginName}}",
+			color:   "#4CAF50",
+			heading: "购买成功！",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>感谢您购买 <strong>{{.PluginName}}</strong>！</p>
+            <p><strong>订单详情：</strong></p>
+            <ul>
+                <li>订单号：{{.OrderNumber}}</li>
+                <li>金额：{{.Amount}}</li>
+                <li>维护期至：{{.MaintenanceUntil}}</li>
+            </ul>
+            <p><strong>仓库地址：</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
+            <p><a href="{{.TutorialURL}}" class="button">查看安装教程</a></p>
+            <p>您现在可以通过 GitHub 访问插件，{{.MaintenanceUntil}} 之前都可以获取更新。</p>`,
+		},
+	},
+	"renewal_success": {
+		"en": {
+			subject: "Renewal Successful - {{.PluginName}}",
+			color:   "#4CAF50",
+			heading: "Renewal Successful!",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>Your maintenance for <strong>{{.PluginName}}</strong> has been successfully renewed!</p>
+            <p><strong>New Expiry Date:</strong> {{.MaintenanceUntil}}</p>
+            <p>You can now continue receiving updates from the repository.</p>
+            <p><strong>Repository URL:</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>`,
+		},
+		"zh": {
+			subject: "续费成功 - {{.PluginName}}",
+			color:   "#4CAF50",
+			heading: "续费成功！",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>您的 <strong>{{.PluginName}}</strong> 维护期已成功续费！</p>
+            <p><strong>新的到期日：</strong> {{.MaintenanceUntil}}</p>
+            <p>您可以继续从仓库获取更新。</p>
+            <p><strong>仓库地址：</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>`,
+		},
+	},
+	"access_granted": {
+		"en": {
+			subject: "Repository Access Granted - {{.PluginName}}",
+			color:   "#4CAF50",
+			heading: "Your Access Is Live!",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>The GitHub account <strong>{{.GitHubLogin}}</strong> has been invited to the <strong>{{.PluginName}}</strong> repository.</p>
+            <p>Please accept the invitation from GitHub (check your GitHub notifications or email) to start pulling the code.</p>
+            <p><a href="{{.RepoURL}}" class="button">Open Repository</a></p>
+            <p>You'll receive update access until {{.MaintenanceUntil}}.</p>`,
+		},
+		"zh": {
+			subject: "仓库访问已开通 - {{.PluginName}}",
+			color:   "#4CAF50",
+			heading: "访问权限已开通！",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>GitHub 账号 <strong>{{.GitHubLogin}}</strong> 已被邀请加入 <strong>{{.PluginName}}</strong> 仓库。</p>
+            <p>请在 GitHub 上接受邀请（查看 GitHub 通知或邮件），即可开始拉取代码。</p>
+            <p><a href="{{.RepoURL}}" class="button">打开仓库</a></p>
+            <p>{{.MaintenanceUntil}} 之前您都可以获取更新。</p>`,
+		},
+	},
+	"maintenance_expiring": {
+		"en": {
+			subject: "Maintenance Expiring Soon - {{.PluginName}} ({{.DaysRemaining}} days remaining)",
+			color:   "#FF9800",
+			heading: "Maintenance Expiring Soon",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>Your maintenance period for <strong>{{.PluginName}}</strong> will expire in <strong>{{.DaysRemaining}} days</strong>.</p>
+            <p><strong>Expiry Date:</strong> {{.MaintenanceUntil}}</p>
+            <p>After expiry, you'll no longer be able to pull updates from the repository, but the plugin will continue to work.</p>
+            <p><a href="{{.RenewalURL}}" class="button">Renew Maintenance</a></p>`,
+		},
+		"zh": {
+			subject: "维护期即将到期 - {{.PluginName}}（剩余 {{.DaysRemaining}} 天）",
+			color:   "#FF9800",
+			heading: "维护期即将到期",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>您的 <strong>{{.PluginName}}</strong> 维护期将在 <strong>{{.DaysRemaining}} 天</strong>后到期。</p>
+            <p><strong>到期日：</strong> {{.MaintenanceUntil}}</p>
+            <p>到期后您将无法从仓库拉取更新，但插件仍可继续使用。</p>
+            <p><a href="{{.RenewalURL}}" class="button">续费维护</a></p>`,
+		},
+	},
+	"maintenance_expired": {
+		"en": {
+			subject: "Maintenance Expired - {{.PluginName}}",
+			color:   "#F44336",
+			heading: "Maintenance Expired",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>Your maintenance period for <strong>{{.PluginName}}</strong> has expired on {{.MaintenanceUntil}}.</p>
+            {{if .GraceUntil}}
+            <p>Your repository access stays unchanged during a grace period until <strong>{{.GraceUntil}}</strong>. After that it becomes read-only and you will no longer receive new releases.</p>
+            <p>Renew before the grace period ends to keep full access.</p>
+            {{else}}
+            <p>Your plugin will continue to work and your repository access is now read-only, but you will no longer receive new releases.</p>
+            <p>To restore update access, please renew your maintenance.</p>
+            {{end}}
+            <p><a href="{{.RenewalURL}}" class="button">Renew Now</a></p>`,
+		},
+		"zh": {
+			subject: "维护期已到期 - {{.PluginName}}",
+			color:   "#F44336",
+			heading: "维护期已到期",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>您的 <strong>{{.PluginName}}</strong> 维护期已于 {{.MaintenanceUntil}} 到期。</p>
+            {{if .GraceUntil}}
+            <p>在宽限期结束（<strong>{{.GraceUntil}}</strong>）之前，您的仓库访问权限保持不变。之后将变为只读，您将不再收到新版本。</p>
+            <p>请在宽限期结束前续费以保留完整访问权限。</p>
+            {{else}}
+            <p>插件仍可继续使用，您的仓库访问权限现已变为只读，将不再收到新版本。</p>
+            <p>如需恢复更新权限，请续费维护。</p>
+            {{end}}
+            <p><a href="{{.RenewalURL}}" class="button">立即续费</a></p>`,
+		},
+	},
+	"grace_period_ending": {
+		"en": {
+			subject: "Grace Period Ending - {{.PluginName}} ({{.DaysRemaining}} days remaining)",
+			color:   "#FF9800",
+			heading: "Grace Period Ending Soon",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>Maintenance for <strong>{{.PluginName}}</strong> expired on {{.MaintenanceUntil}} and your grace period ends in <strong>{{.DaysRemaining}} days</strong>.</p>
+            <p><strong>Grace Period Ends:</strong> {{.GraceUntil}}</p>
+            <p>After that your repository access becomes read-only and you will no longer receive new releases.</p>
+            <p><a href="{{.RenewalURL}}" class="button">Renew Maintenance</a></p>`,
+		},
+		"zh": {
+			subject: "宽限期即将结束 - {{.PluginName}}（剩余 {{.DaysRemaining}} 天）",
+			color:   "#FF9800",
+			heading: "宽限期即将结束",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p><strong>{{.PluginName}}</strong> 的维护期已于 {{.MaintenanceUntil}} 到期，宽限期将在 <strong>{{.DaysRemaining}} 天</strong>后结束。</p>
+            <p><strong>宽限期结束：</strong> {{.GraceUntil}}</p>
+            <p>之后您的仓库访问权限将变为只读，您将不再收到新版本。</p>
+            <p><a href="{{.RenewalURL}}" class="button">续费维护</a></p>`,
+		},
+	},
+	"access_downgraded": {
+		"en": {
+			subject: "Repository Access Downgraded - {{.PluginName}}",
+			color:   "#F44336",
+			heading: "Repository Access Downgraded",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>The grace period for <strong>{{.PluginName}}</strong> has ended and your repository access is now read-only.</p>
+            <p>You can still use the releases published up to {{.MaintenanceUntil}}, but new releases require an active maintenance period.</p>
+            <p><strong>Repository URL:</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
+            <p><a href="{{.RenewalURL}}" class="button">Renew Now</a></p>`,
+		},
+		"zh": {
+			subject: "仓库访问权限已降级 - {{.PluginName}}",
+			color:   "#F44336",
+			heading: "仓库访问权限已降级",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p><strong>{{.PluginName}}</strong> 的宽限期已结束，您的仓库访问权限现已变为只读。</p>
+            <p>您仍可使用 {{.MaintenanceUntil}} 之前发布的版本，新版本需要有效的维护期。</p>
+            <p><strong>仓库地址：</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
+            <p><a href="{{.RenewalURL}}" class="button">立即续费</a></p>`,
+		},
+	},
+	"order_refunded": {
+		"en": {
+			subject: "Order Refunded - {{.OrderNumber}}",
+			color:   "#607D8B",
+			heading: "Order Refunded",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>Your order for <strong>{{.PluginName}}</strong> has been refunded.</p>
+            <ul>
+                <li>Order Number: {{.OrderNumber}}</li>
+                <li>Amount: {{.Amount}}</li>
+            </ul>
+            <p>The license from this order has been revoked and your access to the repository will be removed.</p>`,
+		},
+		"zh": {
+			subject: "订单已退款 - {{.OrderNumber}}",
+			color:   "#607D8B",
+			heading: "订单已退款",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>您购买 <strong>{{.PluginName}}</strong> 的订单已退款。</p>
+            <ul>
+                <li>订单号：{{.OrderNumber}}</li>
+                <li>金额：{{.Amount}}</li>
+            </ul>
+            <p>该订单的许可证已被撤销，您的仓库访问权限将被移除。</p>`,
+		},
+	},
+	"release_available": {
+		"en": {
+			subject: "{{.PluginName}} {{.Version}} is available",
+			color:   "#2196F3",
+			heading: "New Release: {{.PluginName}} {{.Version}}",
+			content: `<p>Hi {{.UserName}},</p>
+            <p><strong>{{.PluginName}} {{.Version}}</strong> has been released and is included in your maintenance period.</p>
+            {{if .ReleaseNotes}}<p><strong>What's new:</strong></p>
+            <div style="white-space: pre-wrap; background-color: #fff; border-left: 3px solid #2196F3; padding: 10px;">{{.ReleaseNotes}}</div>{{end}}
+            <p><a href="{{.ReleaseURL}}" class="button">View Release</a></p>
+            <p>Your maintenance runs until {{.MaintenanceUntil}}.</p>`,
+		},
+		"zh": {
+			subject: "{{.PluginName}} {{.Version}} 已发布",
+			color:   "#2196F3",
+			heading: "新版本：{{.PluginName}} {{.Version}}",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p><strong>{{.PluginName}} {{.Version}}</strong> 已发布，包含在您的维护期内。</p>
+            {{if .ReleaseNotes}}<p><strong>更新内容：</strong></p>
+            <div style="white-space: pre-wrap; background-color: #fff; border-left: 3px solid #2196F3; padding: 10px;">{{.ReleaseNotes}}</div>{{end}}
+            <p><a href="{{.ReleaseURL}}" class="button">查看版本</a></p>
+            <p>您的维护期至 {{.MaintenanceUntil}}。</p>`,
+		},
+	},
+	"release_renewal": {
+		"en": {
+			subject: "{{.PluginName}} {{.Version}} is out - renew to get it",
+			color:   "#FF9800",
+			heading: "New Release: {{.PluginName}} {{.Version}}",
+			content: `<p>Hi {{.UserName}},</p>
+            <p><strong>{{.PluginName}} {{.Version}}</strong> has been released. Your maintenance ended on {{.MaintenanceUntil}}, so this version is not included in your license.</p>
+            {{if .ReleaseNotes}}<p><strong>What's new:</strong></p>
+            <div style="white-space: pre-wrap; background-color: #fff; border-left: 3px solid #FF9800; padding: 10px;">{{.ReleaseNotes}}</div>{{end}}
+            <p>Renew your maintenance to get {{.Version}} and the updates that follow.</p>
+            <p><a href="{{.RenewalURL}}" class="button">Renew Maintenance</a></p>`,
+		},
+		"zh": {
+			subject: "{{.PluginName}} {{.Version}} 已发布 - 续费即可获取",
+			color:   "#FF9800",
+			heading: "新版本：{{.PluginName}} {{.Version}}",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p><strong>{{.PluginName}} {{.Version}}</strong> 已发布。您的维护期已于 {{.MaintenanceUntil}} 结束，此版本不在您的许可范围内。</p>
+            {{if .ReleaseNotes}}<p><strong>更新内容：</strong></p>
+            <div style="white-space: pre-wrap; background-color: #fff; border-left: 3px solid #FF9800; padding: 10px;">{{.ReleaseNotes}}</div>{{end}}
+            <p>续费维护即可获取 {{.Version}} 及后续更新。</p>
+            <p><a href="{{.RenewalURL}}" class="button">续费维护</a></p>`,
+		},
+	},
+	"checkout_recovery": {
+		"en": {
+			subject: "{{if .DiscountPercent}}{{.DiscountPercent}}% off {{.PluginName}} - complete your purchase{{else}}Complete your purchase of {{.PluginName}}{{end}}",
+			color:   "#2196F3",
+			heading: "Your Order Is Waiting",
+			content: `<p>Hi {{.UserName}},</p>
+            <p>You started buying <strong>{{.PluginName}}</strong> but the payment was not completed. Your order {{.OrderNumber}} is still open.</p>
+            {{if .DiscountPercent}}<p>Complete it before {{.DiscountExpiresAt}} and get <strong>{{.DiscountPercent}}% off</strong>: <strong>{{.Amount}}</strong> instead of {{.OriginalAmount}}.</p>
+            {{else}}<p><strong>Amount:</strong> {{.Amount}}</p>
+            {{end}}<p><a href="{{.ResumeURL}}" class="button">Complete Purchase</a></p>
+            <p>If you ran into a problem while paying, just reply to this email.</p>`,
+		},
+		"zh": {
+			subject: "{{if .DiscountPercent}}{{.PluginName}} 限时 {{.DiscountPercent}}% 折扣 - 完成您的购买{{else}}完成您对 {{.PluginName}} 的购买{{end}}",
+			color:   "#2196F3",
+			heading: "您的订单仍在等待支付",
+			content: `<p>{{.UserName}}，您好：</p>
+            <p>您发起了 <strong>{{.PluginName}}</strong> 的购买，但尚未完成支付。您的订单 {{.OrderNumber}} 仍然有效。</p>
+            {{if .DiscountPercent}}<p>在 {{.DiscountExpiresAt}} 之前完成支付即可享受 <strong>{{.DiscountPercent}}% 折扣</strong>，仅需 <strong>{{.Amount}}</strong>（原价 {{.OriginalAmount}}）。</p>
+            {{else}}<p><strong>金额：</strong>{{.Amount}}</p>
+            {{end}}<p><a href="{{.ResumeURL}}" class="button">完成购买</a></p>
+            <p>如果支付时遇到问题，请直接回复此邮件。</p>`,
+		},
+	},
+	"magic_link": {
+		"en": {
+			subject: "Your login link",
+			color:   "#4CAF50",
+			heading: "Log In to {{.SiteName}}",
+			content: `<p>Click the button below to log in. The link can be used once and expires in {{.LinkTTLMinutes}} minutes.</p>
+            <p><a href="{{.LoginURL}}" class="button">Log In</a></p>
+            <p>If you did not request this email, you can safely ignore it.</p>`,
+		},
+		"zh": {
+			subject: "您的登录链接",
+			color:   "#4CAF50",
+			heading: "登录 {{.SiteName}}",
+			content: `<p>点击下方按钮登录。该链接仅可使用一次，{{.LinkTTLMinutes}} 分钟后失效。</p>
+            <p><a href="{{.LoginURL}}" class="button">登录</a></p>
+            <p>如果您没有请求此邮件，请忽略。</p>`,
+		},
+	},
+	"access_grant_failed": {
+		"en": {
+			subject: "Repository Access Failed - {{.PluginName}} ({{.GitHubLogin}})",
+			color:   "#F44336",
+			heading: "Repository Access Failed",
+			content: `<p>Repository access for <strong>{{.UserName}}</strong> (GitHub: {{.GitHubLogin}}) to <strong>{{.PluginName}}</strong> could not be granted after several attempts.</p>
+            <p><strong>Last error:</strong> {{.ErrorMessage}}</p>
+            <p><strong>Repository:</strong> <a href="{{.RepoURL}}">{{.RepoURL}}</a></p>
+            <p>Fix the problem and retry the grant from the admin dashboard.</p>`,
+			footer: `<p>&copy; {{.Year}} {{.SiteName}}.</p>`,
+		},
+	},
+	"admin_digest": {
+		"en": {
+			subject: "{{with .Digest}}{{if eq .Period \"weekly\"}}Weekly{{else}}Daily{{end}} report {{.From}}{{if ne .From .To}} to {{.To}}{{end}}{{end}} - {{.SiteName}}",
+			color:   "#607D8B",
+			heading: "{{with .Digest}}{{if eq .Period \"weekly\"}}Weekly{{else}}Daily{{end}} Report{{end}}",
+			content: `{{with .Digest}}<p><strong>Period:</strong> {{.From}}{{if ne .From .To}} to {{.To}}{{end}}</p>
+            <h3>Revenue</h3>
+            {{if .Revenue}}<ul>{{range .Revenue}}<li>{{.PluginName}}: {{.Amount}} {{.Currency}} ({{.Orders}} orders)</li>{{end}}</ul>
+            <p><strong>Total:</strong> {{range $i, $t := .RevenueTotals}}{{if $i}}, {{end}}{{$t.Amount}} {{$t.Currency}} ({{$t.Orders}} orders){{end}}</p>
+            {{else}}<p>No paid orders.</p>{{end}}
+            <h3>Users</h3>
+            <p>{{.NewUsers}} new, {{.TotalUsers}} in total.</p>
+            <h3>Licenses Expiring in 30 Days ({{.ExpiringLicenseCount}})</h3>
+            {{if .ExpiringLicenses}}<ul>{{range .ExpiringLicenses}}<li>{{.MaintenanceUntil}}: {{.PluginName}} - {{.UserEmail}}</li>{{end}}</ul>
+            {{if gt .ExpiringLicenseCount (len .ExpiringLicenses)}}<p>And more, see the dashboard.</p>{{end}}{{else}}<p>None.</p>{{end}}
+            <h3>Failed Payments ({{.FailedPaymentCount}})</h3>
+            {{if .FailedPayments}}<ul>{{range .FailedPayments}}<li>{{.OrderNumber}}: {{.PluginName}}, {{.Amount}} - {{.UserEmail}}</li>{{end}}</ul>
+            {{if gt .FailedPaymentCount (len .FailedPayments)}}<p>And more, see the dashboard.</p>{{end}}{{else}}<p>None.</p>{{end}}
+            <h3>Webhook Errors ({{.WebhookErrorCount}})</h3>
+            {{if .WebhookErrors}}<ul>{{range .WebhookErrors}}<li>{{.Event}} to {{.URL}} after {{.Attempts}} attempts: {{.Error}}</li>{{end}}</ul>
+            {{if gt .WebhookErrorCount (len .WebhookErrors)}}<p>And more, see the delivery log.</p>{{end}}{{else}}<p>None.</p>{{end}}
+            <p><a href="{{.DashboardURL}}" class="button">Open Dashboard</a></p>{{end}}`,
+			footer: `<p>Sent to the store admins. Change the recipients in the admin_digest_recipients setting.</p>
+            <p>&copy; {{.Year}} {{.SiteName}}</p>`,
+		},
+		"zh": {
+			subject: "{{with .Digest}}{{if eq .Period \"weekly\"}}周报{{else}}日报{{end}} {{.From}}{{if ne .From .To}} 至 {{.To}}{{end}}{{end}} - {{.SiteName}}",
+			color:   "#607D8B",
+			heading: "{{with .Digest}}{{if eq .Period \"weekly\"}}运营周报{{else}}运营日报{{end}}{{end}}",
+			content: `{{with .Digest}}<p><strong>统计周期：</strong>{{.From}}{{if ne .From .To}} 至 {{.To}}{{end}}</p>
+            <h3>收入</h3>
+            {{if .Revenue}}<ul>{{range .Revenue}}<li>{{.PluginName}}：{{.Amount}} {{.Currency}}（{{.Orders}} 笔订单）</li>{{end}}</ul>
+            <p><strong>合计：</strong>{{range $i, $t := .RevenueTotals}}{{if $i}}，{{end}}{{$t.Amount}} {{$t.Currency}}（{{$t.Orders}} 笔订单）{{end}}</p>
+            {{else}}<p>没有已支付的订单。</p>{{end}}
+            <h3>用户</h3>
+            <p>新增 {{.NewUsers}} 人，共 {{.TotalUsers}} 人。</p>
+            <h3>30 天内到期的许可证（{{.ExpiringLicenseCount}}）</h3>
+            {{if .ExpiringLicenses}}<ul>{{range .ExpiringLicenses}}<li>{{.MaintenanceUntil}}：{{.PluginName}} - {{.UserEmail}}</li>{{end}}</ul>
+            {{if gt .ExpiringLicenseCount (len .ExpiringLicenses)}}<p>更多内容请查看管理后台。</p>{{end}}{{else}}<p>无。</p>{{end}}
+            <h3>支付失败（{{.FailedPaymentCount}}）</h3>
+            {{if .FailedPayments}}<ul>{{range .FailedPayments}}<li>{{.OrderNumber}}：{{.PluginName}}，{{.Amount}} - {{.UserEmail}}</li>{{end}}</ul>
+            {{if gt .FailedPaymentCount (len .FailedPayments)}}<p>更多内容请查看管理后台。</p>{{end}}{{else}}<p>无。</p>{{end}}
+            <h3>Webhook 错误（{{.WebhookErrorCount}}）</h3>
+            {{if .WebhookErrors}}<ul>{{range .WebhookErrors}}<li>{{.Event}} 发送至 {{.URL}}，尝试 {{.Attempts}} 次后失败：{{.Error}}</li>{{end}}</ul>
+            {{if gt .WebhookErrorCount (len .WebhookErrors)}}<p>更多内容请查看投递日志。</p>{{end}}{{else}}<p>无。</p>{{end}}
+            <p><a href="{{.DashboardURL}}" class="button">打开管理后台</a></p>{{end}}`,
+			footer: `<p>此邮件发送给商店管理员，可在 admin_digest_recipients 设置中修改收件人。</p>
+            <p>&copy; {{.Year}} {{.SiteName}}</p>`,
+		},
+	},
+}
+
+// emailLayout wraps the content of a built-in template in the shared HTML layout
+func emailLayout(color, heading, content, footer string) string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background-color: ` + color + `; color: white; padding: 20px; text-align: center; }
+        .content { padding: 20px; background-color: #f9f9f9; }
+        .footer { padding: 20px; text-align: center; font-size: 12px; color: #666; }
+        .button { display: inline-block; padding: 10px 20px; background-color: ` + color + `; color: white; text-decoration: none; border-radius: 5px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>` + heading + `</h1>
+        </div>
+        <div class="content">
+            ` + content + `
+        </div>
+        <div class="footer">
+            ` + footer + `
+        </div>
+    </div>
+</body>
+</html>`
+}
+
+// DefaultEmailTemplate returns the built-in content of a template, falling back to
+// English for languages it was not translated to
+func DefaultEmailTemplate(name, language string) (*models.EmailTemplate, bool) {
+	variants, ok := defaultEmailTemplates[name]
+	if !ok {
+		return nil, false
+	}
+	def, ok := variants[language]
+	if !ok {
+		language = models.DefaultLanguage
+		def = variants[language]
+	}
+
+	footer := def.footer
+	if footer == "" {
+		footer = defaultEmailFooters[language]
+	}
+	return &models.EmailTemplate{
+		Name:     name,
+		Language: language,
+		Subject:  def.subject,
+		HTMLBody: emailLayout(def.color, def.heading, def.content, footer),
+	}, true
+}
+
+// SeedEmailTemplates stores the built-in templates that are not in the database yet.
+// Templates edited by admins are left alone.
+func SeedEmailTemplates(db *gorm.DB) error {
+	for _, name := range EmailTemplateNames {
+		for language := range defaultEmailTemplates[name] {
+			tpl, _ := DefaultEmailTemplate(name, language)
+			if err := db.Where("name = ? AND language = ?", name, language).FirstOrCreate(tpl).Error; err != nil {
+				return fmt.Errorf("failed to seed email template %s/%s: %w", name, language, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateEmailTemplate checks that the subject and bodies parse
+func ValidateEmailTemplate(tpl *models.EmailTemplate) error {
+	if strings.TrimSpace(tpl.Subject) == "" || strings.TrimSpace(tpl.HTMLBody) == "" {
+		return errors.New("subject and HTML body are required")
+	}
+	if _, err := texttemplate.New("subject").Parse(tpl.Subject); err != nil {
+		return fmt.Errorf("invalid subject: %w", err)
+	}
+	if _, err := htmltemplate.New("html").Parse(tpl.HTMLBody); err != nil {
+		return fmt.Errorf("invalid HTML body: %w", err)
+	}
+	if _, err := texttemplate.New("text").Parse(tpl.TextBody); err != nil {
+		return fmt.Errorf("invalid text body: %w", err)
+	}
+	return nil
+}
+
+// RenderEmailTemplate renders a template, generating the text body from the HTML
+// body unless the template has its own
+func RenderEmailTemplate(tpl *models.EmailTemplate, data EmailData) (*RenderedEmail, error) {
+	var subject bytes.Buffer
+	subjectTmpl, err := texttemplate.New("subject").Parse(tpl.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject of %s/%s: %w", tpl.Name, tpl.Language, err)
+	}
+	if err := subjectTmpl.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+
+	var htmlBody bytes.Buffer
+	htmlTmpl, err := htmltemplate.New("html").Parse(tpl.HTMLBody)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTML body of %s/%s: %w", tpl.Name, tpl.Language, err)
+	}
+	if err := htmlTmpl.Execute(&htmlBody, data); err != nil {
+		return nil, err
+	}
+
+	rendered := &RenderedEmail{
+		Subject:  strings.Join(strings.Fields(subject.String()), " "),
+		HTMLBody: htmlBody.String(),
+	}
+
+	if strings.TrimSpace(tpl.TextBody) == "" {
+		rendered.TextBody = HTMLToText(rendered.HTMLBody)
+		return rendered, nil
+	}
+	var textBody bytes.Buffer
+	textTmpl, err := texttemplate.New("text").Parse(tpl.TextBody)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text body of %s/%s: %w", tpl.Name, tpl.Language, err)
+	}
+	if err := textTmpl.Execute(&textBody, data); err != nil {
+		return nil, err
+	}
+	rendered.TextBody = textBody.String()
+	return rendered, nil
+}
+
+var (
+	htmlHiddenPattern  = regexp.MustCompile(`(?is)<(head|style|script)[^>]*>.*?</(head|style|script)>`)
+	htmlLinkPattern    = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*"([^"]*)"[^>]*>(.*?)</a>`)
+	htmlBreakPattern   = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlBlockPattern   = regexp.MustCompile(`(?i)</(p|div|h[1-6]|ul|ol|table|tr)>`)
+	htmlItemPattern    = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlTagPattern     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesPattern  = regexp.MustCompile(`\n{3,}`)
+	inlineSpacePattern = regexp.MustCompile(`[ \t]+`)
+)
+
+// HTMLToText turns an HTML email into its plain-text alternative, keeping link targets
+func HTMLToText(body string) string {
+	text := htmlHiddenPattern.ReplaceAllString(body, "")
+	text = htmlLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		m := htmlLinkPattern.FindStringSubmatch(link)
+		href, label := m[1], strings.TrimSpace(htmlTagPattern.ReplaceAllString(m[2], ""))
+		if label == "" || label == href {
+			return href
+		}
+		return label + " (" + href + ")"
+	})
+	text = htmlBreakPattern.ReplaceAllString(text, "\n")
+	text = htmlBlockPattern.ReplaceAllString(text, "\n\n")
+	text = htmlItemPattern.ReplaceAllString(text, "\n- ")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(inlineSpacePattern.ReplaceAllString(line, " "))
+	}
+	text = blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	text = strings.ReplaceAll(text, "\n\n- ", "\n- ")
+	return strings.TrimSpace(text) + "\n"
+}
+
+// EmailTemplateVariables lists the fields templates can use, like {{.PluginName}}
+func EmailTemplateVariables() []string {
+	t := reflect.TypeOf(EmailData{})
+	variables := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		variables = append(variables, "{{."+t.Field(i).Name+"}}")
+	}
+	return variables
+}
+
+// SampleEmailData fills every template variable for previews and test emails
+func SampleEmailData() EmailData {
+	maintenanceUntil := time.Now().AddDate(1, 0, 0)
+	return EmailData{
+		UserName:          "Jane Doe",
+		PluginName:        "Example Plugin",
+		OrderNumber:       "ORD-20240101-0001",
+		Amount:            "99.00 USD",
+		MaintenanceUntil:  maintenanceUntil.Format("2006-01-02"),
+		GraceUntil:        maintenanceUntil.AddDate(0, 0, 14).Format("2006-01-02"),
+		DaysRemaining:     7,
+		RepoURL:           "https://github.com/example/example-plugin",
+		TutorialURL:       "https://example.com/tutorials/example-plugin",
+		RenewalURL:        "https://example.com/renew/00000000-0000-0000-0000-000000000000",
+		GitHubLogin:       "janedoe",
+		ErrorMessage:      "example error",
+		LoginURL:          "https://example.com/auth/callback?magic_token=example",
+		LinkTTLMinutes:    15,
+		Version:           "v1.2.0",
+		ReleaseNotes:      "- Added dark mode\n- Fixed login redirect",
+		ReleaseURL:        "https://github.com/example/example-plugin/releases/tag/v1.2.0",
+		UnsubscribeURL:    "https://example.com/unsubscribe?token=example",
+		ResumeURL:         "https://example.com/purchase/00000000-0000-0000-0000-000000000000?order_id=00000000-0000-0000-0000-000000000000",
+		DiscountPercent:   10,
+		DiscountExpiresAt: time.Now().AddDate(0, 0, 2).Format("2006-01-02 15:04 MST"),
+		OriginalAmount:    "110.00 USD",
+		Digest: &AdminDigest{
+			Period:               DigestDaily,
+			From:                 time.Now().AddDate(0, 0, -1).Format("2006-01-02"),
+			To:                   time.Now().AddDate(0, 0, -1).Format("2006-01-02"),
+			Revenue:              []DigestRevenue{{PluginName: "Example Plugin", Currency: "USD", Orders: 3, Amount: "297.00"}},
+			RevenueTotals:        []DigestRevenue{{Currency: "USD", Orders: 3, Amount: "297.00"}},
+			NewUsers:             5,
+			TotalUsers:           120,
+			ExpiringLicenses:     []DigestLicense{{PluginName: "Example Plugin", UserEmail: "jane@example.com", MaintenanceUntil: time.Now().AddDate(0, 0, 7).Format("2006-01-02")}},
+			ExpiringLicenseCount: 1,
+			FailedPayments:       []DigestOrder{{OrderNumber: "ORD-20240101-0002", PluginName: "Example Plugin", UserEmail: "john@example.com", Amount: "99.00 USD"}},
+			FailedPaymentCount:   1,
+			WebhookErrors:        []DigestWebhookError{{URL: "https://hooks.example.com/store", Event: "order.paid", Attempts: 8, Error: "endpoint returned 500"}},
+			WebhookErrorCount:    1,
+			DashboardURL:         "https://example.com/admin",
+		},
+	}
+}
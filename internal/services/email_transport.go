@@ -0,0 +1,373 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"gopkg.in/gomail.v2"
+)
+
+// EmailMessage is a rendered email handed to a transport
+type EmailMessage struct {
+	From     string // "Name <address>"
+	To       string
+	Subject  string
+	HTMLBody string
+	TextBody string
+	Headers  map[string]string
+}
+
+// EmailTransport delivers emails
+type EmailTransport interface {
+	Send(ctx context.Context, msg *EmailMessage) error
+}
+
+// NewEmailTransport builds the transport selected by EMAIL_TRANSPORT
+func NewEmailTransport(cfg *config.Config) EmailTransport {
+	switch cfg.EmailTransport {
+	case "http":
+		return &HTTPEmailTransport{
+			url:     cfg.EmailHTTPURL,
+			format:  cfg.EmailHTTPFormat,
+			apiKey:  cfg.EmailHTTPAPIKey,
+			apiUser: cfg.EmailHTTPAPIUser,
+			client:  &http.Client{Timeout: 30 * time.Second},
+		}
+	case "mailbox":
+		return NewMailbox(cfg.EmailMailboxDir)
+	default:
+		return &SMTPTransport{
+			dialer: gomail.NewDialer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword),
+		}
+	}
+}
+
+// EmailTransportConfigured reports whether the transport selected by EMAIL_TRANSPORT
+// has what it needs to deliver emails
+func EmailTransportConfigured(cfg *config.Config) bool {
+	switch cfg.EmailTransport {
+	case "http":
+		return cfg.EmailHTTPURL != ""
+	case "mailbox":
+		return cfg.EmailMailboxDir != ""
+	default:
+		return cfg.SMTPHost != ""
+	}
+}
+
+// gomailMessage builds the MIME message with a plain-text part and an HTML alternative
+func (m *EmailMessage) gomailMessage() *gomail.Message {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.From)
+	msg.SetHeader("To", m.To)
+	msg.SetHeader("Subject", m.Subject)
+	msg.SetDateHeader("Date", time.Now())
+	for key, value := range m.Headers {
+		msg.SetHeader(key, value)
+	}
+	msg.SetBody("text/plain", m.TextBody)
+	msg.AddAlternative("text/html", m.HTMLBody)
+	return msg
+}
+
+// SMTPTransport sends emails through an SMTP server
+type SMTPTransport struct {
+	dialer *gomail.Dialer
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg *EmailMessage) error {
+	if err := t.dialer.DialAndSend(msg.gomailMessage()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// HTTPEmailTransport posts emails to an email API. The form format matches Mailgun's
+// messages endpoint; the json format posts {from, to, subject, html, text, headers}
+// for providers or relays that accept JSON.
+type HTTPEmailTransport struct {
+	url     string
+	format  string
+	apiKey  string
+	apiUser string
+	client  *http.Client
+}
+
+func (t *HTTPEmailTransport) Send(ctx context.Context, msg *EmailMessage) error {
+	if t.url == "" {
+		return errors.New("EMAIL_HTTP_URL is not configured")
+	}
+
+	var body io.Reader
+	var contentType string
+	if t.format == "json" {
+		data, err := json.Marshal(map[string]interface{}{
+			"from":    msg.From,
+			"to":      msg.To,
+			"subject": msg.Subject,
+			"html":    msg.HTMLBody,
+			"text":    msg.TextBody,
+			"headers": msg.Headers,
+		})
+		if err != nil {
+			return err
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	} else {
+		form := url.Values{}
+		form.Set("from", msg.From)
+		form.Set("to", msg.To)
+		form.Set("subject", msg.Subject)
+		form.Set("html", msg.HTMLBody)
+		form.Set("text", msg.TextBody)
+		for key, value := range msg.Headers {
+			form.Set("h:"+key, value)
+		}
+		body, contentType = strings.NewReader(form.Encode()), "application/x-www-form-urlencoded"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.apiKey != "" {
+		if t.apiUser != "" {
+			req.SetBasicAuth(t.apiUser, t.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+t.apiKey)
+		}
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("email API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+var mailboxFilePattern = regexp.MustCompile(`^[0-9A-Za-z_-]+\.eml$`)
+
+// ErrMailboxMessageNotFound is returned for unknown or invalid mailbox file names
+var ErrMailboxMessageNotFound = errors.New("mailbox message not found")
+
+// Mailbox is a development transport that writes every email to a .eml file
+// instead of sending it
+type Mailbox struct {
+	dir string
+}
+
+func NewMailbox(dir string) *Mailbox {
+	return &Mailbox{dir: dir}
+}
+
+// MailboxEntry summarizes a stored email
+type MailboxEntry struct {
+	Name    string    `json:"name"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Size    int64     `json:"size"`
+}
+
+// MailboxMessage is a stored email with its decoded parts
+type MailboxMessage struct {
+	MailboxEntry
+	Headers  map[string]string `json:"headers"`
+	TextBody string            `json:"text_body"`
+	HTMLBody string            `json:"html_body"`
+}
+
+func (b *Mailbox) Send(ctx context.Context, msg *EmailMessage) error {
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mailbox: %w", err)
+	}
+
+	// Names sort by time of sending
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s%09d-%s.eml", now.Format("20060102T150405"), now.Nanosecond(), uuid.New().String()[:8])
+
+	f, err := os.Create(filepath.Join(b.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to write mailbox message: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := msg.gomailMessage().WriteTo(f); err != nil {
+		return fmt.Errorf("failed to write mailbox message: %w", err)
+	}
+	return nil
+}
+
+// List returns the stored emails, newest first
+func (b *Mailbox) List() ([]MailboxEntry, error) {
+	files, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []MailboxEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]MailboxEntry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !mailboxFilePattern.MatchString(file.Name()) {
+			continue
+		}
+		message, err := b.Read(file.Name())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, message.MailboxEntry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name > entries[j].Name })
+	return entries, nil
+}
+
+// Raw returns the .eml content of a stored email
+func (b *Mailbox) Raw(name string) ([]byte, error) {
+	if !mailboxFilePattern.MatchString(name) {
+		return nil, ErrMailboxMessageNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMailboxMessageNotFound
+	}
+	return data, err
+}
+
+// Read parses a stored email
+func (b *Mailbox) Read(name string) (*MailboxMessage, error) {
+	data, err := b.Raw(name)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	header := func(key string) string {
+		value := parsed.Header.Get(key)
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			return decoded
+		}
+		return value
+	}
+
+	message := &MailboxMessage{
+		MailboxEntry: MailboxEntry{
+			Name:    name,
+			From:    header("From"),
+			To:      header("To"),
+			Subject: header("Subject"),
+			Size:    int64(len(data)),
+		},
+		Headers: map[string]string{},
+	}
+	message.Date, _ = parsed.Header.Date()
+	for key := range parsed.Header {
+		message.Headers[key] = header(key)
+	}
+
+	if err := readMailboxParts(parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"), parsed.Body, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// readMailboxParts collects the text and HTML parts of a (multipart) body
+func readMailboxParts(contentType, encoding string, body io.Reader, message *MailboxMessage) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := readMailboxParts(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, message); err != nil {
+				return err
+			}
+		}
+	}
+
+	if strings.EqualFold(encoding, "quoted-printable") {
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	switch mediaType {
+	case "text/html":
+		message.HTMLBody = string(data)
+	case "text/plain":
+		message.TextBody = string(data)
+	}
+	return nil
+}
+
+// Delete removes a stored email
+func (b *Mailbox) Delete(name string) error {
+	if !mailboxFilePattern.MatchString(name) {
+		return ErrMailboxMessageNotFound
+	}
+	err := os.Remove(filepath.Join(b.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrMailboxMessageNotFound
+	}
+	return err
+}
+
+// Clear removes all stored emails
+func (b *Mailbox) Clear() error {
+	files, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !file.IsDir() && mailboxFilePattern.MatchString(file.Name()) {
+			if err := os.Remove(filepath.Join(b.dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
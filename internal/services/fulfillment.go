@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/utils"
+	"gorm.io/gorm"
+)
+
+// FulfillmentService turns paid orders into licenses and queues repository access
+type FulfillmentService struct {
+	db       *gorm.DB
+	config   *config.Config
+	emailSvc *EmailService
+}
+
+func NewFulfillmentService(db *gorm.DB, cfg *config.Config) *FulfillmentService {
+	return &FulfillmentService{
+		db:       db,
+		config:   cfg,
+		emailSvc: NewEmailService(cfg, db),
+	}
+}
+
+// FulfillOrder creates or reactivates the license for a paid order and queues
+// repository access for it. Pass the transaction that marks the order as paid so
+// the order, the license and everything queued for them are committed together.
+func (s *FulfillmentService) FulfillOrder(tx *gorm.DB, order *models.Order) (*models.License, error) {
+	var githubAccountID *uuid.UUID
+	var githubAccount models.GitHubAccount
+	err := tx.Where("user_id = ?", order.UserID).First(&githubAccount).Error
+	switch {
+	case err == nil:
+		githubAccountID = &githubAccount.ID
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, fmt.Errorf("failed to find GitHub account: %w", err)
+	}
+
+	var plugin models.Plugin
+	if err := tx.First(&plugin, "id = ?", order.PluginID).Error; err != nil {
+		return nil, fmt.Errorf("failed to find plugin: %w", err)
+	}
+
+	maintenanceMonths := plugin.DefaultMaintenanceMonths
+	if maintenanceMonths == 0 {
+		maintenanceMonths = s.config.DefaultMaintenanceMonths
+	}
+	if maintenanceMonths == 0 {
+		maintenanceMonths = 12
+	}
+	maintenanceUntil := utils.CalculateMaintenanceUntil(maintenanceMonths)
+
+	var user models.User
+	if err := tx.First(&user, "id = ?", order.UserID).Error; err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	renewal := false
+	var license models.License
+	err = tx.Where("user_id = ? AND plugin_id = ?", order.UserID, order.PluginID).First(&license).Error
+	switch {
+	case err == nil:
+		// Buying a plugin again after a refund or revocation is a new purchase
+		renewal = license.Status != "revoked"
+		license.OrderID = order.ID
+		if githubAccountID != nil {
+			license.GitHubAccountID = githubAccountID
+		}
+		license.LicenseType = "permanent"
+		license.Status = "active"
+		license.MaintenanceUntil = maintenanceUntil
+		license.RevokedReason = ""
+		license.RevokedAt = nil
+		if err := tx.Save(&license).Error; err != nil {
+			return nil, fmt.Errorf("failed to update license: %w", err)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		license = models.License{
+			UserID:           order.UserID,
+			PluginID:         order.PluginID,
+			OrderID:          order.ID,
+			GitHubAccountID:  githubAccountID,
+			LicenseType:      "permanent",
+			Status:           "active",
+			MaintenanceUntil: maintenanceUntil,
+		}
+		if err := tx.Create(&license).Error; err != nil {
+			return nil, fmt.Errorf("failed to create license: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to look up license: %w", err)
+	}
+
+	history := models.LicenseHistory{
+		LicenseID:  license.ID,
+		Action:     "granted",
+		Metadata:   fmt.Sprintf(`{"order_id": "%s"}`, order.ID),
+		OccurredAt: time.Now(),
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to create license history: %w", err)
+	}
+
+	if renewal {
+		err = s.emailSvc.QueueRenewalSuccessEmail(tx, &user, &plugin, order, &license)
+		if err == nil {
+			err = NotifyLicense(tx, models.NotificationTypeLicenseRenewed, &user, &plugin, &license, nil)
+		}
+	} else {
+		err = s.emailSvc.QueuePurchaseSuccessEmail(tx, &user, &plugin, order, &license)
+		if err == nil {
+			err = NotifyLicense(tx, models.NotificationTypeLicenseGranted, &user, &plugin, &license, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := EmitWebhookEvent(tx, models.WebhookEventOrderPaid, OrderWebhookData(order, &user, &plugin)); err != nil {
+		return nil, err
+	}
+	if err := NotifyChatOps(tx, ChatOpsOrderMessage(ChatOpsOrderPaid, order, &user, &plugin)); err != nil {
+		return nil, err
+	}
+	if err := MarkCheckoutRecovered(tx, order); err != nil {
+		return nil, err
+	}
+	licenseData := LicenseWebhookData(&license, &user, &plugin)
+	licenseData["renewal"] = renewal
+	if err := EmitWebhookEvent(tx, models.WebhookEventLicenseGranted, licenseData); err != nil {
+		return nil, err
+	}
+
+	if plugin.GitHubRepoName == "" {
+		log.Printf("[Fulfillment] Plugin %s has no GitHub repository, skipping access grant", plugin.Slug)
+		return &license, nil
+	}
+	if license.GitHubAccountID == nil {
+		log.Printf("[Fulfillment] License %s waits for the buyer to link GitHub before repository access", license.ID)
+		if err := NotifyLicense(tx, models.NotificationTypeGitHubLinkNeeded, &user, &plugin, &license, nil); err != nil {
+			return nil, err
+		}
+		return &license, nil
+	}
+
+	if err := EnqueueAccessGrant(tx, license.ID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Fulfillment] License %s fulfilled for order %s", license.ID, order.OrderNumber)
+	return &license, nil
+}
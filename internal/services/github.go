@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/google/go-github/v57/github"
 	"github.com/nodeloc/git-store/internal/config"
@@ -89,3 +93,122 @@ func (s *GitHubService) GetRepository(ctx context.Context, owner, repo string) (
 
 	return repository, nil
 }
+
+// ListReleases lists all published releases of a repository, newest first
+func (s *GitHubService) ListReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
+	var allReleases []*github.RepositoryRelease
+	opt := &github.ListOptions{PerPage: 100}
+
+	for {
+		releases, resp, err := s.client.Repositories.ListReleases(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+
+		allReleases = append(allReleases, releases...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allReleases, nil
+}
+
+// ListTags lists all tags of a repository
+func (s *GitHubService) ListTags(ctx context.Context, owner, repo string) ([]*github.RepositoryTag, error) {
+	var allTags []*github.RepositoryTag
+	opt := &github.ListOptions{PerPage: 100}
+
+	for {
+		tags, resp, err := s.client.Repositories.ListTags(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+
+		allTags = append(allTags, tags...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allTags, nil
+}
+
+// GetCommitDate returns the committer date of a commit
+func (s *GitHubService) GetCommitDate(ctx context.Context, owner, repo, sha string) (time.Time, error) {
+	commit, _, err := s.client.Repositories.GetCommit(ctx, owner, repo, sha, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get commit: %w", err)
+	}
+
+	return commit.GetCommit().GetCommitter().GetDate().Time, nil
+}
+
+// OpenArchive streams the source archive of a ref ("zip" or "tar") using the store's credentials.
+// The caller must close the returned body.
+func (s *GitHubService) OpenArchive(ctx context.Context, owner, repo, format, ref string) (io.ReadCloser, int64, error) {
+	archiveFormat := github.Zipball
+	if format == "tar" {
+		archiveFormat = github.Tarball
+	}
+
+	link, _, err := s.client.Repositories.GetArchiveLink(ctx, owner, repo, archiveFormat,
+		&github.RepositoryContentGetOptions{Ref: ref}, 1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get archive link: %w", err)
+	}
+
+	// The archive link is short-lived and already carries a token for private repositories
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download archive: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("failed to download archive: status %d", resp.StatusCode)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// OpenReleaseAsset streams a release asset using the store's credentials.
+// The caller must close the returned body.
+func (s *GitHubService) OpenReleaseAsset(ctx context.Context, owner, repo string, assetID int64) (io.ReadCloser, error) {
+	body, _, err := s.client.Repositories.DownloadReleaseAsset(ctx, owner, repo, assetID, http.DefaultClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download release asset: %w", err)
+	}
+	return body, nil
+}
+
+// ErrFileNotFound is returned by GetFileContent when the file does not exist at the ref
+var ErrFileNotFound = errors.New("file not found")
+
+// GetFileContent returns the content of a file at a ref
+func (s *GitHubService) GetFileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	file, _, resp, err := s.client.Repositories.GetContents(ctx, owner, repo, path,
+		&github.RepositoryContentGetOptions{Ref: ref})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file content: %w", err)
+	}
+	if file == nil {
+		return nil, ErrFileNotFound
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode file content: %w", err)
+	}
+	return []byte(content), nil
+}
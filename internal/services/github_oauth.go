@@ -30,12 +30,13 @@ func NewGitHubOAuthService(cfg *config.Config) *GitHubOAuthService {
 	}
 }
 
-func (s *GitHubOAuthService) GetAuthURL(state string) string {
-	return s.oauth.AuthCodeURL(state, oauth2.AccessTypeOnline)
+// GetAuthURL returns the GitHub authorization URL with a PKCE S256 challenge for the verifier
+func (s *GitHubOAuthService) GetAuthURL(state, verifier string) string {
+	return s.oauth.AuthCodeURL(state, oauth2.AccessTypeOnline, oauth2.S256ChallengeOption(verifier))
 }
 
-func (s *GitHubOAuthService) ExchangeCode(ctx context.Context, code string) (*oauth2.Token, error) {
-	token, err := s.oauth.Exchange(ctx, code)
+func (s *GitHubOAuthService) ExchangeCode(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	token, err := s.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nodeloc/git-store/internal/models"
+)
+
+var (
+	semverTagPattern   = regexp.MustCompile(`^v(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?$`)
+	majorSuffixPattern = regexp.MustCompile(`^(.+)/v([2-9]|[1-9][0-9]+)$`)
+)
+
+// GoModulePath returns the module path a plugin is served under by the Go module proxy
+func GoModulePath(plugin *models.Plugin) string {
+	if plugin.GoModulePath != "" {
+		return plugin.GoModulePath
+	}
+	return "github.com/" + plugin.GitHubRepoName
+}
+
+// SplitModuleMajor splits a "/vN" major version suffix off a module path. Paths without one are major 0/1.
+func SplitModuleMajor(modulePath string) (string, int) {
+	m := majorSuffixPattern.FindStringSubmatch(modulePath)
+	if m == nil {
+		return modulePath, 1
+	}
+	major, _ := strconv.Atoi(m[2])
+	return m[1], major
+}
+
+// GoModuleVersion reports whether a tag is a module version servable under the given major version
+func GoModuleVersion(tag string, major int) bool {
+	m := semverTagPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return false
+	}
+	tagMajor, _ := strconv.Atoi(m[1])
+	if major <= 1 {
+		return tagMajor <= 1
+	}
+	return tagMajor == major
+}
+
+// UnescapeModulePath decodes the case encoding of the GOPROXY protocol ("!a" stands for "A")
+func UnescapeModulePath(escaped string) (string, error) {
+	var b strings.Builder
+	bang := false
+	for _, r := range escaped {
+		if bang {
+			if r < 'a' || r > 'z' {
+				return "", fmt.Errorf("invalid escaped module path %q", escaped)
+			}
+			b.WriteRune(unicode.ToUpper(r))
+			bang = false
+			continue
+		}
+		if r == '!' {
+			bang = true
+			continue
+		}
+		if unicode.IsUpper(r) {
+			return "", fmt.Errorf("invalid escaped module path %q", escaped)
+		}
+		b.WriteRune(r)
+	}
+	if bang {
+		return "", fmt.Errorf("invalid escaped module path %q", escaped)
+	}
+	return b.String(), nil
+}
+
+// WriteModuleZip repacks a GitHub source zipball into the module zip layout expected by the go command:
+// every file under "<module>@<version>/", without vendor directories and nested modules.
+func WriteModuleZip(w io.Writer, archive *os.File, modulePath, version string) error {
+	info, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+	src, err := zip.NewReader(archive, info.Size())
+	if err != nil {
+		return fmt.Errorf("failed to read source archive: %w", err)
+	}
+
+	// GitHub wraps the sources in a single "<owner>-<repo>-<sha>/" directory
+	type entry struct {
+		name string
+		file *zip.File
+	}
+	var entries []entry
+	nested := map[string]bool{}
+	for _, f := range src.File {
+		if f.FileInfo().IsDir() || !f.Mode().IsRegular() {
+			continue
+		}
+		parts := strings.SplitN(f.Name, "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			continue
+		}
+		name := parts[1]
+		if path.Base(name) == "go.mod" && name != "go.mod" {
+			nested[path.Dir(name)+"/"] = true
+		}
+		entries = append(entries, entry{name: name, file: f})
+	}
+
+	dst := zip.NewWriter(w)
+	prefix := modulePath + "@" + version + "/"
+	for _, e := range entries {
+		if excludedFromModule(e.name, nested) {
+			continue
+		}
+
+		out, err := dst.Create(prefix + e.name)
+		if err != nil {
+			return err
+		}
+		in, err := e.file.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return dst.Close()
+}
+
+// excludedFromModule reports whether a file is left out of module zips: vendored
+// code and files that belong to a nested module
+func excludedFromModule(name string, nested map[string]bool) bool {
+	// Like the go command, keep files directly in vendor/ (modules.txt) but drop vendored packages
+	if strings.HasPrefix(name, "vendor/") {
+		return strings.Contains(strings.TrimPrefix(name, "vendor/"), "/")
+	}
+	if strings.Contains(name, "/vendor/") {
+		return true
+	}
+	for dir := range nested {
+		if strings.HasPrefix(name, dir) {
+			return true
+		}
+	}
+	return false
+}
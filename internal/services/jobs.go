@@ -0,0 +1,21 @@
+package services
+
+import (
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/jobs"
+	"gorm.io/gorm"
+)
+
+// RegisterJobHandlers installs the background job handlers provided by the services package
+func RegisterJobHandlers(queue *jobs.Queue, db *gorm.DB, cfg *config.Config) {
+	var githubSvc *GitHubService
+	if cfg.GitHubAdminToken != "" {
+		githubSvc = NewGitHubService(cfg)
+	}
+
+	NewAccessGrantService(db, cfg, githubSvc).Register(queue)
+	NewEmailService(cfg, db).Register(queue)
+	NewReleaseAnnouncementService(db, cfg).Register(queue)
+	NewWebhookService(db).Register(queue)
+	NewChatOpsService(db, cfg).Register(queue)
+}
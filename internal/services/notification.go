@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// notificationText is the title and message of a notification type, as text/templates
+// over the notification's data
+type notificationText struct {
+	title   string
+	message string
+}
+
+var notificationTexts = map[string]map[string]notificationText{
+	models.NotificationTypeLicenseGranted: {
+		"en": {"Your license for {{.plugin}} is active", "Thank you for your purchase. Maintenance runs until {{.maintenance_until}}."},
+		"zh": {"{{.plugin}} 许可证已激活", "感谢您的购买，维护期至 {{.maintenance_until}}。"},
+	},
+	models.NotificationTypeLicenseRenewed: {
+		"en": {"{{.plugin}} maintenance renewed", "Maintenance now runs until {{.maintenance_until}}."},
+		"zh": {"{{.plugin}} 维护期已续费", "维护期现至 {{.maintenance_until}}。"},
+	},
+	models.NotificationTypeGitHubLinkNeeded: {
+		"en": {"Link GitHub to access {{.plugin}}", "Link your GitHub account to be invited to the {{.plugin}} repository."},
+		"zh": {"关联 GitHub 以访问 {{.plugin}}", "关联您的 GitHub 账号后即可受邀加入 {{.plugin}} 仓库。"},
+	},
+	models.NotificationTypeAccessGranted: {
+		"en": {"Your access to {{.plugin}} is live", "@{{.github_login}} was invited to the repository. Accept the invitation on GitHub to start pulling the code."},
+		"zh": {"{{.plugin}} 访问权限已开通", "@{{.github_login}} 已被邀请加入仓库，请在 GitHub 上接受邀请后拉取代码。"},
+	},
+	models.NotificationTypeLicenseExpiring: {
+		"en": {"Your {{.plugin}} maintenance expires in {{.days}} days", "Renew before {{.maintenance_until}} to keep receiving updates."},
+		"zh": {"{{.plugin}} 维护期将在 {{.days}} 天后到期", "请在 {{.maintenance_until}} 前续费以继续获取更新。"},
+	},
+	models.NotificationTypeGracePeriodEnding: {
+		"en": {"{{.plugin}} grace period ends in {{.days}} days", "Repository access becomes read-only on {{.grace_until}} unless you renew."},
+		"zh": {"{{.plugin}} 宽限期将在 {{.days}} 天后结束", "如未续费，仓库访问将于 {{.grace_until}} 变为只读。"},
+	},
+	models.NotificationTypeLicenseExpired: {
+		"en": {"{{.plugin}} maintenance has expired", "Releases published until {{.maintenance_until}} stay yours. Renew to get new versions."},
+		"zh": {"{{.plugin}} 维护期已到期", "{{.maintenance_until}} 之前发布的版本仍可使用，续费后可获取新版本。"},
+	},
+	models.NotificationTypeAccessDowngraded: {
+		"en": {"{{.plugin}} repository access is now read-only", "Renew to receive new releases again."},
+		"zh": {"{{.plugin}} 仓库访问已变为只读", "续费后可再次获取新版本。"},
+	},
+	models.NotificationTypeOrderRefunded: {
+		"en": {"Order {{.order_number}} refunded", "Your order for {{.plugin}} was refunded and its license revoked."},
+		"zh": {"订单 {{.order_number}} 已退款", "您购买 {{.plugin}} 的订单已退款，许可证已撤销。"},
+	},
+	models.NotificationTypeReleaseAvailable: {
+		"en": {"{{.plugin}} {{.version}} is available", "A new version included in your maintenance has been released."},
+		"zh": {"{{.plugin}} {{.version}} 已发布", "新版本已发布，包含在您的维护期内。"},
+	},
+	models.NotificationTypeReleaseRenewal: {
+		"en": {"{{.plugin}} {{.version}} is out", "Renew your maintenance to get this version."},
+		"zh": {"{{.plugin}} {{.version}} 已发布", "续费维护即可获取此版本。"},
+	},
+}
+
+// Notify adds an entry to a user's notification center, written in the user's
+// language. Pass the transaction of the change it reports.
+func Notify(tx *gorm.DB, user *models.User, notificationType, link string, data map[string]interface{}) error {
+	texts, ok := notificationTexts[notificationType]
+	if !ok {
+		return fmt.Errorf("unknown notification type: %s", notificationType)
+	}
+	text, ok := texts[user.Language]
+	if !ok {
+		text = texts[models.DefaultLanguage]
+	}
+
+	title, err := renderNotificationText(text.title, data)
+	if err != nil {
+		return err
+	}
+	message, err := renderNotificationText(text.message, data)
+	if err != nil {
+		return err
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		UserID:  user.ID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+		Link:    link,
+		Data:    string(dataJSON),
+	}
+	if err := tx.Create(&notification).Error; err != nil {
+		return fmt.Errorf("failed to create %s notification: %w", notificationType, err)
+	}
+	return nil
+}
+
+func renderNotificationText(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("notification").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// NotifyLicense adds a notification about a license that links to the license page.
+// extra adds to the plugin, license and maintenance date the text can use.
+func NotifyLicense(tx *gorm.DB, notificationType string, user *models.User, plugin *models.Plugin, license *models.License, extra map[string]interface{}) error {
+	data := map[string]interface{}{
+		"plugin":            plugin.Name,
+		"plugin_slug":       plugin.Slug,
+		"license_id":        license.ID,
+		"maintenance_until": license.MaintenanceUntil.Format("2006-01-02"),
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+	return Notify(tx, user, notificationType, "/licenses/"+license.ID.String(), data)
+}
+
+// feedOverlap is how far each poll of a NotificationFeed looks back. Notifications
+// are written inside the transaction of the change they report, so one can become
+// visible after a newer one was already streamed.
+const feedOverlap = 2 * time.Minute
+
+// NotificationFeed yields a user's new notifications for the SSE stream
+type NotificationFeed struct {
+	db     *gorm.DB
+	userID uuid.UUID
+	since  time.Time               // creation time of the newest notification seen
+	seen   map[uuid.UUID]time.Time // notifications seen within feedOverlap of since
+}
+
+// NewNotificationFeed starts a feed of the notifications created after since. The
+// ones created before count as seen.
+func NewNotificationFeed(db *gorm.DB, userID uuid.UUID, since time.Time) (*NotificationFeed, error) {
+	f := &NotificationFeed{db: db, userID: userID, since: since, seen: map[uuid.UUID]time.Time{}}
+
+	var existing []models.Notification
+	if err := db.Select("id", "created_at").
+		Where("user_id = ? AND created_at > ? AND created_at <= ?", userID, since.Add(-feedOverlap), since).
+		Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	for _, n := range existing {
+		f.seen[n.ID] = n.CreatedAt
+	}
+	return f, nil
+}
+
+// Poll returns the notifications that became visible since the last poll, oldest first
+func (f *NotificationFeed) Poll() ([]models.Notification, error) {
+	var notifications []models.Notification
+	if err := f.db.Where("user_id = ? AND created_at > ?", f.userID, f.since.Add(-feedOverlap)).
+		Order("created_at ASC").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+
+	fresh := make([]models.Notification, 0, len(notifications))
+	for _, n := range notifications {
+		if _, ok := f.seen[n.ID]; ok {
+			continue
+		}
+		f.seen[n.ID] = n.CreatedAt
+		if n.CreatedAt.After(f.since) {
+			f.since = n.CreatedAt
+		}
+		fresh = append(fresh, n)
+	}
+
+	for id, createdAt := range f.seen {
+		if !createdAt.After(f.since.Add(-feedOverlap)) {
+			delete(f.seen, id)
+		}
+	}
+	return fresh, nil
+}
+
+// UnreadNotificationCount counts a user's unread notifications
+func UnreadNotificationCount(db *gorm.DB, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nodeloc/git-store/internal/config"
+	"golang.org/x/oauth2"
+)
+
+var ErrNoVerifiedEmail = errors.New("the provider account has no verified email address")
+
+// OAuthIdentity is the account a user authenticated with at an OAuth provider
+type OAuthIdentity struct {
+	Provider    string
+	Subject     string // stable user ID at the provider
+	Login       string
+	Name        string
+	AvatarURL   string
+	Emails      []string // verified addresses, primary first
+	AccessToken string
+}
+
+// OAuthProvider is a login provider using the authorization code flow with PKCE
+type OAuthProvider interface {
+	Name() string
+	AuthURL(state, verifier string) string
+	Identify(ctx context.Context, code, verifier string) (*OAuthIdentity, error)
+}
+
+// NewOAuthProviders returns the configured login providers by name. GitHub is always available.
+func NewOAuthProviders(cfg *config.Config) map[string]OAuthProvider {
+	providers := map[string]OAuthProvider{
+		"github": &githubProvider{svc: NewGitHubOAuthService(cfg)},
+	}
+	if cfg.GoogleClientID != "" {
+		providers["google"] = newOIDCProvider("google", "https://accounts.google.com", cfg.GoogleClientID, cfg.GoogleClientSecret, cfg)
+	}
+	if cfg.GitLabClientID != "" {
+		providers["gitlab"] = newOIDCProvider("gitlab", strings.TrimRight(cfg.GitLabURL, "/"), cfg.GitLabClientID, cfg.GitLabClientSecret, cfg)
+	}
+	return providers
+}
+
+// githubProvider adapts GitHubOAuthService to OAuthProvider
+type githubProvider struct {
+	svc *GitHubOAuthService
+}
+
+func (p *githubProvider) Name() string { return "github" }
+
+func (p *githubProvider) AuthURL(state, verifier string) string {
+	return p.svc.GetAuthURL(state, verifier)
+}
+
+func (p *githubProvider) Identify(ctx context.Context, code, verifier string) (*OAuthIdentity, error) {
+	token, err := p.svc.ExchangeCode(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	githubUser, err := p.svc.GetUserInfo(ctx, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := p.svc.GetUserEmails(ctx, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &OAuthIdentity{
+		Provider:    "github",
+		Subject:     strconv.FormatInt(githubUser.GetID(), 10),
+		Login:       githubUser.GetLogin(),
+		Name:        githubUser.GetName(),
+		AvatarURL:   githubUser.GetAvatarURL(),
+		AccessToken: token.AccessToken,
+	}
+	for _, email := range emails {
+		if !email.GetVerified() {
+			continue
+		}
+		if email.GetPrimary() {
+			identity.Emails = append([]string{email.GetEmail()}, identity.Emails...)
+		} else {
+			identity.Emails = append(identity.Emails, email.GetEmail())
+		}
+	}
+	return identity, nil
+}
+
+// oidcProvider logs in with an OpenID Connect provider, configured through discovery.
+// Claims are read from the userinfo endpoint over TLS, so ID tokens need no signature checks.
+type oidcProvider struct {
+	name   string
+	issuer string
+	oauth  oauth2.Config
+
+	mu          sync.Mutex
+	discovered  bool
+	userinfoURL string
+}
+
+func newOIDCProvider(name, issuer, clientID, clientSecret string, cfg *config.Config) *oidcProvider {
+	return &oidcProvider{
+		name:   name,
+		issuer: issuer,
+		oauth: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  fmt.Sprintf("%s/api/auth/%s/callback", cfg.AppURL, name),
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+	}
+}
+
+func (p *oidcProvider) Name() string { return p.name }
+
+// discover loads the provider endpoints once; failures are retried on the next login
+func (p *oidcProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to discover %s: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to discover %s: status %d", p.name, resp.StatusCode)
+	}
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode %s discovery document: %w", p.name, err)
+	}
+
+	p.oauth.Endpoint = oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint}
+	p.userinfoURL = doc.UserinfoEndpoint
+	p.discovered = true
+	return nil
+}
+
+func (p *oidcProvider) AuthURL(state, verifier string) string {
+	if err := p.discover(context.Background()); err != nil {
+		return ""
+	}
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *oidcProvider) Identify(ctx context.Context, code, verifier string) (*OAuthIdentity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	resp, err := p.oauth.Client(ctx, token).Get(p.userinfoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get user info: status %d", resp.StatusCode)
+	}
+
+	var claims struct {
+		Subject           string `json:"sub"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		Name              string `json:"name"`
+		Picture           string `json:"picture"`
+		PreferredUsername string `json:"preferred_username"`
+		Nickname          string `json:"nickname"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("user info has no subject")
+	}
+
+	identity := &OAuthIdentity{
+		Provider:    p.name,
+		Subject:     claims.Subject,
+		Login:       claims.PreferredUsername,
+		Name:        claims.Name,
+		AvatarURL:   claims.Picture,
+		AccessToken: token.AccessToken,
+	}
+	if identity.Login == "" {
+		identity.Login = claims.Nickname
+	}
+	if claims.Email != "" && claims.EmailVerified {
+		identity.Emails = []string{claims.Email}
+	}
+	return identity, nil
+}
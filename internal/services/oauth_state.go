@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrOAuthStateNotFound = errors.New("unknown or expired OAuth state")
+
+// OAuthStateStore keeps pending OAuth logins between the redirect to the provider and the callback.
+// It must be shared by every instance of the application.
+type OAuthStateStore interface {
+	Save(ctx context.Context, state *models.OAuthState) error
+	// Consume returns and removes a state; it returns ErrOAuthStateNotFound for unknown or expired states
+	Consume(ctx context.Context, state string) (*models.OAuthState, error)
+}
+
+// NewOAuthStateStore returns the store selected by OAUTH_STATE_STORE (postgres or redis).
+// The redis store fails when REDIS_URL is invalid or the server does not answer.
+func NewOAuthStateStore(db *gorm.DB, cfg *config.Config) (OAuthStateStore, error) {
+	if cfg.OAuthStateStore != "redis" {
+		return &dbOAuthStateStore{db: db}, nil
+	}
+
+	options, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to reach redis: %w", err)
+	}
+	log.Printf("[OAuth] Keeping login state in redis at %s", options.Addr)
+	return &redisOAuthStateStore{client: client}, nil
+}
+
+// NewOAuthState starts a login with a random state and PKCE verifier
+func NewOAuthState(redirectTo string, ttl time.Duration) (*models.OAuthState, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &models.OAuthState{
+		State:        hex.EncodeToString(b),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		RedirectTo:   redirectTo,
+		ExpiresAt:    time.Now().Add(ttl),
+	}, nil
+}
+
+// dbOAuthStateStore keeps states in the oauth_states table
+type dbOAuthStateStore struct {
+	db *gorm.DB
+}
+
+func (s *dbOAuthStateStore) Save(ctx context.Context, state *models.OAuthState) error {
+	// Abandoned logins are cleaned up as new ones start
+	s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.OAuthState{})
+	return s.db.WithContext(ctx).Create(state).Error
+}
+
+func (s *dbOAuthStateStore) Consume(ctx context.Context, state string) (*models.OAuthState, error) {
+	var deleted []models.OAuthState
+	result := s.db.WithContext(ctx).Clauses(clause.Returning{}).
+		Where("state = ?", state).Delete(&deleted)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(deleted) == 0 || time.Now().After(deleted[0].ExpiresAt) {
+		return nil, ErrOAuthStateNotFound
+	}
+	return &deleted[0], nil
+}
+
+// redisOAuthStateStore keeps states in a Redis-compatible server (GETDEL requires Redis 6.2+)
+type redisOAuthStateStore struct {
+	client *redis.Client
+}
+
+const redisOAuthStatePrefix = "oauth_state:"
+
+func (s *redisOAuthStateStore) Save(ctx context.Context, state *models.OAuthState) error {
+	state.CreatedAt = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(state.ExpiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("OAuth state already expired")
+	}
+	return s.client.Set(ctx, redisOAuthStatePrefix+state.State, data, ttl).Err()
+}
+
+func (s *redisOAuthStateStore) Consume(ctx context.Context, state string) (*models.OAuthState, error) {
+	data, err := s.client.GetDel(ctx, redisOAuthStatePrefix+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrOAuthStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored models.OAuthState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode OAuth state: %w", err)
+	}
+	return &stored, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nodeloc/git-store/internal/models"
+)
+
+// PackageVersion converts a release tag ("v1.2.3" or "1.2.3") to the plain semver used by npm and Composer
+func PackageVersion(tag string) (string, bool) {
+	version := "v" + strings.TrimPrefix(tag, "v")
+	if !semverTagPattern.MatchString(version) {
+		return "", false
+	}
+	return strings.TrimPrefix(version, "v"), true
+}
+
+// NpmVersionMetadata builds the packument entry of one version from its package.json
+func NpmVersionMetadata(plugin *models.Plugin, release *models.PluginRelease, version string, manifest []byte, tarballURL string) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if len(manifest) > 0 {
+		json.Unmarshal(manifest, &metadata)
+	}
+
+	metadata["name"] = plugin.NpmPackage
+	metadata["version"] = version
+	metadata["_id"] = plugin.NpmPackage + "@" + version
+	if release.CommitSHA != "" {
+		metadata["gitHead"] = release.CommitSHA
+	}
+	metadata["dist"] = map[string]interface{}{
+		"tarball": tarballURL,
+	}
+	return metadata
+}
+
+// ComposerVersionMetadata builds the p2 entry of one version from its composer.json
+func ComposerVersionMetadata(plugin *models.Plugin, release *models.PluginRelease, version string, manifest []byte, distURL string) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if len(manifest) > 0 {
+		json.Unmarshal(manifest, &metadata)
+	}
+
+	reference := release.CommitSHA
+	if reference == "" {
+		reference = release.TagName
+	}
+
+	metadata["name"] = plugin.ComposerPackage
+	metadata["version"] = version
+	metadata["time"] = release.PublishedAt
+	metadata["dist"] = map[string]interface{}{
+		"type":      "zip",
+		"url":       distURL,
+		"reference": reference,
+	}
+	delete(metadata, "repositories")
+	return metadata
+}
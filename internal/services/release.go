@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrGitHubNotConfigured = errors.New("GitHub service not configured")
+
+// ReleaseService keeps plugin releases in sync with GitHub and serves their archives
+type ReleaseService struct {
+	db        *gorm.DB
+	githubSvc *GitHubService
+}
+
+func NewReleaseService(db *gorm.DB, githubSvc *GitHubService) *ReleaseService {
+	return &ReleaseService{db: db, githubSvc: githubSvc}
+}
+
+// SyncAll syncs the releases of every plugin with a repository and returns how many plugins synced and failed
+func (s *ReleaseService) SyncAll(ctx context.Context) (int, int, error) {
+	if s.githubSvc == nil {
+		return 0, 0, ErrGitHubNotConfigured
+	}
+
+	var plugins []models.Plugin
+	if err := s.db.Where("github_repo_name <> ''").Find(&plugins).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to load plugins: %w", err)
+	}
+
+	synced, failed := 0, 0
+	for i := range plugins {
+		if _, err := s.SyncPlugin(ctx, &plugins[i]); err != nil {
+			log.Printf("[Releases] Failed to sync %s: %v", plugins[i].Slug, err)
+			failed++
+			continue
+		}
+		synced++
+	}
+	return synced, failed, nil
+}
+
+// SyncPlugin imports the GitHub releases and tags of a plugin's repository and updates
+// Plugin.Version to the latest stable release. It returns the number of new releases.
+func (s *ReleaseService) SyncPlugin(ctx context.Context, plugin *models.Plugin) (int, error) {
+	if s.githubSvc == nil {
+		return 0, ErrGitHubNotConfigured
+	}
+
+	owner, repo, ok := splitRepoFullName(plugin.GitHubRepoName)
+	if !ok {
+		return 0, fmt.Errorf("invalid repository name: %q", plugin.GitHubRepoName)
+	}
+
+	var existing []models.PluginRelease
+	if err := s.db.Where("plugin_id = ?", plugin.ID).Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	known := make(map[string]*models.PluginRelease, len(existing))
+	for i := range existing {
+		known[existing[i].TagName] = &existing[i]
+	}
+
+	releases, err := s.githubSvc.ListReleases(ctx, owner, repo)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, r := range releases {
+		if r.GetDraft() || r.GetTagName() == "" {
+			continue
+		}
+
+		release, found := known[r.GetTagName()]
+		if !found {
+			release = &models.PluginRelease{PluginID: plugin.ID, TagName: r.GetTagName()}
+		}
+		applyGitHubRelease(release, r)
+
+		if err := s.db.Save(release).Error; err != nil {
+			return created, fmt.Errorf("failed to save release %s: %w", release.TagName, err)
+		}
+		if err := s.syncAssets(release, r); err != nil {
+			return created, fmt.Errorf("failed to save assets of %s: %w", release.TagName, err)
+		}
+		if !found {
+			known[release.TagName] = release
+			created++
+		}
+	}
+
+	// Tags without a GitHub release are versions too; date them by their commit
+	tags, err := s.githubSvc.ListTags(ctx, owner, repo)
+	if err != nil {
+		return created, err
+	}
+	for _, t := range tags {
+		if release, found := known[t.GetName()]; found {
+			if release.CommitSHA == "" && t.GetCommit().GetSHA() != "" {
+				s.db.Model(release).Update("commit_sha", t.GetCommit().GetSHA())
+			}
+			continue
+		}
+
+		publishedAt, err := s.githubSvc.GetCommitDate(ctx, owner, repo, t.GetCommit().GetSHA())
+		if err != nil {
+			log.Printf("[Releases] Skipping tag %s of %s: %v", t.GetName(), plugin.Slug, err)
+			continue
+		}
+
+		release := &models.PluginRelease{
+			PluginID:    plugin.ID,
+			TagName:     t.GetName(),
+			Name:        t.GetName(),
+			CommitSHA:   t.GetCommit().GetSHA(),
+			Source:      "tag",
+			PublishedAt: publishedAt,
+		}
+		if err := s.db.Create(release).Error; err != nil {
+			return created, fmt.Errorf("failed to save tag %s: %w", release.TagName, err)
+		}
+		known[release.TagName] = release
+		created++
+	}
+
+	if err := s.updatePluginVersion(plugin); err != nil {
+		return created, err
+	}
+
+	if created > 0 {
+		log.Printf("[Releases] Synced %d new releases for %s", created, plugin.Slug)
+	}
+	return created, nil
+}
+
+// SaveRelease stores a release reported by a GitHub release webhook and updates
+// Plugin.Version, without calling the GitHub API
+func (s *ReleaseService) SaveRelease(plugin *models.Plugin, r *github.RepositoryRelease) (*models.PluginRelease, error) {
+	var release models.PluginRelease
+	if err := s.db.Where("plugin_id = ? AND tag_name = ?", plugin.ID, r.GetTagName()).
+		FirstOrInit(&release, models.PluginRelease{PluginID: plugin.ID, TagName: r.GetTagName()}).Error; err != nil {
+		return nil, err
+	}
+	applyGitHubRelease(&release, r)
+
+	if err := s.db.Save(&release).Error; err != nil {
+		return nil, fmt.Errorf("failed to save release %s: %w", release.TagName, err)
+	}
+	if err := s.syncAssets(&release, r); err != nil {
+		return nil, fmt.Errorf("failed to save assets of %s: %w", release.TagName, err)
+	}
+	if err := s.updatePluginVersion(plugin); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// applyGitHubRelease copies the fields of a GitHub release onto a stored release
+func applyGitHubRelease(release *models.PluginRelease, r *github.RepositoryRelease) {
+	releaseID := r.GetID()
+	release.Name = r.GetName()
+	release.Body = r.GetBody()
+	release.Source = "release"
+	release.GitHubReleaseID = &releaseID
+	release.Prerelease = r.GetPrerelease()
+	release.PublishedAt = r.GetPublishedAt().Time
+	if release.PublishedAt.IsZero() {
+		release.PublishedAt = r.GetCreatedAt().Time
+	}
+}
+
+// syncAssets stores the files attached to a GitHub release
+func (s *ReleaseService) syncAssets(release *models.PluginRelease, r *github.RepositoryRelease) error {
+	for _, a := range r.Assets {
+		asset := models.PluginReleaseAsset{GitHubAssetID: a.GetID()}
+		if err := s.db.Where("github_asset_id = ?", a.GetID()).FirstOrInit(&asset).Error; err != nil {
+			return err
+		}
+		asset.ReleaseID = release.ID
+		asset.Name = a.GetName()
+		asset.ContentType = a.GetContentType()
+		asset.Size = int64(a.GetSize())
+		if err := s.db.Save(&asset).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updatePluginVersion sets Plugin.Version to the tag of the latest stable release
+func (s *ReleaseService) updatePluginVersion(plugin *models.Plugin) error {
+	var latest models.PluginRelease
+	err := s.db.Where("plugin_id = ? AND prerelease = ?", plugin.ID, false).
+		Order("published_at DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if plugin.Version == latest.TagName {
+		return nil
+	}
+	plugin.Version = latest.TagName
+	return s.db.Model(plugin).Updates(map[string]interface{}{
+		"version":    latest.TagName,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// OpenAsset streams a file attached to a release
+func (s *ReleaseService) OpenAsset(ctx context.Context, plugin *models.Plugin, asset *models.PluginReleaseAsset) (io.ReadCloser, error) {
+	if s.githubSvc == nil {
+		return nil, ErrGitHubNotConfigured
+	}
+
+	owner, repo, ok := splitRepoFullName(plugin.GitHubRepoName)
+	if !ok {
+		return nil, fmt.Errorf("invalid repository name: %q", plugin.GitHubRepoName)
+	}
+
+	return s.githubSvc.OpenReleaseAsset(ctx, owner, repo, asset.GitHubAssetID)
+}
+
+// GetFile returns the content of a file in the plugin repository at a release
+func (s *ReleaseService) GetFile(ctx context.Context, plugin *models.Plugin, release *models.PluginRelease, path string) ([]byte, error) {
+	if s.githubSvc == nil {
+		return nil, ErrGitHubNotConfigured
+	}
+
+	owner, repo, ok := splitRepoFullName(plugin.GitHubRepoName)
+	if !ok {
+		return nil, fmt.Errorf("invalid repository name: %q", plugin.GitHubRepoName)
+	}
+
+	return s.githubSvc.GetFileContent(ctx, owner, repo, path, release.TagName)
+}
+
+// Manifest returns a package manifest of a release, fetching it from GitHub once and caching it.
+// It reports false when the release does not contain the file.
+func (s *ReleaseService) Manifest(ctx context.Context, plugin *models.Plugin, release *models.PluginRelease, path string) ([]byte, bool, error) {
+	var cached models.ReleaseManifest
+	err := s.db.Where("release_id = ? AND path = ?", release.ID, path).First(&cached).Error
+	if err == nil {
+		return []byte(cached.Content), cached.Found, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	content, err := s.GetFile(ctx, plugin, release, path)
+	found := true
+	if errors.Is(err, ErrFileNotFound) {
+		found = false
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	cached = models.ReleaseManifest{
+		ReleaseID: release.ID,
+		Path:      path,
+		Content:   string(content),
+		Found:     found,
+	}
+	if err := s.db.Create(&cached).Error; err != nil {
+		log.Printf("[Releases] Failed to cache %s of %s@%s: %v", path, plugin.Slug, release.TagName, err)
+	}
+	return content, found, nil
+}
+
+// OpenArchive streams the source archive of a release ("zip" or "tar")
+func (s *ReleaseService) OpenArchive(ctx context.Context, plugin *models.Plugin, release *models.PluginRelease, format string) (io.ReadCloser, int64, error) {
+	if s.githubSvc == nil {
+		return nil, 0, ErrGitHubNotConfigured
+	}
+
+	owner, repo, ok := splitRepoFullName(plugin.GitHubRepoName)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid repository name: %q", plugin.GitHubRepoName)
+	}
+
+	return s.githubSvc.OpenArchive(ctx, owner, repo, format, release.TagName)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/jobs"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// JobAnnounceRelease emails a plugin's licensees about a new release
+const JobAnnounceRelease = "release.announce"
+
+// ReleaseAnnouncementPayload is the payload of release announcement jobs
+type ReleaseAnnouncementPayload struct {
+	ReleaseID uuid.UUID `json:"release_id"`
+}
+
+// EnqueueReleaseAnnouncement queues the announcement emails of a release
+func EnqueueReleaseAnnouncement(tx *gorm.DB, releaseID uuid.UUID) error {
+	_, err := jobs.Enqueue(tx, JobAnnounceRelease, ReleaseAnnouncementPayload{ReleaseID: releaseID},
+		jobs.UniqueKey("announce_release:"+releaseID.String()))
+	return err
+}
+
+// ReleaseAnnouncementService tells licensees about new plugin releases
+type ReleaseAnnouncementService struct {
+	db       *gorm.DB
+	emailSvc *EmailService
+}
+
+func NewReleaseAnnouncementService(db *gorm.DB, cfg *config.Config) *ReleaseAnnouncementService {
+	return &ReleaseAnnouncementService{
+		db:       db,
+		emailSvc: NewEmailService(cfg, db),
+	}
+}
+
+// Register installs the release announcement job handler on the queue
+func (s *ReleaseAnnouncementService) Register(queue *jobs.Queue) {
+	queue.Register(JobAnnounceRelease, jobs.Typed(s.announce))
+}
+
+// announce queues the release email for every license holder of the plugin: the
+// changelog for licenses whose maintenance covers the release, and a renewal offer
+// for those whose maintenance ended before it. Emails are queued together with
+// announced_at, so a release is announced once.
+func (s *ReleaseAnnouncementService) announce(ctx context.Context, payload ReleaseAnnouncementPayload) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var release models.PluginRelease
+		if err := tx.Preload("Plugin").First(&release, "id = ?", payload.ReleaseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return jobs.Permanent(fmt.Errorf("release not found: %w", err))
+			}
+			return err
+		}
+		if release.AnnouncedAt != nil {
+			return nil
+		}
+
+		var licenses []models.License
+		if err := tx.Preload("User").
+			Where("plugin_id = ? AND status <> ?", release.PluginID, "revoked").
+			Find(&licenses).Error; err != nil {
+			return fmt.Errorf("failed to load licenses: %w", err)
+		}
+
+		covered, renewals := 0, 0
+		for i := range licenses {
+			license := &licenses[i]
+			if !license.User.IsActive {
+				continue
+			}
+			if license.CoversRelease(&release) {
+				if err := s.emailSvc.QueueReleaseAvailableEmail(tx, &license.User, &release.Plugin, &release, license); err != nil {
+					return err
+				}
+				if err := NotifyLicense(tx, models.NotificationTypeReleaseAvailable, &license.User, &release.Plugin, license, releaseNotificationData(&release)); err != nil {
+					return err
+				}
+				covered++
+			} else {
+				if err := s.emailSvc.QueueReleaseRenewalEmail(tx, &license.User, &release.Plugin, &release, license); err != nil {
+					return err
+				}
+				if err := NotifyLicense(tx, models.NotificationTypeReleaseRenewal, &license.User, &release.Plugin, license, releaseNotificationData(&release)); err != nil {
+					return err
+				}
+				renewals++
+			}
+		}
+
+		if err := tx.Model(&release).Update("announced_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		log.Printf("[Releases] Announced %s %s to %d licensees and offered renewal to %d", release.Plugin.Slug, release.TagName, covered, renewals)
+		return nil
+	})
+}
+
+func releaseNotificationData(release *models.PluginRelease) map[string]interface{} {
+	return map[string]interface{}{
+		"version":    release.TagName,
+		"release_id": release.ID,
+	}
+}
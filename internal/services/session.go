@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"github.com/nodeloc/git-store/internal/utils"
+	"gorm.io/gorm"
+)
+
+// refreshReuseGrace tolerates a browser sending a just-rotated refresh token from a
+// concurrent request, without treating it as a stolen token
+const refreshReuseGrace = 10 * time.Second
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshConflict means a concurrent request already rotated the refresh token;
+	// the caller's cookie is replaced by that request's response
+	ErrRefreshConflict = errors.New("refresh token was rotated by a concurrent request")
+)
+
+// SessionService manages login sessions and their rotating refresh tokens
+type SessionService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+func NewSessionService(db *gorm.DB, cfg *config.Config) *SessionService {
+	return &SessionService{db: db, config: cfg}
+}
+
+// RefreshTTL is how long a session lives without being refreshed
+func (s *SessionService) RefreshTTL() time.Duration {
+	return time.Duration(s.config.RefreshTokenTTLDays) * 24 * time.Hour
+}
+
+// Create starts a session for the user and returns it with its raw refresh token. Users with
+// two-factor authentication get a session that waits for their code.
+func (s *SessionService) Create(user *models.User, userAgent, ipAddress string) (*models.Session, string, error) {
+	var enrolled int64
+	s.db.Model(&models.UserTOTP{}).Where("user_id = ? AND enabled_at IS NOT NULL", user.ID).Count(&enrolled)
+
+	raw, err := generateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: models.HashToken(raw),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.RefreshTTL()),
+		MFAPending:       enrolled > 0,
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, "", err
+	}
+	session.User = *user
+	return session, raw, nil
+}
+
+// Refresh rotates a refresh token, returning the session and its new raw refresh token
+func (s *SessionService) Refresh(raw, userAgent, ipAddress string) (*models.Session, string, error) {
+	hash := models.HashToken(raw)
+	now := time.Now()
+
+	var session models.Session
+	if err := s.db.Preload("User").Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", err
+		}
+		return nil, "", s.detectReuse(hash, now)
+	}
+	if !session.Active(now) || !session.User.IsActive {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	next, err := generateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  models.HashToken(next),
+			"previous_token_hash": hash,
+			"user_agent":          userAgent,
+			"ip_address":          ipAddress,
+			"last_used_at":        now,
+			"expires_at":          now.Add(s.RefreshTTL()),
+		})
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Lost a race with a concurrent refresh of the same token
+		return nil, "", ErrRefreshConflict
+	}
+
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(s.RefreshTTL())
+	return &session, next, nil
+}
+
+// detectReuse revokes the session a rotated-away refresh token belonged to. Reuse within
+// refreshReuseGrace is a concurrent request and reported as ErrRefreshConflict.
+func (s *SessionService) detectReuse(hash string, now time.Time) error {
+	var session models.Session
+	err := s.db.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	if now.Sub(session.LastUsedAt) < refreshReuseGrace {
+		return ErrRefreshConflict
+	}
+
+	log.Printf("[Session] Refresh token reuse detected, revoking session %s of user %s", session.ID, session.UserID)
+	if err := s.db.Model(&session).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
+
+// AccessToken issues a short-lived JWT for the session
+func (s *SessionService) AccessToken(session *models.Session) (string, error) {
+	return utils.GenerateJWT(session, s.config)
+}
+
+// RevokeByRefreshToken ends the session a raw refresh token belongs to
+func (s *SessionService) RevokeByRefreshToken(raw string) error {
+	return s.db.Model(&models.Session{}).
+		Where("refresh_token_hash = ? AND revoked_at IS NULL", models.HashToken(raw)).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Revoke ends one of the user's sessions, reporting whether it existed
+func (s *SessionService) Revoke(userID, sessionID uuid.UUID) (bool, error) {
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAll ends every session of the user except the given one (uuid.Nil ends them all)
+func (s *SessionService) RevokeAll(userID, except uuid.UUID) (int64, error) {
+	result := s.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, except).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// ListActive returns the user's sessions that can still be refreshed, most recently used first
+func (s *SessionService) ListActive(userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// PurgeEnded deletes sessions that expired or were revoked before the cutoff
+func (s *SessionService) PurgeEnded(before time.Time) (int64, error) {
+	result := s.db.Where("expires_at < ? OR revoked_at < ?", before, before).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/config"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// TOTP parameters understood by every authenticator app (RFC 6238 defaults)
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // accepted steps before and after the current one
+
+	recoveryCodeCount = 10
+
+	// maxMFAFailures wrong codes end a session waiting for its second factor
+	maxMFAFailures = 5
+)
+
+var (
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	ErrTooManyTwoFactorTries = errors.New("too many invalid two-factor codes")
+)
+
+// TwoFactorService manages TOTP enrollment, recovery codes and second-factor checks of sessions
+type TwoFactorService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+func NewTwoFactorService(db *gorm.DB, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{db: db, config: cfg}
+}
+
+// Enabled reports whether the user completed TOTP enrollment
+func (s *TwoFactorService) Enabled(userID uuid.UUID) bool {
+	var count int64
+	s.db.Model(&models.UserTOTP{}).Where("user_id = ? AND enabled_at IS NOT NULL", userID).Count(&count)
+	return count > 0
+}
+
+// Status returns when 2FA was enabled (nil if it is not) and the number of unused recovery codes
+func (s *TwoFactorService) Status(userID uuid.UUID) (*time.Time, int64) {
+	var totp models.UserTOTP
+	if err := s.db.First(&totp, "user_id = ?", userID).Error; err != nil || totp.EnabledAt == nil {
+		return nil, 0
+	}
+	var remaining int64
+	s.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&remaining)
+	return totp.EnabledAt, remaining
+}
+
+// BeginEnrollment creates a new pending secret, replacing an unfinished enrollment, and
+// returns it with the otpauth:// URL authenticator apps import
+func (s *TwoFactorService) BeginEnrollment(user *models.User) (string, string, error) {
+	if s.Enabled(user.ID) {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	totp := models.UserTOTP{UserID: user.ID, SecretEncrypted: encrypted}
+	if err := s.db.Save(&totp).Error; err != nil {
+		return "", "", err
+	}
+
+	label := url.PathEscape(s.config.TOTPIssuer + ":" + user.Email)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", s.config.TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return secret, "otpauth://totp/" + label + "?" + params.Encode(), nil
+}
+
+// ConfirmEnrollment enables 2FA once the first code checks out, returning fresh recovery codes
+func (s *TwoFactorService) ConfirmEnrollment(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var totp models.UserTOTP
+		if err := tx.First(&totp, "user_id = ?", userID).Error; err != nil {
+			return ErrTwoFactorNotEnabled
+		}
+		if totp.EnabledAt != nil {
+			return ErrTwoFactorEnabled
+		}
+		if err := s.checkTOTP(tx, &totp, code); err != nil {
+			return err
+		}
+		if err := tx.Model(&totp).Update("enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Verify checks a TOTP code, or consumes a recovery code, of a user with 2FA enabled
+func (s *TwoFactorService) Verify(userID uuid.UUID, code string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var totp models.UserTOTP
+		if err := tx.First(&totp, "user_id = ?", userID).Error; err != nil || totp.EnabledAt == nil {
+			return ErrTwoFactorNotEnabled
+		}
+
+		code = strings.TrimSpace(code)
+		if len(code) == totpDigits {
+			return s.checkTOTP(tx, &totp, code)
+		}
+
+		// Recovery codes are single use
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, models.HashToken(normalizeRecoveryCode(code))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	})
+}
+
+// VerifySession completes the second factor of a session, or refreshes its step-up time.
+// Repeated wrong codes revoke the session.
+func (s *TwoFactorService) VerifySession(session *models.Session, code string) error {
+	if err := s.Verify(session.UserID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			session.MFAFailures++
+			updates := map[string]interface{}{"mfa_failures": session.MFAFailures}
+			if session.MFAFailures >= maxMFAFailures {
+				updates["revoked_at"] = time.Now()
+				err = ErrTooManyTwoFactorTries
+			}
+			s.db.Model(session).Updates(updates)
+		}
+		return err
+	}
+
+	return s.MarkSessionVerified(session)
+}
+
+// MarkSessionVerified records a passed second-factor check on the session
+func (s *TwoFactorService) MarkSessionVerified(session *models.Session) error {
+	now := time.Now()
+	session.MFAPending = false
+	session.MFAVerifiedAt = &now
+	session.MFAFailures = 0
+	return s.db.Model(session).Updates(map[string]interface{}{
+		"mfa_pending":     false,
+		"mfa_verified_at": now,
+		"mfa_failures":    0,
+	}).Error
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	if !s.Enabled(userID) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable removes the enrollment and recovery codes
+func (s *TwoFactorService) Disable(userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error; err != nil {
+			return err
+		}
+		// Existing sessions no longer count as verified
+		return tx.Model(&models.Session{}).Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"mfa_pending": false, "mfa_verified_at": nil}).Error
+	})
+}
+
+func (s *TwoFactorService) replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		code = code[:5] + "-" + code[5:]
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: models.HashToken(normalizeRecoveryCode(code))}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// checkTOTP accepts a code from the current or an adjacent time step that was not used before
+func (s *TwoFactorService) checkTOTP(tx *gorm.DB, totp *models.UserTOTP, code string) error {
+	secret, err := s.decrypt(totp.SecretEncrypted)
+	if err != nil {
+		return err
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= totp.LastUsedStep || !hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			continue
+		}
+		// Claim the step atomically so a code cannot be used twice
+		result := tx.Model(&models.UserTOTP{}).
+			Where("user_id = ? AND last_used_step < ?", totp.UserID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		totp.LastUsedStep = step
+		return nil
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// totpCode computes the HOTP value (RFC 4226) of a time step
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// TOTP secrets are stored encrypted with a key derived from JWT_SECRET
+func (s *TwoFactorService) gcm() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("totp:" + s.config.JWTSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *TwoFactorService) encrypt(plaintext string) (string, error) {
+	gcm, err := s.gcm()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+func (s *TwoFactorService) decrypt(encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid TOTP secret")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return string(plaintext), nil
+}
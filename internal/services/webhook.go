@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nodeloc/git-store/internal/jobs"
+	"github.com/nodeloc/git-store/internal/models"
+	"gorm.io/gorm"
+)
+
+// JobDeliverWebhook posts one webhook delivery to its endpoint
+const JobDeliverWebhook = "webhook.deliver"
+
+// Headers of webhook requests. The signature is "t=<unix time>,v1=<hex HMAC-SHA256
+// of "<unix time>.<body>" keyed with the endpoint secret>".
+const (
+	WebhookHeaderEvent     = "X-GitStore-Event"
+	WebhookHeaderDelivery  = "X-GitStore-Delivery"
+	WebhookHeaderSignature = "X-GitStore-Signature"
+)
+
+// webhookResponseLimit is how much of an endpoint's response the delivery log keeps
+const webhookResponseLimit = 1024
+
+// WebhookDeliveryPayload is the payload of webhook delivery jobs
+type WebhookDeliveryPayload struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// WebhookEvent is the JSON body posted to endpoints
+type WebhookEvent struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// EmitWebhookEvent queues the delivery of an event to every active endpoint
+// subscribed to it. Pass the transaction of the change the event reports, so that
+// nothing is sent for changes that are rolled back.
+func EmitWebhookEvent(tx *gorm.DB, eventType string, data interface{}) error {
+	var endpoints []models.WebhookEndpoint
+	if err := tx.Where("is_active = ?", true).Find(&endpoints).Error; err != nil {
+		return fmt.Errorf("failed to load webhook endpoints: %w", err)
+	}
+
+	event := WebhookEvent{ID: uuid.New(), Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s webhook: %w", eventType, err)
+	}
+
+	for i := range endpoints {
+		if !endpoints[i].Subscribes(eventType) {
+			continue
+		}
+		if _, err := enqueueWebhookDelivery(tx, endpoints[i].ID, event.ID, eventType, string(body), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendTestWebhook queues a ping event to one endpoint
+func SendTestWebhook(db *gorm.DB, endpoint *models.WebhookEndpoint) (*models.WebhookDelivery, error) {
+	event := WebhookEvent{
+		ID:        uuid.New(),
+		Type:      models.WebhookEventPing,
+		CreatedAt: time.Now().UTC(),
+		Data:      map[string]interface{}{"endpoint_id": endpoint.ID, "url": endpoint.URL},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivery *models.WebhookDelivery
+	err = db.Transaction(func(tx *gorm.DB) error {
+		delivery, err = enqueueWebhookDelivery(tx, endpoint.ID, event.ID, event.Type, string(body), nil)
+		return err
+	})
+	return delivery, err
+}
+
+// Redeliver sends a logged delivery again as a new delivery with the same event ID
+// and body, whatever the outcome of the original was
+func Redeliver(db *gorm.DB, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := db.First(&original, "id = ?", deliveryID).Error; err != nil {
+		return nil, err
+	}
+
+	var delivery *models.WebhookDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		delivery, err = enqueueWebhookDelivery(tx, original.EndpointID, original.EventID, original.Event, original.Payload, &original.ID)
+		return err
+	})
+	return delivery, err
+}
+
+func enqueueWebhookDelivery(tx *gorm.DB, endpointID, eventID uuid.UUID, eventType, body string, redeliveryOf *uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		EndpointID:   endpointID,
+		EventID:      eventID,
+		Event:        eventType,
+		Payload:      body,
+		Status:       models.WebhookDeliveryPending,
+		RedeliveryOf: redeliveryOf,
+	}
+	if err := tx.Create(&delivery).Error; err != nil {
+		return nil, fmt.Errorf("failed to log %s webhook delivery: %w", eventType, err)
+	}
+	if _, err := jobs.Enqueue(tx, JobDeliverWebhook, WebhookDeliveryPayload{DeliveryID: delivery.ID}); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GenerateWebhookSecret returns a new endpoint signing secret
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// WebhookSignature returns the signature header value of a body sent at timestamp
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// OrderWebhookData is the data of order and refund events
+func OrderWebhookData(order *models.Order, user *models.User, plugin *models.Plugin) map[string]interface{} {
+	return map[string]interface{}{
+		"order_id":       order.ID,
+		"order_number":   order.OrderNumber,
+		"amount":         order.Amount,
+		"currency":       order.Currency,
+		"payment_method": order.PaymentMethod,
+		"payment_status": order.PaymentStatus,
+		"paid_at":        order.PaidAt,
+		"refunded_at":    order.RefundedAt,
+		"user":           webhookUserData(user),
+		"plugin":         webhookPluginData(plugin),
+	}
+}
+
+// LicenseWebhookData is the data of license events
+func LicenseWebhookData(license *models.License, user *models.User, plugin *models.Plugin) map[string]interface{} {
+	return map[string]interface{}{
+		"license_id":        license.ID,
+		"order_id":          license.OrderID,
+		"status":            license.Status,
+		"maintenance_until": license.MaintenanceUntil.Format("2006-01-02"),
+		"revoked_reason":    license.RevokedReason,
+		"revoked_at":        license.RevokedAt,
+		"user":              webhookUserData(user),
+		"plugin":            webhookPluginData(plugin),
+	}
+}
+
+func webhookUserData(user *models.User) map[string]interface{} {
+	return map[string]interface{}{"id": user.ID, "email": user.Email, "name": user.Name}
+}
+
+func webhookPluginData(plugin *models.Plugin) map[string]interface{} {
+	return map[string]interface{}{"id": plugin.ID, "slug": plugin.Slug, "name": plugin.Name}
+}
+
+// WebhookService delivers outgoing webhooks
+type WebhookService struct {
+	db     *gorm.DB
+	client *http.Client
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{
+		db: db,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+			// A redirect would resend the signed body somewhere the owner did not configure
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Register installs the webhook delivery job handler on the queue
+func (s *WebhookService) Register(queue *jobs.Queue) {
+	queue.Register(JobDeliverWebhook, jobs.Typed(s.deliver),
+		jobs.WithRetryPolicy(jobs.RetryPolicy{
+			MaxAttempts: 8,
+			BaseDelay:   time.Minute,
+			MaxDelay:    4 * time.Hour,
+		}),
+		jobs.OnDeadLetter(s.markDeliveryFailed),
+	)
+}
+
+// deliver posts a delivery to its endpoint. Anything but a 2xx response is retried.
+func (s *WebhookService) deliver(ctx context.Context, payload WebhookDeliveryPayload) error {
+	var delivery models.WebhookDelivery
+	if err := s.db.Preload("Endpoint").First(&delivery, "id = ?", payload.DeliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(fmt.Errorf("webhook delivery not found: %w", err))
+		}
+		return err
+	}
+	if delivery.Status != models.WebhookDeliveryPending {
+		return nil
+	}
+	if !delivery.Endpoint.IsActive && delivery.Event != models.WebhookEventPing {
+		return s.db.Model(&delivery).Updates(map[string]interface{}{
+			"status":        models.WebhookDeliveryFailed,
+			"error_message": "endpoint is disabled",
+		}).Error
+	}
+
+	start := time.Now()
+	status, response, sendErr := s.post(ctx, &delivery)
+
+	updates := map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_attempt_at": start,
+		"duration_ms":     time.Since(start).Milliseconds(),
+		"response_status": status,
+		"response_body":   response,
+	}
+	if sendErr != nil {
+		updates["error_message"] = sendErr.Error()
+	} else {
+		updates["status"] = models.WebhookDeliverySucceeded
+		updates["delivered_at"] = time.Now()
+		updates["error_message"] = ""
+	}
+	if err := s.db.Model(&delivery).Updates(updates).Error; err != nil {
+		log.Printf("[Webhook] Failed to record delivery %s: %v", delivery.ID, err)
+	}
+
+	if sendErr != nil {
+		log.Printf("[Webhook] Delivery %s (%s) to %s failed: %v", delivery.ID, delivery.Event, delivery.Endpoint.URL, sendErr)
+	}
+	return sendErr
+}
+
+// post sends the signed request and returns the response status and the start of its body
+func (s *WebhookService) post(ctx context.Context, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", jobs.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GitStore-Webhooks/1.0")
+	req.Header.Set(WebhookHeaderEvent, delivery.Event)
+	req.Header.Set(WebhookHeaderDelivery, delivery.ID.String())
+	req.Header.Set(WebhookHeaderSignature, WebhookSignature(delivery.Endpoint.Secret, time.Now().Unix(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	// Stored in a text column, which takes neither NUL bytes nor invalid UTF-8
+	response := strings.ReplaceAll(strings.ToValidUTF8(string(data), "\uFFFD"), "\x00", "")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, response, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, response, nil
+}
+
+// markDeliveryFailed marks a delivery failed once its job has no attempts left
+func (s *WebhookService) markDeliveryFailed(ctx context.Context, job *models.Job) {
+	var payload WebhookDeliveryPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return
+	}
+	if err := s.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ?", payload.DeliveryID, models.WebhookDeliveryPending).
+		Update("status", models.WebhookDeliveryFailed).Error; err != nil {
+		log.Printf("[Webhook] Failed to mark delivery %s as failed: %v", payload.DeliveryID, err)
+	}
+}
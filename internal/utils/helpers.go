@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/nodeloc/git-store/internal/models"
 )
 
 // GenerateOrderNumber generates a unique order number
@@ -80,3 +82,12 @@ func GenerateLicenseKey() string {
 	}
 	return formatted
 }
+
+// GenerateAccessToken returns a new random personal access token
+func GenerateAccessToken() (string, error) {
+	randomBytes := make([]byte, 24)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return models.AccessTokenPrefix + hex.EncodeToString(randomBytes), nil
+}
@@ -4,23 +4,30 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/middleware"
+	"github.com/nodeloc/git-store/internal/models"
 )
 
-func GenerateJWT(userID uuid.UUID, email, role string, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(cfg.JWTExpiryHours) * time.Hour)
+// GenerateJWT issues a short-lived access token bound to a login session. The session's
+// User must be loaded. Its second-factor state is copied into the claims.
+func GenerateJWT(session *models.Session, cfg *config.Config) (string, error) {
+	expirationTime := time.Now().Add(time.Duration(cfg.AccessTokenTTLMinutes) * time.Minute)
 
 	claims := &middleware.Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:     session.UserID,
+		Email:      session.User.Email,
+		Role:       session.User.Role,
+		SessionID:  session.ID,
+		MFAPending: session.MFAPending,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if session.MFAVerifiedAt != nil {
+		claims.MFAAt = session.MFAVerifiedAt.Unix()
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
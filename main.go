@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/nodeloc/git-store/internal/config"
 	"github.com/nodeloc/git-store/internal/database"
+	"github.com/nodeloc/git-store/internal/jobs"
 	"github.com/nodeloc/git-store/internal/router"
 	"github.com/nodeloc/git-store/internal/scheduler"
 	"github.com/nodeloc/git-store/internal/services"
@@ -34,6 +36,11 @@ func main() {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Store the built-in email templates admins have not edited yet
+	if err := services.SeedEmailTemplates(db); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	// Setup Stripe webhook (if configured)
 	if cfg.StripeSecretKey != "" {
 		stripeService := services.NewStripeService(cfg)
@@ -50,15 +57,29 @@ func main() {
 	// Initialize Gin router
 	r := gin.Default()
 
-	// Setup routes
-	router.SetupRoutes(r, db, cfg)
-
 	// Initialize cron scheduler
 	c := cron.New()
-	scheduler.SetupScheduler(c, db, cfg)
+	sched := scheduler.SetupScheduler(c, db, cfg)
+
+	// OAuth login state shared by all replicas
+	stateStore, err := services.NewOAuthStateStore(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up OAuth state store: %v", err)
+	}
+
+	// Setup routes
+	router.SetupRoutes(r, db, cfg, sched, stateStore)
+
 	c.Start()
 	defer c.Stop()
 
+	// Start background job workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := jobs.NewQueue(db)
+	services.RegisterJobHandlers(queue, db, cfg)
+	queue.Start(ctx, cfg.JobWorkers)
+
 	// Start server
 	port := os.Getenv("APP_PORT")
 	if port == "" {
@@ -0,0 +1,20 @@
+-- Scheduled job run history
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_name VARCHAR(100) NOT NULL,
+    trigger VARCHAR(20) NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+    triggered_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    scheduled_for TIMESTAMP WITH TIME ZONE,
+    instance VARCHAR(255),
+    status VARCHAR(20) DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+    processed INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- One scheduled run per job and cron tick, even with several replicas
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduler_runs_slot ON scheduler_runs(job_name, scheduled_for);
+CREATE INDEX IF NOT EXISTS idx_scheduler_runs_job_name ON scheduler_runs(job_name);
+CREATE INDEX IF NOT EXISTS idx_scheduler_runs_status ON scheduler_runs(status);
@@ -0,0 +1,22 @@
+-- Per-plugin grace period and repository permission
+ALTER TABLE plugins ADD COLUMN IF NOT EXISTS grace_period_days INTEGER NOT NULL DEFAULT 0 CHECK (grace_period_days >= 0);
+ALTER TABLE plugins ADD COLUMN IF NOT EXISTS collaborator_permission VARCHAR(20) NOT NULL DEFAULT 'pull'
+    CHECK (collaborator_permission IN ('pull', 'triage', 'push', 'maintain', 'admin'));
+
+-- Licenses stay in 'grace' with unchanged access between maintenance expiry and the downgrade
+ALTER TABLE licenses DROP CONSTRAINT IF EXISTS licenses_status_check;
+ALTER TABLE licenses ADD CONSTRAINT licenses_status_check
+    CHECK (status IN ('active', 'grace', 'expired', 'revoked'));
+
+ALTER TABLE license_history DROP CONSTRAINT IF EXISTS license_history_action_check;
+ALTER TABLE license_history ADD CONSTRAINT license_history_action_check
+    CHECK (action IN ('granted', 'grace_started', 'expired', 'renewed', 'revoked',
+                      'github_access_granted', 'github_access_downgraded', 'github_access_revoked'));
+
+-- Days before the end of the grace period on which warning emails are sent
+INSERT INTO system_settings (key, value, description) VALUES
+    ('grace_warning_days', '7,1', 'Comma-separated days before the grace period ends to send warning emails')
+ON CONFLICT (key) DO NOTHING;
+
+UPDATE system_settings SET description = 'Cron schedule: Expire licenses, end grace periods and send expiry warnings'
+WHERE key = 'schedule_maintenance_check';
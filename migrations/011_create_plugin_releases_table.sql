@@ -0,0 +1,22 @@
+-- Plugin releases synced from GitHub releases and tags
+CREATE TABLE IF NOT EXISTS plugin_releases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    plugin_id UUID NOT NULL REFERENCES plugins(id) ON DELETE CASCADE,
+    tag_name VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+    body TEXT,
+    commit_sha VARCHAR(40),
+    source VARCHAR(20) NOT NULL DEFAULT 'release' CHECK (source IN ('release', 'tag')),
+    github_release_id BIGINT,
+    prerelease BOOLEAN DEFAULT FALSE,
+    published_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plugin_releases_tag ON plugin_releases(plugin_id, tag_name);
+CREATE INDEX IF NOT EXISTS idx_plugin_releases_published_at ON plugin_releases(published_at);
+
+INSERT INTO system_settings (key, value, description) VALUES
+    ('schedule_sync_releases', '0 * * * *', 'Cron schedule: Sync plugin releases and versions from GitHub')
+ON CONFLICT (key) DO NOTHING;
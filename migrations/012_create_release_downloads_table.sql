@@ -0,0 +1,34 @@
+-- Release download accounting
+ALTER TABLE plugin_releases ADD COLUMN IF NOT EXISTS download_count INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS plugin_release_assets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    release_id UUID NOT NULL REFERENCES plugin_releases(id) ON DELETE CASCADE,
+    github_asset_id BIGINT NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255),
+    size BIGINT,
+    download_count INTEGER DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_plugin_release_assets_release_id ON plugin_release_assets(release_id);
+
+CREATE TABLE IF NOT EXISTS release_downloads (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    license_id UUID NOT NULL REFERENCES licenses(id) ON DELETE CASCADE,
+    release_id UUID NOT NULL REFERENCES plugin_releases(id) ON DELETE CASCADE,
+    asset_id UUID REFERENCES plugin_release_assets(id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(20),
+    via VARCHAR(20),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_release_downloads_license_id ON release_downloads(license_id);
+CREATE INDEX IF NOT EXISTS idx_release_downloads_release_id ON release_downloads(release_id);
+CREATE INDEX IF NOT EXISTS idx_release_downloads_user_id ON release_downloads(user_id);
+CREATE INDEX IF NOT EXISTS idx_release_downloads_created_at ON release_downloads(created_at);
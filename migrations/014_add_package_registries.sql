@@ -0,0 +1,15 @@
+-- Package names served by the npm registry and the Composer repository
+ALTER TABLE plugins ADD COLUMN IF NOT EXISTS npm_package VARCHAR(214);
+ALTER TABLE plugins ADD COLUMN IF NOT EXISTS composer_package VARCHAR(255);
+
+-- Cached package manifests (package.json, composer.json) of releases
+CREATE TABLE IF NOT EXISTS release_manifests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    release_id UUID NOT NULL REFERENCES plugin_releases(id) ON DELETE CASCADE,
+    path VARCHAR(255) NOT NULL,
+    content TEXT,
+    found BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_release_manifests_path ON release_manifests(release_id, path);
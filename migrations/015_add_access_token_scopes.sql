@@ -0,0 +1,7 @@
+-- Scoped, expiring personal access tokens usable against the REST API
+ALTER TABLE access_tokens ADD COLUMN IF NOT EXISTS scopes VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE access_tokens ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE access_tokens ADD COLUMN IF NOT EXISTS last_used_ip VARCHAR(45);
+
+-- Tokens created before scopes existed were only accepted by the package registries
+UPDATE access_tokens SET scopes = 'packages' WHERE scopes = '';
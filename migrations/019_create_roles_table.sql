@@ -0,0 +1,20 @@
+-- Roles grant admin permissions; users.role names one of them
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(20) NOT NULL UNIQUE,
+    description TEXT,
+    permissions TEXT NOT NULL DEFAULT '', -- space separated, '*' for all
+    is_system BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO roles (name, description, permissions, is_system) VALUES
+    ('admin', 'Full access', '*', true),
+    ('user', 'Customer without admin access', '', true),
+    ('support', 'View orders and users, manage licenses', 'orders:read licenses:read licenses:write users:read', false)
+ON CONFLICT (name) DO NOTHING;
+
+-- Replace the fixed user/admin check with a reference to the roles table
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name);
@@ -0,0 +1,14 @@
+-- Email outbox: notifications are written as pending with the change they report
+-- and delivered by the email.deliver job with retries
+ALTER TABLE email_notifications DROP CONSTRAINT IF EXISTS email_notifications_notification_type_check;
+
+ALTER TABLE email_notifications ADD COLUMN IF NOT EXISTS to_email VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE email_notifications ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE email_notifications ADD COLUMN IF NOT EXISTS last_attempt_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE email_notifications ALTER COLUMN metadata SET DEFAULT '{}';
+
+UPDATE email_notifications n SET to_email = u.email
+FROM users u
+WHERE n.user_id = u.id AND n.to_email = '';
+
+CREATE INDEX IF NOT EXISTS idx_email_notifications_created_at ON email_notifications(created_at DESC);
@@ -0,0 +1,20 @@
+-- Admin-editable email templates in English and Chinese. Built-in templates are
+-- inserted at startup; rows edited here are kept.
+CREATE TABLE IF NOT EXISTS email_templates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(50) NOT NULL,
+    language VARCHAR(10) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    html_body TEXT NOT NULL,
+    text_body TEXT NOT NULL DEFAULT '', -- generated from html_body when empty
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_templates_name_language ON email_templates(name, language);
+
+-- Language emails are sent in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language VARCHAR(10) NOT NULL DEFAULT 'en';
+
+-- Plain-text alternative of queued emails
+ALTER TABLE email_notifications ADD COLUMN IF NOT EXISTS text_body TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,23 @@
+-- Optional email categories users can opt out of. Users without a row receive all of them.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    marketing BOOLEAN NOT NULL DEFAULT TRUE,
+    expiry_reminders BOOLEAN NOT NULL DEFAULT TRUE,
+    release_announcements BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Addresses no email is sent to (hard bounces, complaints, added by admins)
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email VARCHAR(255) NOT NULL, -- lower case
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('hard_bounce', 'complaint', 'manual')),
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_suppressions_email ON email_suppressions(email);
+
+-- Category of outbox emails; empty for transactional emails. Emails the recipient
+-- opted out of are kept with status 'skipped'.
+ALTER TABLE email_notifications ADD COLUMN IF NOT EXISTS category VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- When licensees were emailed about a release published through the GitHub release webhook
+ALTER TABLE plugin_releases ADD COLUMN IF NOT EXISTS announced_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,11 @@
+-- Chat-ops channels: incoming webhook URL (empty turns the channel off) and the
+-- comma-separated events posted to it
+INSERT INTO system_settings (key, value, description) VALUES
+    ('chatops_slack_webhook_url', '', 'Slack incoming webhook URL for chat-ops messages, empty to turn off'),
+    ('chatops_slack_events', '*', 'Comma-separated events posted to Slack: order.paid, refund.created, github_grant.failed, scheduler.failed or *'),
+    ('chatops_discord_webhook_url', '', 'Discord webhook URL for chat-ops messages, empty to turn off'),
+    ('chatops_discord_events', '*', 'Comma-separated events posted to Discord: order.paid, refund.created, github_grant.failed, scheduler.failed or *'),
+    ('chatops_telegram_webhook_url', '', 'Telegram Bot API sendMessage URL (https://api.telegram.org/bot<token>/sendMessage), empty to turn off'),
+    ('chatops_telegram_chat_id', '', 'Telegram chat ID chat-ops messages are sent to'),
+    ('chatops_telegram_events', '*', 'Comma-separated events posted to Telegram: order.paid, refund.created, github_grant.failed, scheduler.failed or *')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,30 @@
+-- Reminders sent for unpaid orders and the purchases they led to
+CREATE TABLE IF NOT EXISTS checkout_recoveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plugin_id UUID NOT NULL REFERENCES plugins(id) ON DELETE CASCADE,
+    email_notification_id UUID REFERENCES email_notifications(id) ON DELETE SET NULL,
+    currency VARCHAR(10),
+    original_amount DECIMAL(10, 2) NOT NULL,
+    discount_percent INTEGER NOT NULL DEFAULT 0,
+    discounted_amount DECIMAL(10, 2) NOT NULL, -- the order amount while the discount is valid
+    discount_expires_at TIMESTAMP WITH TIME ZONE,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    recovered_at TIMESTAMP WITH TIME ZONE,
+    recovered_order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    recovered_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkout_recoveries_user_plugin ON checkout_recoveries(user_id, plugin_id);
+CREATE INDEX IF NOT EXISTS idx_checkout_recoveries_sent_at ON checkout_recoveries(sent_at);
+
+INSERT INTO system_settings (key, value, description) VALUES
+    ('schedule_recover_checkouts', '15 * * * *', 'Cron schedule: Email buyers whose orders are still unpaid, optionally with a discount'),
+    ('checkout_recovery_delay_hours', '24', 'Hours an order stays unpaid before its buyer is reminded'),
+    ('checkout_recovery_max_age_days', '7', 'Unpaid orders older than this many days get no reminder'),
+    ('checkout_recovery_discount_percent', '0', 'Discount offered in checkout reminders in percent, 0 for none'),
+    ('checkout_recovery_discount_hours', '48', 'Hours the checkout reminder discount can be used')
+ON CONFLICT (key) DO NOTHING;
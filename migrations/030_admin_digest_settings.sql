@@ -0,0 +1,7 @@
+-- Daily and weekly admin digest emails
+INSERT INTO system_settings (key, value, description) VALUES
+    ('schedule_admin_digest_daily', '0 7 * * *', 'Cron schedule: Email yesterday''s report to the admins'),
+    ('schedule_admin_digest_weekly', '30 7 * * 1', 'Cron schedule: Email last week''s report to the admins'),
+    ('admin_digest_recipients', '', 'Comma-separated addresses the admin digest is sent to, empty for ADMIN_EMAIL'),
+    ('admin_digest_periods', 'daily,weekly', 'Comma-separated admin digests to send: daily, weekly, empty for none')
+ON CONFLICT (key) DO NOTHING;
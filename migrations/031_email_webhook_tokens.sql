@@ -0,0 +1,7 @@
+-- Tokens of processed Mailgun webhooks, so a captured request cannot be replayed
+CREATE TABLE IF NOT EXISTS email_webhook_tokens (
+    token VARCHAR(255) PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_webhook_tokens_created_at ON email_webhook_tokens(created_at);